@@ -0,0 +1,161 @@
+package compiler
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sql-compiler/compiler/ast"
+	. "sql-compiler/compiler/rowType"
+	"sql-compiler/compiler/state_full_byte_code/byte_code"
+	"sql-compiler/unwrap"
+	"strings"
+)
+
+// the type of what each aggregate function returns, given the type of its argument (COUNT(*) has no argument)
+var aggregate_result_types = map[string]func(arg_type unwrap.Option[DataType]) DataType{
+	"COUNT": func(unwrap.Option[DataType]) DataType { return Int },
+	"SUM":   func(arg_type unwrap.Option[DataType]) DataType { return expect_numeric("SUM", arg_type) },
+	"AVG": func(arg_type unwrap.Option[DataType]) DataType {
+		expect_numeric("AVG", arg_type)
+		return Float
+	},
+	"MIN": func(arg_type unwrap.Option[DataType]) DataType { return arg_type.Expect("MIN needs an argument") },
+	"MAX": func(arg_type unwrap.Option[DataType]) DataType { return arg_type.Expect("MAX needs an argument") },
+}
+
+// makes calls of an aggregate function registered at runtime (see pubsub.Register_aggregate) compile, result_type gives the type of what it returns for the type of its argument and panics when it can't take that type
+func Register_aggregate_type(name string, result_type func(arg_type DataType) DataType) {
+	name = strings.ToUpper(name)
+	if Is_aggregate_function(name) {
+		panic("there is already an aggregate function called " + name)
+	}
+	aggregate_result_types[name] = func(arg_type unwrap.Option[DataType]) DataType {
+		return result_type(arg_type.Expect(name + " needs an argument"))
+	}
+}
+
+func expect_numeric(func_name string, arg_type unwrap.Option[DataType]) DataType {
+	type_ := arg_type.Expect(func_name + " needs an argument")
+	if type_ != Int && type_ != Float {
+		panic(func_name + " needs a number but got " + type_.To_string(0))
+	}
+	return type_
+}
+
+func is_aggregate_call(value any) bool {
+	call, ok := value.(ast.Func_call)
+	return ok && Is_aggregate_function(call.Name)
+}
+
+func Is_aggregate_function(name string) bool {
+	_, ok := aggregate_result_types[strings.ToUpper(name)]
+	return ok
+}
+
+// a select with aggregates (or a HAVING) outputs one row per group instead of one row per row of its table
+func Is_aggregated(select_ *ast.Select) bool {
+	if len(select_.Having) > 0 {
+		return true
+	}
+	for _, col := range select_.Selected_values {
+		if contains_aggregate_call(col.Value_to_select) {
+			return true
+		}
+	}
+	return false
+}
+
+// like SUM(price) / COUNT(*) or ROUND(AVG(price))
+func contains_aggregate_call(value any) bool {
+	if is_aggregate_call(value) {
+		return true
+	}
+	return slices.ContainsFunc(sub_values(value), contains_aggregate_call)
+}
+
+// a nested select like (SELECT COUNT(*) FROM todo WHERE todo.person_id == person.id) always has exactly one row with one value, so it can be used as a plain value
+func Is_scalar_select(select_ *ast.Select) bool {
+	return select_.Parent_select.IsSome() && Is_aggregated(select_) && len(select_.Selected_values) == 1 && len(select_.Group_by) == 0 && len(select_.Set_operations) == 0
+}
+
+func func_call_type(select_ *ast.Select, call ast.Func_call) DataType {
+	result_type, ok := aggregate_result_types[strings.ToUpper(call.Name)]
+	if !ok {
+		panic("unknown function " + call.Name)
+	}
+	if call.Star {
+		if strings.ToUpper(call.Name) != "COUNT" {
+			panic("only COUNT can take *")
+		}
+		return result_type(unwrap.None[DataType]())
+	}
+	if len(call.Args) != 1 {
+		panic(fmt.Sprintf("%s takes 1 argument but got %d", call.Name, len(call.Args)))
+	}
+	return result_type(unwrap.Some(value_type(select_, call.Args[0])))
+}
+
+// compiles the expressions of an aggregated select against its group row (the GROUP BY values followed by the results of the aggregates)
+type group_row_compiler struct {
+	select_            *ast.Select
+	group_by_locations []byte_code.Runtime_value_relative_location //where each GROUP BY column is in the table's rows
+	calls              []ast.Func_call                             //the same call (like COUNT(*) in both the selected values and HAVING) is only computed once
+	aggregation        byte_code.Aggregation
+}
+
+func (this *group_row_compiler) aggregate_index(call ast.Func_call) int {
+	for i := range this.calls {
+		if reflect.DeepEqual(this.calls[i], call) {
+			return i
+		}
+	}
+	aggregate_call := byte_code.Aggregate_call{Func: strings.ToUpper(call.Name)}
+	if !call.Star {
+		aggregate_call.Arg = get_Runtime_value_relative_location_if_Col(this.select_, call.Args[0])
+	}
+	this.calls = append(this.calls, call)
+	this.aggregation.Aggregates = append(this.aggregation.Aggregates, aggregate_call)
+	return len(this.calls) - 1
+}
+
+// a column of the select's own table can only be used if its grouped by (otherwise there is no single value for the group to take), columns of a parent select are still fine
+func (this *group_row_compiler) compile_value(value any) byte_code.Expression {
+	if is_aggregate_call(value) {
+		return byte_code.Runtime_value_relative_location{Amount_to_follow: 0, Col_index: len(this.group_by_locations) + this.aggregate_index(value.(ast.Func_call))}
+	}
+	switch value := value.(type) {
+	case ast.Plain_col_name, ast.Table_access:
+		location, _ := get_Runtime_value_relative_location_and_type(this.select_, value.(ast.Col))
+		if location.Amount_to_follow != 0 {
+			return location
+		}
+		for i := range this.group_by_locations {
+			if this.group_by_locations[i] == location {
+				return byte_code.Runtime_value_relative_location{Amount_to_follow: 0, Col_index: i}
+			}
+		}
+		panic(fmt.Sprintf("column %v must appear in the GROUP BY clause or be used in an aggregate function", value))
+	default:
+		return compile_expression(this.select_, value, this.compile_value)
+	}
+}
+
+func make_aggregation_byte_code(select_ *ast.Select, s *byte_code.Select) {
+	compiler := group_row_compiler{select_: select_}
+	for _, col := range select_.Group_by {
+		location, _ := get_Runtime_value_relative_location_and_type(select_, col)
+		if location.Amount_to_follow != 0 {
+			panic(fmt.Sprintf("GROUP BY column %v must be from the same table", col))
+		}
+		compiler.group_by_locations = append(compiler.group_by_locations, location)
+		compiler.aggregation.Group_by = append(compiler.aggregation.Group_by, location)
+	}
+	for _, col := range select_.Selected_values {
+		s.Selected_values_byte_code = append(s.Selected_values_byte_code, compiler.compile_value(col.Value_to_select))
+	}
+	for _, having := range select_.Having {
+		compiler.aggregation.Having = append(compiler.aggregation.Having, make_bool_expr_byte_code(having, compiler.compile_value))
+	}
+	s.Aggregation = unwrap.Some(compiler.aggregation)
+	s.Is_scalar = Is_scalar_select(select_)
+}
@@ -0,0 +1,25 @@
+package compiler
+
+import (
+	"fmt"
+	"sql-compiler/compiler/ast"
+	. "sql-compiler/compiler/parser/tokenizer"
+	. "sql-compiler/compiler/rowType"
+)
+
+// + - * and / only work on numbers, the result is an int when both sides are ints (so / rounds towards zero) and a float otherwise,
+// and its null when either side is null or when dividing by zero
+func arithmetic_col_info(select_ *ast.Select, arithmetic ast.Arithmetic) ColInfo {
+	left := value_col_info(select_, arithmetic.Left)
+	right := value_col_info(select_, arithmetic.Right)
+	for _, side := range []ColInfo{left, right} {
+		if side.Type != Int && side.Type != Float {
+			panic(fmt.Sprintf("%s needs numbers but got a %s", arithmetic.Operator, side.Type.To_string(0)))
+		}
+	}
+	type_ := Float
+	if left.Type == Int && right.Type == Int {
+		type_ = Int
+	}
+	return ColInfo{Type: type_, Nullable: left.Nullable || right.Nullable || arithmetic.Operator == SLASH}
+}
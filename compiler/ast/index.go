@@ -23,26 +23,243 @@ type Table_access struct {
 func (Table_access) __Col() {}
 func (Select) __Col()       {}
 
+// a node of a WHERE clause, the leaves are always a Where (a single comparison)
+type Bool_expr interface {
+	__Bool_expr()
+}
+
+func (Where) __Bool_expr()     {}
+func (And_expr) __Bool_expr()  {}
+func (Or_expr) __Bool_expr()   {}
+func (Not_expr) __Bool_expr()  {}
+func (Is_null) __Bool_expr()   {}
+func (In_list) __Bool_expr()   {}
+func (In_select) __Bool_expr() {}
+func (Exists) __Bool_expr()    {}
+
 type Where struct {
 	Value1   any
 	Operator TokenType
 	Value2   any
 }
 
+// an arithmetic expression like "price * quantity", the Operator is PLUS, MINUS, ASTERISK or SLASH and each side is a value (a column, a literal or another Arithmetic)
+type Arithmetic struct {
+	Left     any
+	Operator TokenType
+	Right    any
+}
+
+// CAST(Value AS Type)
+type Cast struct {
+	Value any
+	Type  rowType.DataType
+}
+
+// CASE WHEN ... THEN ... ELSE ... END, the value of the first when whose condition is true, Else is nil when there is no ELSE (in which case its null)
+type Case struct {
+	Whens []Case_when
+	Else  any
+}
+
+type Case_when struct {
+	Condition Bool_expr
+	Then      any
+}
+
+// the NULL literal
+type Null struct{}
+
+// "value IS NULL", or "value IS NOT NULL" when Negated, unlike a comparison its never unknown
+type Is_null struct {
+	Value   any
+	Negated bool
+}
+
+// "value [NOT] IN (a, b, ...)"
+type In_list struct {
+	Value   any
+	List    []any
+	Negated bool
+}
+
+// "value [NOT] IN (SELECT ...)", the select has a single column
+type In_select struct {
+	Value   any
+	Select  Select
+	Negated bool
+}
+
+// "EXISTS (SELECT ...)", NOT EXISTS is a Not_expr around it
+type Exists struct {
+	Select Select
+}
+
+type And_expr struct {
+	Left  Bool_expr
+	Right Bool_expr
+}
+
+type Or_expr struct {
+	Left  Bool_expr
+	Right Bool_expr
+}
+
+type Not_expr struct {
+	Expr Bool_expr
+}
+
+// flattens the top level ANDs of a WHERE clause so that each conjunct can be looked at on its own (for example when choosing an index)
+func Split_conjuncts(expr Bool_expr) []Bool_expr {
+	if and, ok := expr.(And_expr); ok {
+		return append(Split_conjuncts(and.Left), Split_conjuncts(and.Right)...)
+	}
+	return []Bool_expr{expr}
+}
+
+// a call like COUNT(*) or AVG(age), Star is set when the only argument is *
+type Func_call struct {
+	Name string
+	Args []any
+	Star bool
+}
+
+// SELECT * (or table.* when Table_name is set), replaced by the columns of the tables when the row schema is set
+type Star struct {
+	Table_name string
+}
+
 type Selected_value struct {
 	Value_to_select any
 	Alias           string
 }
 
+type Order_by_item struct {
+	Col  Col
+	Desc bool
+}
+
+type Join_kind string
+
+const (
+	Inner_join Join_kind = "INNER"
+	Left_join  Join_kind = "LEFT"
+	Right_join Join_kind = "RIGHT"
+	Full_join  Join_kind = "FULL"
+)
+
+type Join struct {
+	Kind  Join_kind
+	Table string
+	Alias string
+	On    Bool_expr
+	// compile time (post parsing stage) inserted
+	Cte Option[*Cte] //the WITH query that is joined instead of a table
+}
+
+// "name AS (SELECT ...)" in a WITH, the selects it is visible to read from it like from a table.
+// a Recursive one can read from itself, its select then is the rows it starts with UNIONed with a select that joins a table with it
+type Cte struct {
+	Name      string
+	Recursive bool
+	Select    Select
+	///type info
+	Row_schema rowType.RowSchema
+}
+
+type Set_operation_kind string
+
+const (
+	Union     Set_operation_kind = "UNION"
+	Intersect Set_operation_kind = "INTERSECT"
+	Except    Set_operation_kind = "EXCEPT"
+)
+
+// "UNION [ALL] SELECT ..." and the like, All keeps the duplicates (and counts them) instead of treating the rows as a set
+type Set_operation struct {
+	Kind   Set_operation_kind
+	All    bool
+	Select Select
+}
+
 type Select struct {
+	With            []Cte //visible to this select, the selects in it and the WITH queries after them
+	Distinct        bool
+	Distinct_on     []Col //the columns rows only have to differ in for DISTINCT ON, empty for a plain DISTINCT (where they have to differ in any of them)
 	Table           string
-	Wheres          []Where
+	Table_alias     string
+	Joins           []Join
+	Wheres          []Bool_expr //conjuncts, a row has to pass all of them
 	Selected_values []Selected_value
-	GroupByCol      Option[Col]
+	Group_by        []Col
+	Having          []Bool_expr     //conjuncts, a group has to pass all of them
+	Set_operations  []Set_operation //applied one after the other to the rows of this select, the ORDER BY, LIMIT and OFFSET are then applied to the combined rows
+	Order_by        []Order_by_item
+	Limit           Option[int]
+	Offset          Option[int]
 	///type info
 	Row_schema rowType.RowSchema
 	// compile time (post parsing stage) inserted
 	Parent_select Option[*Select]
+	Cte           Option[*Cte] //the WITH query the select reads from instead of a table
+}
+
+// points the FROM and the JOINs that name a WITH query (of this select or of a select it is in) at it, a WITH query hides the tables and the earlier WITH queries with its name
+func (this *Select) Resolve_ctes(visible []*Cte) {
+	for i := range this.With {
+		cte := &this.With[i]
+		if !cte.Recursive {
+			cte.Select.Resolve_ctes(visible)
+		}
+		visible = append(visible[:len(visible):len(visible)], cte)
+		if cte.Recursive {
+			cte.Select.Resolve_ctes(visible)
+		}
+	}
+	find := func(name string) Option[*Cte] {
+		for i := len(visible) - 1; i >= 0; i-- {
+			if visible[i].Name == name {
+				return unwrap.Some(visible[i])
+			}
+		}
+		return unwrap.None[*Cte]()
+	}
+	this.Cte = find(this.Table)
+	for i := range this.Joins {
+		this.Joins[i].Cte = find(this.Joins[i].Table)
+	}
+	for i := range this.Selected_values {
+		if col, ok := this.Selected_values[i].Value_to_select.(Select); ok {
+			col.Resolve_ctes(visible)
+			this.Selected_values[i].Value_to_select = col
+		}
+	}
+	for i := range this.Set_operations {
+		this.Set_operations[i].Select.Resolve_ctes(visible)
+	}
+	for i := range this.Wheres {
+		this.Wheres[i] = resolve_subquery_ctes(this.Wheres[i], visible)
+	}
+}
+
+// the selects of IN (SELECT ...) and EXISTS
+func resolve_subquery_ctes(expr Bool_expr, visible []*Cte) Bool_expr {
+	switch expr := expr.(type) {
+	case In_select:
+		expr.Select.Resolve_ctes(visible)
+		return expr
+	case Exists:
+		expr.Select.Resolve_ctes(visible)
+		return expr
+	case Not_expr:
+		return Not_expr{Expr: resolve_subquery_ctes(expr.Expr, visible)}
+	case And_expr:
+		return And_expr{Left: resolve_subquery_ctes(expr.Left, visible), Right: resolve_subquery_ctes(expr.Right, visible)}
+	case Or_expr:
+		return Or_expr{Left: resolve_subquery_ctes(expr.Left, visible), Right: resolve_subquery_ctes(expr.Right, visible)}
+	default:
+		return expr
+	}
 }
 
 func (this *Select) Recursively_link_children() {
@@ -56,4 +273,9 @@ func (this *Select) Recursively_link_children() {
 			panic("unexpected pointer")
 		}
 	}
+	//the selects that are combined with this one are at the same level, so they have the same parent
+	for i := range this.Set_operations {
+		this.Set_operations[i].Select.Parent_select = this.Parent_select
+		this.Set_operations[i].Select.Recursively_link_children()
+	}
 }
@@ -1,14 +1,18 @@
 package compiler
 
 import (
+	"fmt"
+	"slices"
 	"sql-compiler/assert"
 	"sql-compiler/compiler/ast"
+	"sql-compiler/compiler/functions"
 	. "sql-compiler/compiler/parser/tokenizer"
 	. "sql-compiler/compiler/rowType"
 	"sql-compiler/compiler/state_full_byte_code/byte_code"
 	"sql-compiler/db_tables"
-	"sql-compiler/display"
 	"sql-compiler/unwrap"
+	"sql-compiler/utils"
+	"strings"
 )
 
 func Make_select_byte_code(select_ *ast.Select) byte_code.Select {
@@ -16,15 +20,64 @@ func Make_select_byte_code(select_ *ast.Select) byte_code.Select {
 	s := byte_code.Select{
 		Table_name: select_.Table,
 	}
+	if select_.Cte.IsSome() {
+		s.Cte = unwrap.Some(compile_cte(select_.Cte.Unwrap()))
+	}
+	for i := range select_.Joins {
+		s.Joins = append(s.Joins, make_join_byte_code(select_, i))
+	}
 
 	for _, where := range select_.Wheres {
-		s.Wheres_byte_code = append(s.Wheres_byte_code, byte_code.Where{
-			Value_1:      get_Runtime_value_relative_location_if_Col(select_, where.Value1),
-			Compare_type: string(where.Operator),
-			Value_2:      get_Runtime_value_relative_location_if_Col(select_, where.Value2),
-		})
+		if subquery, anti, ok := as_subquery_predicate(where); ok {
+			s.Semi_joins = append(s.Semi_joins, make_semi_join_byte_code(select_, subquery, anti))
+			continue
+		}
+		check_comparison_types(select_, where)
+		s.Wheres_byte_code = append(s.Wheres_byte_code, make_bool_expr_byte_code(where, func(value any) byte_code.Expression {
+			return get_Runtime_value_relative_location_if_Col(select_, value)
+		}))
+	}
+
+	if Is_aggregated(select_) {
+		make_aggregation_byte_code(select_, &s)
+	} else {
+		make_selected_values_byte_code(select_, &s)
+		s.Key_col_index = key_col_index(select_)
+		if len(select_.Set_operations) == 0 {
+			s.Join_key_values = join_key_values(select_)
+		}
+	}
+	s.Access_path = Choose_access_path(select_sources(select_)[0].table, select_)
+
+	// without aggregates GROUP BY only groups the selected rows (into extra path segments)
+	if s.Aggregation.IsNone() {
+		for _, col := range select_.Group_by {
+			s.Group_by_col_indexes = append(s.Group_by_col_indexes, selected_col_index(select_, col, "GROUP BY"))
+		}
+	}
+
+	if select_.Distinct {
+		s.Distinct_col_indexes = distinct_col_indexes(select_)
+	}
+
+	for i := range select_.Set_operations {
+		operation := &select_.Set_operations[i]
+		if len(operation.Select.Group_by) > 0 && !Is_aggregated(&operation.Select) {
+			panic(fmt.Sprintf("a select after %s can only GROUP BY when it has aggregates", operation.Kind))
+		}
+		s.Set_operations = append(s.Set_operations, byte_code.Set_operation{Kind: string(operation.Kind), All: operation.All, Select: unkeyed(Make_select_byte_code(&operation.Select))})
+	}
+
+	for _, item := range select_.Order_by {
+		s.Order_by = append(s.Order_by, byte_code.Order_by_col{Col_index: selected_col_index(select_, item.Col, "ORDER BY"), Desc: item.Desc})
 	}
+	s.Limit = select_.Limit
+	s.Offset = select_.Offset.UnwrapOr(0)
 
+	return s
+}
+
+func make_selected_values_byte_code(select_ *ast.Select, s *byte_code.Select) {
 	for _, col := range select_.Selected_values {
 		switch col := col.Value_to_select.(type) {
 		case ast.Select:
@@ -34,67 +87,212 @@ func Make_select_byte_code(select_ *ast.Select) byte_code.Select {
 			s.Selected_values_byte_code = append(s.Selected_values_byte_code, get_Runtime_value_relative_location_if_Col(select_, col))
 		case ast.Table_access:
 			s.Selected_values_byte_code = append(s.Selected_values_byte_code, get_Runtime_value_relative_location_if_Col(select_, col))
-		case int, string, bool:
+		case ast.Arithmetic, ast.Func_call, ast.Cast, ast.Case:
+			s.Selected_values_byte_code = append(s.Selected_values_byte_code, get_Runtime_value_relative_location_if_Col(select_, col))
+		case int, float64, string, bool:
 			s.Selected_values_byte_code = append(s.Selected_values_byte_code, col)
 		default:
 			panic("unhandled")
 		}
 	}
-	table := db_tables.Tables.Get(select_.Table)
-	s.Col_and_value_to_index_by = Choose_table_col_to_index(table, select_)
+}
 
-	// Compile GROUP BY column to index
-	if select_.GroupByCol.IsSome() {
-		group_by_col := select_.GroupByCol.Unwrap()
-		var col_name string
-		switch col := group_by_col.(type) {
-		case ast.Plain_col_name:
-			col_name = string(col)
-		case ast.Table_access:
-			col_name = col.Col_name
-			if col.Table_name != select_.Table {
-				panic("GROUP BY column must be from the same table")
-			}
-		default:
-			panic("GROUP BY column must be a plain column name or table access")
+// a row of a join is one row of each of its tables (or null padding), so it is keyed by the key column of each of them (their primary key, otherwise their first column)
+func join_key_values(select_ *ast.Select) []byte_code.Expression {
+	if len(select_.Joins) == 0 {
+		return nil
+	}
+	res := []byte_code.Expression{}
+	for _, source := range select_sources(select_) {
+		key_col := source.table.Columns[source.table.R_Table.Key_col].Name
+		res = append(res, get_Runtime_value_relative_location_if_Col(select_, ast.Table_access{Table_name: source.name, Col_name: key_col}))
+	}
+	return res
+}
+
+// the rows of a WITH query, of the select of IN or EXISTS and of the selects of a set operation are read (and compared) by their selected values only, so they don't get the keys of their joins
+func unkeyed(select_ byte_code.Select) byte_code.Select {
+	select_.Join_key_values = nil
+	return select_
+}
+
+// the rows of a select from a single table are keyed by its primary key when its selected, otherwise by the first selected column (the rows of a join by join_key_values)
+func key_col_index(select_ *ast.Select) int {
+	table := select_sources(select_)[0].table
+	if len(select_.Joins) > 0 || table.Primary_key == "" {
+		return 0
+	}
+	for i, col := range select_.Selected_values {
+		if col_name, ok := table_col(table, select_, col.Value_to_select); ok && col_name == table.Primary_key {
+			return i
+		}
+	}
+	return 0
+}
+
+func value_type(select_ *ast.Select, value any) DataType {
+	return value_col_info(select_, value).Type
+}
+
+// the type of a value and whether it can be null (the Name is left empty)
+func value_col_info(select_ *ast.Select, value any) ColInfo {
+	switch value := value.(type) {
+	case ast.Plain_col_name, ast.Table_access:
+		_, col_info := get_Runtime_value_relative_location_and_col_info(select_, value.(ast.Col))
+		return ColInfo{Type: col_info.Type, Nullable: col_info.Nullable}
+	case ast.Func_call:
+		if !is_aggregate_call(value) {
+			return scalar_call_col_info(select_, value)
 		}
+		//only COUNT has a value when there are no (non null) values to aggregate
+		return ColInfo{Type: func_call_type(select_, value), Nullable: strings.ToUpper(value.Name) != "COUNT"}
+	case ast.Arithmetic:
+		return arithmetic_col_info(select_, value)
+	case ast.Cast:
+		if _, is_null := value.Value.(ast.Null); is_null {
+			return ColInfo{Type: value.Type, Nullable: true}
+		}
+		return functions.Cast_result(value_col_info(select_, value.Value), value.Type)
+	case ast.Case:
+		return case_col_info(select_, value)
+	case int:
+		return ColInfo{Type: Int}
+	case float64:
+		return ColInfo{Type: Float}
+	case string:
+		return ColInfo{Type: String}
+	case bool:
+		return ColInfo{Type: Bool}
+	default:
+		panic(fmt.Sprintf("%T can not be used as a value", value))
+	}
+}
 
-		// table.Get_col_index(col_name)
-		col_index := select_.Row_schema.Find_field_index(col_name)
-		if col_index == -1 {
-			panic("GROUP BY column " + col_name + " not found in table " + select_.Table)
+// where a column (that the clause refers to by name) is in the row that the select outputs
+// the values of a nested select keep changing, so they can't be compared to tell which rows are the same (for DISTINCT and set operations)
+func has_nested_select(select_ *ast.Select) bool {
+	return slices.ContainsFunc(select_.Selected_values, func(col ast.Selected_value) bool {
+		_, is_select := col.Value_to_select.(ast.Select)
+		return is_select
+	})
+}
+
+func distinct_col_indexes(select_ *ast.Select) []int {
+	if has_nested_select(select_) {
+		panic("DISTINCT can not be used with nested selects")
+	}
+	col_indexes := []int{}
+	for _, col := range select_.Distinct_on {
+		col_indexes = append(col_indexes, selected_col_index(select_, col, "DISTINCT ON"))
+	}
+	if len(col_indexes) == 0 {
+		for i := range select_.Row_schema {
+			col_indexes = append(col_indexes, i)
 		}
-		s.Group_by_col_index = unwrap.Some(col_index)
-	} else {
-		s.Group_by_col_index = unwrap.None[int]()
 	}
+	return col_indexes
+}
 
-	return s
+func selected_col_index(select_ *ast.Select, col ast.Col, clause string) int {
+	var col_name string
+	switch col := col.(type) {
+	case ast.Plain_col_name:
+		col_name = string(col)
+	case ast.Table_access:
+		col_name = col.Col_name
+		if !slices.ContainsFunc(select_sources(select_), func(source table_source) bool { return source.name == col.Table_name }) {
+			panic(clause + " column must be from one of the select's tables")
+		}
+	default:
+		panic(clause + " column must be a plain column name or table access")
+	}
+	//the rows are only sorted (or grouped) after they're mapped to the selected values
+	if !slices.ContainsFunc(select_.Row_schema, func(col ColInfo) bool { return col.Name == col_name }) {
+		panic(fmt.Sprintf("%s column %s must be one of the selected columns", clause, col_name))
+	}
+	return select_.Row_schema.Find_field_index(col_name)
+}
+
+// compile_value compiles the values being compared, as what they refer to depends on what row the expression is evaluated against
+func make_bool_expr_byte_code(expr ast.Bool_expr, compile_value func(value any) byte_code.Expression) byte_code.Bool_expr {
+	switch expr := expr.(type) {
+	case ast.Where:
+		if is_pattern_operator(expr.Operator) {
+			return byte_code.Where{
+				Value_1:      compile_value(expr.Value1),
+				Compare_type: string(expr.Operator),
+				Value_2:      compile_pattern(expr.Operator, expr.Value2),
+			}
+		}
+		return byte_code.Where{
+			Value_1:      compile_value(expr.Value1),
+			Compare_type: string(expr.Operator),
+			Value_2:      compile_value(expr.Value2),
+		}
+	case ast.And_expr:
+		return byte_code.And{Left: make_bool_expr_byte_code(expr.Left, compile_value), Right: make_bool_expr_byte_code(expr.Right, compile_value)}
+	case ast.Or_expr:
+		return byte_code.Or{Left: make_bool_expr_byte_code(expr.Left, compile_value), Right: make_bool_expr_byte_code(expr.Right, compile_value)}
+	case ast.Not_expr:
+		return byte_code.Not{Expr: make_bool_expr_byte_code(expr.Expr, compile_value)}
+	case ast.Is_null:
+		return byte_code.Is_null{Value: compile_value(expr.Value), Negated: expr.Negated}
+	case ast.In_list:
+		list := []byte_code.Expression{}
+		for _, item := range expr.List {
+			list = append(list, compile_value(item))
+		}
+		return byte_code.In_list{Value: compile_value(expr.Value), List: list, Negated: expr.Negated}
+	case ast.In_select, ast.Exists:
+		panic("IN (SELECT ...) and EXISTS can only be used on their own (or under a NOT) as a condition of WHERE")
+	default:
+		panic(fmt.Sprintf("unhandled bool expression %T", expr))
+	}
 }
 
 func Recursively_set_selects_row_schema(select_ *ast.Select) RowSchema {
-	for _, col := range select_.Selected_values {
+	expand_stars(select_)
+	for i, col := range select_.Selected_values {
 		switch col_value := col.Value_to_select.(type) {
 		case ast.Select:
-			NestedSelectsRowSchema = append(NestedSelectsRowSchema, Recursively_set_selects_row_schema(&col_value))
+			childs_row_schema := Recursively_set_selects_row_schema(&col_value)
+			select_.Selected_values[i].Value_to_select = col_value //so that the nested select has its row schema when its compiled
+			if Is_scalar_select(&col_value) {
+				select_.Row_schema = append(select_.Row_schema, ColInfo{Name: col.Alias, Type: childs_row_schema[0].Type, Nullable: childs_row_schema[0].Nullable})
+				continue
+			}
+			NestedSelectsRowSchema = append(NestedSelectsRowSchema, childs_row_schema)
 			select_.Row_schema = append(select_.Row_schema, ColInfo{Name: col.Alias, Type: DataType(len(NestedSelectsRowSchema) - 1)})
+		case ast.Func_call:
+			col_info := value_col_info(select_, col_value)
+			col_info.Name = strings.ToLower(col_value.Name)
+			if col.Alias != "" {
+				col_info.Name = col.Alias
+			}
+			select_.Row_schema = append(select_.Row_schema, col_info)
+		case ast.Arithmetic, ast.Cast, ast.Case:
+			col_info := value_col_info(select_, col_value)
+			col_info.Name = col.Alias
+			select_.Row_schema = append(select_.Row_schema, col_info)
 		case ast.Plain_col_name:
-			_, type_ := get_Runtime_value_relative_location_and_type(select_, col_value)
+			_, col_info := get_Runtime_value_relative_location_and_col_info(select_, col_value)
 			schema_col_name := string(col_value)
 			if col.Alias != "" {
 				schema_col_name = col.Alias
 			}
-			select_.Row_schema = append(select_.Row_schema, ColInfo{Name: schema_col_name, Type: type_})
+			select_.Row_schema = append(select_.Row_schema, ColInfo{Name: schema_col_name, Type: col_info.Type, Nullable: col_info.Nullable})
 		case ast.Table_access:
-			_, type_ := get_Runtime_value_relative_location_and_type(select_, col_value)
+			_, col_info := get_Runtime_value_relative_location_and_col_info(select_, col_value)
 			schema_col_name := col_value.Col_name
 			if col.Alias != "" {
 				schema_col_name = col.Alias
 			}
-			select_.Row_schema = append(select_.Row_schema, ColInfo{Name: schema_col_name, Type: type_})
+			select_.Row_schema = append(select_.Row_schema, ColInfo{Name: schema_col_name, Type: col_info.Type, Nullable: col_info.Nullable})
 		//////////
 		case int:
 			select_.Row_schema = append(select_.Row_schema, ColInfo{Name: col.Alias, Type: Int})
+		case float64:
+			select_.Row_schema = append(select_.Row_schema, ColInfo{Name: col.Alias, Type: Float})
 		case string:
 			select_.Row_schema = append(select_.Row_schema, ColInfo{Name: col.Alias, Type: String})
 		case bool:
@@ -103,26 +301,118 @@ func Recursively_set_selects_row_schema(select_ *ast.Select) RowSchema {
 			panic("no other types supported")
 		}
 	}
+	for i := range select_.Set_operations {
+		combine_set_operation_row_schema(select_, &select_.Set_operations[i])
+	}
 	return select_.Row_schema
 }
+
+// the selects combined by a set operation need the same number of columns, each of a type that can be in the same column, the names come from the first select
+func combine_set_operation_row_schema(select_ *ast.Select, operation *ast.Set_operation) {
+	operation_row_schema := Recursively_set_selects_row_schema(&operation.Select)
+	if len(operation_row_schema) != len(select_.Row_schema) {
+		panic(fmt.Sprintf("the selects of a %s need the same number of columns but got %d and %d", operation.Kind, len(select_.Row_schema), len(operation_row_schema)))
+	}
+	if has_nested_select(select_) || has_nested_select(&operation.Select) {
+		panic(fmt.Sprintf("nested selects can not be used with %s", operation.Kind))
+	}
+	for i := range select_.Row_schema {
+		select_.Row_schema[i].Type = functions.Common_type(string(operation.Kind), []ColInfo{select_.Row_schema[i], operation_row_schema[i]})
+		select_.Row_schema[i].Nullable = select_.Row_schema[i].Nullable || operation_row_schema[i].Nullable
+	}
+}
 func get_Runtime_value_relative_location_if_Col(this *ast.Select, expr any) byte_code.Expression {
 	if col, ok := expr.(ast.Col); ok {
 		location_info, _ := get_Runtime_value_relative_location_and_type(this, col)
 		return location_info
 	}
-	return expr
+	if is_aggregate_call(expr) {
+		panic(expr.(ast.Func_call).Name + " can only be used in the selected values and in HAVING")
+	}
+	return compile_expression(this, expr, func(value any) byte_code.Expression {
+		return get_Runtime_value_relative_location_if_Col(this, value)
+	})
+}
+
+// a table that a select reads from, the FROM table followed by each joined table, the rows the select works on are the columns of each one after the other
+type table_source struct {
+	name     string //what the table is referred to as, its alias if it has one
+	table    *db_tables.Table
+	offset   int  //where its columns start in the rows the select works on
+	nullable bool //when its on the padded side of an outer join
+}
+
+func select_sources(select_ *ast.Select) []table_source {
+	source_name := func(table string, alias string) string {
+		if alias != "" {
+			return alias
+		}
+		return table
+	}
+	sources := []table_source{{name: source_name(select_.Table, select_.Table_alias), table: source_table(select_.Table, select_.Cte)}}
+	for _, join := range select_.Joins {
+		previous := sources[len(sources)-1]
+		if join.Kind == ast.Right_join || join.Kind == ast.Full_join {
+			for i := range sources {
+				sources[i].nullable = true
+			}
+		}
+		sources = append(sources, table_source{
+			name:     source_name(join.Table, join.Alias),
+			table:    source_table(join.Table, join.Cte),
+			offset:   previous.offset + len(previous.table.Columns),
+			nullable: join.Kind == ast.Left_join || join.Kind == ast.Full_join,
+		})
+	}
+	return sources
+}
+
+// a * is expanded against the columns the tables have when the query is compiled, so a query compiled after a table changes selects its new columns.
+// a query that is already running isn't expanded again, it keeps selecting the columns it was compiled with
+func expand_stars(select_ *ast.Select) {
+	is_star := func(col ast.Selected_value) bool {
+		_, ok := col.Value_to_select.(ast.Star)
+		return ok
+	}
+	if !slices.ContainsFunc(select_.Selected_values, is_star) {
+		return
+	}
+	expanded := []ast.Selected_value{}
+	for _, col := range select_.Selected_values {
+		star, ok := col.Value_to_select.(ast.Star)
+		if !ok {
+			expanded = append(expanded, col)
+			continue
+		}
+		found := false
+		for _, source := range select_sources(select_) {
+			if star.Table_name != "" && star.Table_name != source.name {
+				continue
+			}
+			found = true
+			for _, table_col := range source.table.Columns {
+				expanded = append(expanded, ast.Selected_value{Value_to_select: ast.Table_access{Table_name: source.name, Col_name: table_col.Name}})
+			}
+		}
+		if !found {
+			panic(star.Table_name + ".* does not name a table the select reads from")
+		}
+	}
+	select_.Selected_values = expanded
 }
 
 func get_Runtime_value_relative_location_and_type(select_ *ast.Select, col ast.Col) (byte_code.Runtime_value_relative_location, DataType) {
+	location, col_info := get_Runtime_value_relative_location_and_col_info(select_, col)
+	return location, col_info.Type
+}
+
+func get_Runtime_value_relative_location_and_col_info(select_ *ast.Select, col ast.Col) (byte_code.Runtime_value_relative_location, ColInfo) {
 	var col_name string
 	switch col := col.(type) {
 	case ast.Plain_col_name:
 		col_name = string(col)
 	case ast.Table_access:
 		col_name = col.Col_name
-		if col.Table_name != select_.Table {
-			goto Try_parent
-		}
 	case ast.Select:
 		panic("not implemented")
 	default:
@@ -131,77 +421,163 @@ func get_Runtime_value_relative_location_and_type(select_ *ast.Select, col ast.C
 	if col_name == "" {
 		panic("col_name is empty")
 	}
-	{
-		table := db_tables.Tables.Get(select_.Table)
-		index := table.Get_col_index(col_name)
-		if index != -1 {
-			return byte_code.Runtime_value_relative_location{Amount_to_follow: 0, Col_index: index}, table.Columns[index].Type
+	found := false
+	var location byte_code.Runtime_value_relative_location
+	var col_info ColInfo
+	for _, source := range select_sources(select_) {
+		if table_access, ok := col.(ast.Table_access); ok && table_access.Table_name != source.name {
+			continue
+		}
+		index := source.table.Get_col_index(col_name)
+		if index == -1 {
+			continue
 		}
+		if found {
+			panic("col " + col_name + " is ambiguous, it is in more than one of the tables of select " + select_.Table)
+		}
+		found = true
+		location = byte_code.Runtime_value_relative_location{Amount_to_follow: 0, Col_index: source.offset + index}
+		col_info = source.table.Columns[index]
+		col_info.Nullable = col_info.Nullable || source.nullable
+	}
+	if found {
+		return location, col_info
 	}
 
-Try_parent:
 	if select_.Parent_select.IsNone() {
 		panic("col " + col_name + " not found in select " + select_.Table)
 	}
-	location_info, type_ := get_Runtime_value_relative_location_and_type(select_.Parent_select.Unwrap(), col)
-	return location_info.Add_one(), type_
+	location_info, col_info := get_Runtime_value_relative_location_and_col_info(select_.Parent_select.Unwrap(), col)
+	return location_info.Add_one(), col_info
 }
 
-func Choose_table_col_to_index(table *db_tables.Table, select_ *ast.Select) byte_code.ColValuePair {
-	type IndexSelectionInfo struct {
-		channel_count int
-		col_name      string
-		value         any
+// ON has to be one or more equalities (joined by AND) between a column of the joined table and a column of the tables before it, so the rows can be matched by a key
+func make_join_byte_code(select_ *ast.Select, join_index int) byte_code.Join {
+	join := select_.Joins[join_index]
+	source := select_sources(select_)[join_index+1]
+	res := byte_code.Join{Kind: string(join.Kind), Table_name: join.Table}
+	if join.Cte.IsSome() {
+		res.Cte = unwrap.Some(compile_cte(join.Cte.Unwrap()))
+	}
+	for _, conjunct := range ast.Split_conjuncts(join.On) {
+		where, ok := conjunct.(ast.Where)
+		if !ok || where.Operator != EQ {
+			panic("ON only supports equalities joined by AND")
+		}
+		col1, ok1 := where.Value1.(ast.Col)
+		col2, ok2 := where.Value2.(ast.Col)
+		if !ok1 || !ok2 {
+			panic("ON has to compare columns")
+		}
+		location1, _ := get_Runtime_value_relative_location_and_type(select_, col1)
+		location2, _ := get_Runtime_value_relative_location_and_type(select_, col2)
+		if location1.Col_index >= source.offset {
+			location1, location2 = location2, location1
+		}
+		if location1.Amount_to_follow != 0 || location2.Amount_to_follow != 0 || location1.Col_index >= source.offset || location2.Col_index < source.offset {
+			panic("ON has to compare a column of " + source.name + " with a column of the tables before it")
+		}
+		res.Left_key = append(res.Left_key, location1)
+		res.Right_key = append(res.Right_key, location2.Col_index-source.offset)
 	}
+	return res
+}
 
-	best_index := IndexSelectionInfo{}
-	for _, where := range select_.Wheres {
-		var col string
-		switch value1 := where.Value1.(type) {
-		case ast.Plain_col_name:
-			col = string(value1)
-			if !table.HasCol(col) {
+// the columns compared with == (or IS NULL) are matched against the columns of each index of the table,
+// each index that has a prefix of its columns compared can be read from, with the channel of the values of that prefix
+func hash_candidates(table *db_tables.Table, select_ *ast.Select) [][]byte_code.ColValuePair {
+	equalities := map[string]any{}
+	for _, conjunct := range select_.Wheres {
+		//only a plain comparison that has to hold for every row can narrow down the rows to a channel
+		var where ast.Where
+		switch conjunct := conjunct.(type) {
+		case ast.Where:
+			if _, is_null := conjunct.Value2.(ast.Null); is_null { //comparing with null is never true, so there is no channel for it
 				continue
 			}
-			goto Try_to_index_col
-		case ast.Table_access:
-			if value1.Table_name != table.Name {
+			where = conjunct
+		case ast.Is_null:
+			if conjunct.Negated {
 				continue
 			}
-			col = string(value1.Col_name)
-			goto Try_to_index_col
+			//the rows where the column is null have a channel of their own
+			where = ast.Where{Value1: conjunct.Value, Operator: EQ, Value2: ast.Null{}}
 		default:
 			continue
-
 		}
-	Try_to_index_col:
-		// if !table.col_is_primary_key(col) {
-		// 	return byte_code.ColValuePair{
-		// 		Col:   col,
-		// 		Value: where.Value2,
-		// 	}
-		// }
-		if !table.HasIndex(col) {
+		if where.Operator != EQ { //ranges are read from ordered indexes (see choose_range_to_index)
 			continue
 		}
-		if where.Operator != EQ { //until we start using ordered maps then you can index on < and >
+		col, ok := table_col(table, select_, where.Value1)
+		if !ok {
 			continue
 		}
-
-		if _, is_of_type_bool := where.Value2.(bool); is_of_type_bool {
+		if _, compared := equalities[col]; compared {
 			continue
 		}
-		if table.Get_index(col) != nil {
-			best_index.channel_count = len(table.Get_index(col).Channels)
-			best_index.col_name = string(col)
-			best_index.value = get_Runtime_value_relative_location_if_Col(select_, where.Value2)
+		if value, ok := index_value(table, select_, col, where.Value2); ok {
+			equalities[col] = value
 		}
 	}
-	display.DisplayStruct(best_index)
-	return byte_code.ColValuePair{
-		Col:   best_index.col_name,
-		Value: best_index.value,
+
+	candidates := [][]byte_code.ColValuePair{}
+	for _, index := range table.R_Table.Indexes {
+		prefix := []byte_code.ColValuePair{}
+		for _, col_index := range index.Cols_indexing_on {
+			col := table.Columns[col_index].Name
+			value, compared := equalities[col]
+			if !compared {
+				break
+			}
+			prefix = append(prefix, byte_code.ColValuePair{Col: col, Value: value})
+		}
+		if len(prefix) > 0 {
+			candidates = append(candidates, prefix)
+		}
+	}
+	return candidates
+}
+
+// a column of the table the select reads from
+func table_col(table *db_tables.Table, select_ *ast.Select, value any) (string, bool) {
+	switch value := value.(type) {
+	case ast.Plain_col_name:
+		return string(value), table.HasCol(string(value))
+	case ast.Table_access:
+		return value.Col_name, value.Table_name == select_sources(select_)[0].name
+	default:
+		return "", false
+	}
+}
+
+// a column of the table the select reads from that has an index of its own
+func indexed_col(table *db_tables.Table, select_ *ast.Select, value any) (string, bool) {
+	col, ok := table_col(table, select_, value)
+	return col, ok && table.HasIndex(col)
+}
+
+// what the column is compared with, when it can be used to pick the rows from an index before the select has any rows of its own
+func index_value(table *db_tables.Table, select_ *ast.Select, col string, value any) (byte_code.StringOrNumber, bool) {
+	switch value.(type) {
+	case ast.Arithmetic, ast.Func_call, ast.Cast, ast.Case: //the channel is looked up by a single value
+		return nil, false
+	}
+	if col_value, is_col := value.(ast.Col); is_col {
+		//the channel is picked before the select has any rows of its own (like the rows of a joined table), so it can only be picked with a value from a parent select
+		if location, _ := get_Runtime_value_relative_location_and_type(select_, col_value); location.Amount_to_follow == 0 {
+			return nil, false
+		}
+	}
+	col_type := table.Columns[table.Get_col_index(col)].Type
+	if col_type == Json { //json documents are not looked up by value
+		return nil, false
+	}
+	index_value := get_Runtime_value_relative_location_if_Col(select_, value)
+	if timestamp, is_string := index_value.(string); is_string && col_type == Timestamp {
+		//so it has the same key as the timestamps put in the index
+		index_value = utils.Parse_timestamp(timestamp)
 	}
+	return index_value, true
 }
 
 ////// closer to runtime
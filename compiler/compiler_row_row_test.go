@@ -1,10 +1,16 @@
 package compiler
 
 import (
+	"reflect"
 	"sql-compiler/compare"
 	"sql-compiler/compiler/parser"
 	"sql-compiler/compiler/parser/tokenizer"
 	"sql-compiler/compiler/rowType"
+	"sql-compiler/compiler/state_full_byte_code/byte_code"
+	"sql-compiler/db_tables"
+	pubsub "sql-compiler/pub_sub"
+	"sql-compiler/unwrap"
+	"sql-compiler/utils"
 	"testing"
 )
 
@@ -43,3 +49,184 @@ func Test_query_to_row_schema_with_alias(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func Test_query_to_row_schema_with_functions(t *testing.T) {
+	src := `select LOWER(title) as lower_title, length(description), COALESCE(description, "none") as described, CAST(person_id AS text) as owner, CASE WHEN done == true THEN 1 WHEN is_public == true THEN 2.5 END as rank, ROUND(id * 1.5, 1) as rounded from todo `
+	l := tokenizer.NewLexer(src)
+	parser := parser.Parser{Tokens: l.Tokenize()}
+	select_ := parser.Parse_Select()
+	select_.Recursively_link_children()
+	actual := Recursively_set_selects_row_schema(&select_)
+	expected := rowType.RowSchema{
+		rowType.ColInfo{Name: "lower_title", Type: rowType.String},
+		rowType.ColInfo{Name: "length", Type: rowType.Int, Nullable: true},
+		rowType.ColInfo{Name: "described", Type: rowType.String},
+		rowType.ColInfo{Name: "owner", Type: rowType.String},
+		rowType.ColInfo{Name: "rank", Type: rowType.Float, Nullable: true},
+		rowType.ColInfo{Name: "rounded", Type: rowType.Float},
+	}
+	output, err := compare.Compare(expected, actual, "")
+	println(output)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_query_to_row_schema_with_set_operations(t *testing.T) {
+	row_schema := func(src string) rowType.RowSchema {
+		parser := parser.Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+		select_ := parser.Parse_Select()
+		select_.Recursively_link_children()
+		return Recursively_set_selects_row_schema(&select_)
+	}
+	actual := row_schema(`select id, title, 1 as rank from todo union select id, description, 2.5 from todo `)
+	expected := rowType.RowSchema{
+		rowType.ColInfo{Name: "id", Type: rowType.Int},
+		rowType.ColInfo{Name: "title", Type: rowType.String, Nullable: true},
+		rowType.ColInfo{Name: "rank", Type: rowType.Float},
+	}
+	output, err := compare.Compare(expected, actual, "")
+	println(output)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, src := range []string{
+		`select id, title from todo except select id from todo `,
+		`select id, title from todo intersect select title, id from todo `,
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s should not compile", src)
+				}
+			}()
+			row_schema(src)
+		}()
+	}
+}
+
+func Test_range_to_index_by(t *testing.T) {
+	db_tables.Tables.Add("event", db_tables.NewTable("event", rowType.RowSchema{{Name: "name", Type: rowType.String}, {Name: "at", Type: rowType.Timestamp}, {Name: "id", Type: rowType.Int}}))
+	db_tables.Tables.Get("event").Index_on("at", pubsub.Ordered)
+	index_range := func(src string) unwrap.Option[byte_code.Index_range] {
+		parser := parser.Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+		select_ := parser.Parse_Select()
+		select_.Recursively_link_children()
+		Recursively_set_selects_row_schema(&select_)
+		return Make_select_byte_code(&select_).Access_path.Range
+	}
+	bound := func(value any, inclusive bool) unwrap.Option[byte_code.Index_bound] {
+		return unwrap.Some(byte_code.Index_bound{Value: value, Inclusive: inclusive})
+	}
+	none := unwrap.None[byte_code.Index_bound]()
+	for src, expected := range map[string]unwrap.Option[byte_code.Index_range]{
+		`select id from event where at > "2024-01-02" `:                        unwrap.Some(byte_code.Index_range{Col: "at", Low: bound(utils.Parse_timestamp("2024-01-02"), false), High: none}),
+		`select id from event where at BETWEEN "2024-01-02" AND "2024-01-05" `: unwrap.Some(byte_code.Index_range{Col: "at", Low: bound(utils.Parse_timestamp("2024-01-02"), true), High: bound(utils.Parse_timestamp("2024-01-05"), true)}),
+		`select id from event where at == "2024-01-02" `:                       unwrap.None[byte_code.Index_range](), //an equality is read from a channel of its own
+		`select id from todo where person_id > 2 `:                             unwrap.None[byte_code.Index_range](), //a hash index has no order
+	} {
+		if actual := index_range(src); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%s: expected %#v but got %#v", src, expected, actual)
+		}
+	}
+}
+
+func Test_like_prefix_range(t *testing.T) {
+	for pattern, expected := range map[string][2]string{
+		"abc%":     {"abc", "abd"},
+		"ab_c%":    {"ab", "ac"},
+		`50\%off%`: {"50%off", "50%ofg"},
+		"%abc":     {"", ""},
+	} {
+		prefix := like_prefix(pattern)
+		after, _ := after_prefix(prefix)
+		if prefix != expected[0] || after != expected[1] {
+			t.Errorf("%s: expected the range %v but got [%s, %s)", pattern, expected, prefix, after)
+		}
+	}
+	if _, ok := after_prefix("\xff\xff"); ok {
+		t.Error("there is no string after every string starting with 0xff bytes")
+	}
+}
+
+func Test_cols_and_values_to_index_by(t *testing.T) {
+	index_by := func(src string) []byte_code.ColValuePair {
+		parser := parser.Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+		select_ := parser.Parse_Select()
+		select_.Recursively_link_children()
+		Recursively_set_selects_row_schema(&select_)
+		return Make_select_byte_code(&select_).Access_path.Cols_and_values
+	}
+	for src, expected := range map[string][]byte_code.ColValuePair{
+		//the composite index on (person_id, done) is used for both
+		`select id from todo where done == false and person_id == 3 `: {{Col: "person_id", Value: 3}, {Col: "done", Value: false}},
+		`select id from todo where person_id == 3 `:                   {{Col: "person_id", Value: 3}},
+		//done is not the first column of an index
+		`select id from todo where done == false `: nil,
+	} {
+		if actual := index_by(src); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%s: expected %#v but got %#v", src, expected, actual)
+		}
+	}
+}
+
+func Test_choose_access_path(t *testing.T) {
+	db_tables.Tables.Add("shipment", db_tables.NewTable("shipment", rowType.RowSchema{
+		{Name: "status", Type: rowType.String}, {Name: "region", Type: rowType.Int}, {Name: "weight", Type: rowType.Int}, {Name: "id", Type: rowType.Int},
+	}))
+	shipments := db_tables.Tables.Get("shipment")
+	shipments.Index_on("status")
+	shipments.Index_on("region")
+	shipments.Index_on("weight", pubsub.Ordered)
+	//2 statuses, 50 regions and 100 weights
+	for id := range 100 {
+		status := "open"
+		if id%2 == 1 {
+			status = "closed"
+		}
+		shipments.Insert(rowType.RowType{status, id % 50, id, id})
+	}
+
+	access_path := func(src string) byte_code.Access_path {
+		parser := parser.Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+		select_ := parser.Parse_Select()
+		select_.Recursively_link_children()
+		Recursively_set_selects_row_schema(&select_)
+		return Make_select_byte_code(&select_).Access_path
+	}
+	for src, expected := range map[string]byte_code.Access_path{
+		`select id from shipment where status == "open" and region == 3 `: {Kind: byte_code.Hash_channel, Cols_and_values: []byte_code.ColValuePair{{Col: "region", Value: 3}}, Estimated_rows: 2},
+		`select id from shipment where status == "open" and weight < 5 `: {
+			Kind: byte_code.Range_index, Range: unwrap.Some(byte_code.Index_range{Col: "weight", Low: unwrap.None[byte_code.Index_bound](), High: unwrap.Some(byte_code.Index_bound{Value: 5})}), Estimated_rows: 5,
+		},
+		`select id from shipment where status == "open" and weight >= 10 `: {Kind: byte_code.Hash_channel, Cols_and_values: []byte_code.ColValuePair{{Col: "status", Value: "open"}}, Estimated_rows: 50},
+		`select id from shipment `: {Kind: byte_code.Full_table, Estimated_rows: 100},
+	} {
+		if actual := access_path(src); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%s: expected %#v but got %#v", src, expected, actual)
+		}
+	}
+	//95 rows are delivered and 5 are lost, so the channel of a rare status beats the average region (of 10 rows) while a common one doesn't
+	db_tables.Tables.Add("parcel", db_tables.NewTable("parcel", rowType.RowSchema{
+		{Name: "status", Type: rowType.String}, {Name: "region", Type: rowType.Int}, {Name: "id", Type: rowType.Int},
+	}))
+	parcels := db_tables.Tables.Get("parcel")
+	parcels.Index_on("status")
+	parcels.Index_on("region")
+	for id := range 100 {
+		status := "delivered"
+		if id < 5 {
+			status = "lost"
+		}
+		parcels.Insert(rowType.RowType{status, id % 10, id})
+	}
+	for src, expected := range map[string]byte_code.Access_path{
+		`select id from parcel where status == "lost" and region == 3 `:      {Kind: byte_code.Hash_channel, Cols_and_values: []byte_code.ColValuePair{{Col: "status", Value: "lost"}}, Estimated_rows: 5},
+		`select id from parcel where status == "delivered" and region == 3 `: {Kind: byte_code.Hash_channel, Cols_and_values: []byte_code.ColValuePair{{Col: "region", Value: 3}}, Estimated_rows: 10},
+	} {
+		if actual := access_path(src); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%s: expected %#v but got %#v", src, expected, actual)
+		}
+	}
+}
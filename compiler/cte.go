@@ -0,0 +1,94 @@
+package compiler
+
+import (
+	"slices"
+	"sql-compiler/compiler/ast"
+	. "sql-compiler/compiler/rowType"
+	"sql-compiler/compiler/state_full_byte_code/byte_code"
+	"sql-compiler/db_tables"
+	"sql-compiler/unwrap"
+)
+
+// each WITH query is only compiled once, even when its read from by more than one select (or by itself, when its recursive)
+var compiled_ctes = map[*ast.Cte]*byte_code.Cte{}
+
+// what a select reads from, a WITH query is a table without rows (or indexes) of its own whose columns are the ones it selects
+func source_table(name string, cte unwrap.Option[*ast.Cte]) *db_tables.Table {
+	if cte.IsNone() {
+		return db_tables.Tables.Get(name)
+	}
+	table := db_tables.NewTable(name, cte_row_schema(cte.Unwrap()))
+	return &table
+}
+
+func reads_from(select_ *ast.Select, cte *ast.Cte) bool {
+	is_cte := func(other unwrap.Option[*ast.Cte]) bool { return other.IsSome() && other.Unwrap() == cte }
+	return is_cte(select_.Cte) || slices.ContainsFunc(select_.Joins, func(join ast.Join) bool { return is_cte(join.Cte) })
+}
+
+// a WITH RECURSIVE query is only recursive when it reads from itself, which it can only do in the select after its UNION
+func is_recursive_cte(cte *ast.Cte) bool {
+	if !cte.Recursive {
+		return false
+	}
+	if reads_from(&cte.Select, cte) {
+		panic("WITH RECURSIVE " + cte.Name + " has to start with rows that don't read from itself")
+	}
+	if !slices.ContainsFunc(cte.Select.Set_operations, func(operation ast.Set_operation) bool { return reads_from(&operation.Select, cte) }) {
+		return false
+	}
+	if len(cte.Select.Set_operations) != 1 || cte.Select.Set_operations[0].Kind != ast.Union {
+		panic("WITH RECURSIVE " + cte.Name + " has to be a select UNIONed with a select that reads from it")
+	}
+	if len(cte.Select.Order_by) > 0 || cte.Select.Limit.IsSome() || cte.Select.Offset.IsSome() || has_nested_select(&cte.Select) {
+		panic("WITH RECURSIVE " + cte.Name + " can not have ORDER BY, LIMIT, OFFSET or nested selects")
+	}
+	return true
+}
+
+// the rows a recursive one starts with decide its columns, so that the select that reads from it knows them when its typed
+func cte_row_schema(cte *ast.Cte) RowSchema {
+	if cte.Row_schema != nil {
+		return cte.Row_schema
+	}
+	cte.Select.Recursively_link_children()
+	if is_recursive_cte(cte) {
+		anchor := cte.Select
+		anchor.Set_operations = nil
+		cte.Row_schema = Recursively_set_selects_row_schema(&anchor)
+	}
+	cte.Row_schema = Recursively_set_selects_row_schema(&cte.Select)
+	return cte.Row_schema
+}
+
+func compile_cte(cte *ast.Cte) *byte_code.Cte {
+	if compiled, ok := compiled_ctes[cte]; ok {
+		return compiled
+	}
+	compiled := &byte_code.Cte{Name: cte.Name, Row_schema: cte_row_schema(cte)}
+	compiled_ctes[cte] = compiled
+	if !is_recursive_cte(cte) {
+		compiled.Select = unkeyed(Make_select_byte_code(&cte.Select))
+		return compiled
+	}
+	anchor := cte.Select
+	anchor.Set_operations = nil
+	compiled.Select = unkeyed(Make_select_byte_code(&anchor))
+
+	operation := &cte.Select.Set_operations[0]
+	step := &operation.Select
+	is_cte := func(other unwrap.Option[*ast.Cte]) bool { return other.IsSome() && other.Unwrap() == cte }
+	if len(step.Joins) != 1 || step.Joins[0].Kind != ast.Inner_join || is_cte(step.Cte) == is_cte(step.Joins[0].Cte) || (step.Cte.IsSome() && step.Joins[0].Cte.IsSome()) ||
+		Is_aggregated(step) || len(step.Group_by) > 0 || step.Distinct || has_nested_select(step) {
+		panic("the select after the UNION of WITH RECURSIVE " + cte.Name + " can only join a table with " + cte.Name + " (with an INNER JOIN), and can not have aggregates, GROUP BY, DISTINCT or nested selects")
+	}
+	recursion := byte_code.Recursion{All: operation.All, Step: unkeyed(Make_select_byte_code(step)), Cte_first: is_cte(step.Cte)}
+	if len(recursion.Step.Semi_joins) > 0 {
+		panic("the select after the UNION of WITH RECURSIVE " + cte.Name + " can not have IN (SELECT ...) or EXISTS")
+	}
+	//where the rows of the WITH query go in the Step is known from Cte_first, pointing at itself would only make the byte code endless
+	recursion.Step.Cte = unwrap.None[*byte_code.Cte]()
+	recursion.Step.Joins[0].Cte = unwrap.None[*byte_code.Cte]()
+	compiled.Recursion = unwrap.Some(recursion)
+	return compiled
+}
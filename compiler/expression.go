@@ -0,0 +1,189 @@
+package compiler
+
+import (
+	"fmt"
+	"sql-compiler/compiler/ast"
+	"sql-compiler/compiler/functions"
+	. "sql-compiler/compiler/rowType"
+	"sql-compiler/compiler/state_full_byte_code/byte_code"
+	"strings"
+)
+
+// compiles the parts of a value that don't depend on where its used (arithmetic, function calls, CAST, CASE and literals),
+// compile_value is used for the values inside it, as what a column (or an aggregate) refers to does depend on that
+func compile_expression(select_ *ast.Select, value any, compile_value func(value any) byte_code.Expression) byte_code.Expression {
+	switch value := value.(type) {
+	case ast.Null:
+		return nil
+	case int, float64, string, bool:
+		return value
+	}
+	value_col_info(select_, value) //so that a value that doesn't fit (like adding a string to a number) fails when compiling
+	switch value := value.(type) {
+	case ast.Arithmetic:
+		return byte_code.Arithmetic{Left: compile_value(value.Left), Operator: string(value.Operator), Right: compile_value(value.Right)}
+	case ast.Func_call:
+		function, _ := functions.Get(value.Name)
+		call := byte_code.Func_call{Name: strings.ToUpper(value.Name), Call: function.Call}
+		for _, arg := range value.Args {
+			call.Args = append(call.Args, compile_value(arg))
+		}
+		return call
+	case ast.Cast:
+		to := value.Type
+		return byte_code.Func_call{
+			Name: "CAST",
+			Call: func(args []any) any { return functions.Cast(args[0], to) },
+			Args: []byte_code.Expression{compile_value(value.Value)},
+		}
+	case ast.Case:
+		case_ := byte_code.Case{}
+		for _, when := range value.Whens {
+			case_.Whens = append(case_.Whens, byte_code.Case_when{Condition: make_bool_expr_byte_code(when.Condition, compile_value), Then: compile_value(when.Then)})
+		}
+		if value.Else != nil {
+			case_.Else = compile_value(value.Else)
+		}
+		return case_
+	default:
+		panic(fmt.Sprintf("%T can not be used as a value", value))
+	}
+}
+
+// the values directly inside a value (like the arguments of a call), the values a CASE compares in its conditions included
+func sub_values(value any) []any {
+	switch value := value.(type) {
+	case ast.Arithmetic:
+		return []any{value.Left, value.Right}
+	case ast.Func_call:
+		return value.Args
+	case ast.Cast:
+		return []any{value.Value}
+	case ast.Case:
+		res := []any{}
+		for _, when := range value.Whens {
+			res = append(res, bool_expr_values(when.Condition)...)
+			res = append(res, when.Then)
+		}
+		if value.Else != nil {
+			res = append(res, value.Else)
+		}
+		return res
+	default:
+		return nil
+	}
+}
+
+func bool_expr_values(expr ast.Bool_expr) []any {
+	switch expr := expr.(type) {
+	case ast.Where:
+		return []any{expr.Value1, expr.Value2}
+	case ast.Is_null:
+		return []any{expr.Value}
+	case ast.In_list:
+		return append([]any{expr.Value}, expr.List...)
+	case ast.In_select:
+		return []any{expr.Value}
+	case ast.Exists:
+		return nil
+	case ast.And_expr:
+		return append(bool_expr_values(expr.Left), bool_expr_values(expr.Right)...)
+	case ast.Or_expr:
+		return append(bool_expr_values(expr.Left), bool_expr_values(expr.Right)...)
+	case ast.Not_expr:
+		return bool_expr_values(expr.Expr)
+	default:
+		panic(fmt.Sprintf("unhandled bool expression %T", expr))
+	}
+}
+
+// the runtime compares an int with a float as floats and reads a string compared with a timestamp as a timestamp,
+// any other values have to be of the same type
+func check_comparison_types(select_ *ast.Select, expr ast.Bool_expr) {
+	switch expr := expr.(type) {
+	case ast.Where:
+		if is_pattern_operator(expr.Operator) {
+			return
+		}
+		_, null1 := expr.Value1.(ast.Null)
+		_, null2 := expr.Value2.(ast.Null)
+		if null1 || null2 {
+			return
+		}
+		type1, type2 := value_type(select_, expr.Value1), value_type(select_, expr.Value2)
+		numbers := (type1 == Int || type1 == Float) && (type2 == Int || type2 == Float)
+		timestamps := (type1 == Timestamp && type2 == String) || (type1 == String && type2 == Timestamp)
+		if type1 != type2 && !numbers && !timestamps {
+			panic(fmt.Sprintf("can not compare a %s with a %s", type1.To_string(0), type2.To_string(0)))
+		}
+	case ast.And_expr:
+		check_comparison_types(select_, expr.Left)
+		check_comparison_types(select_, expr.Right)
+	case ast.Or_expr:
+		check_comparison_types(select_, expr.Left)
+		check_comparison_types(select_, expr.Right)
+	case ast.Not_expr:
+		check_comparison_types(select_, expr.Expr)
+	}
+}
+
+func scalar_call_col_info(select_ *ast.Select, call ast.Func_call) ColInfo {
+	function, ok := functions.Get(call.Name)
+	if !ok {
+		panic("unknown function " + call.Name)
+	}
+	if call.Star {
+		panic(call.Name + " can not take *")
+	}
+	args := make([]ColInfo, len(call.Args))
+	typed_args := []ColInfo{} //of the arguments that aren't NULL
+	for i, arg := range call.Args {
+		if _, is_null := arg.(ast.Null); !is_null {
+			args[i] = value_col_info(select_, arg)
+			typed_args = append(typed_args, args[i])
+		}
+	}
+	for i, arg := range call.Args {
+		if _, is_null := arg.(ast.Null); !is_null {
+			continue
+		}
+		if function.Skips_nulls {
+			args[i] = ColInfo{Type: String, Nullable: true}
+			continue
+		}
+		if !function.Unified_args {
+			panic(call.Name + " can not take NULL as an argument")
+		}
+		if len(typed_args) == 0 {
+			panic(call.Name + " needs at least one argument that isn't NULL")
+		}
+		args[i] = ColInfo{Type: functions.Common_type(call.Name, typed_args), Nullable: true}
+	}
+	return function.Result(strings.ToUpper(call.Name), args)
+}
+
+// every branch has to be of the same type, its null when no condition is true and there is no ELSE
+func case_col_info(select_ *ast.Select, case_ ast.Case) ColInfo {
+	branches := []any{}
+	for _, when := range case_.Whens {
+		branches = append(branches, when.Then)
+	}
+	nullable := case_.Else == nil
+	if !nullable {
+		branches = append(branches, case_.Else)
+	}
+	infos := []ColInfo{}
+	for _, branch := range branches {
+		if _, is_null := branch.(ast.Null); is_null {
+			nullable = true
+			continue
+		}
+		info := value_col_info(select_, branch)
+		nullable = nullable || info.Nullable
+		infos = append(infos, info)
+	}
+	if len(infos) == 0 {
+		panic("CASE needs at least one branch that isn't NULL")
+	}
+	return ColInfo{Type: functions.Common_type("CASE", infos), Nullable: nullable}
+}
@@ -0,0 +1,125 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sql-compiler/compiler/rowType"
+	"sql-compiler/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CAST(value AS type), a cast that can fail for some values (like text that isn't a number) is null for those values instead of stopping the query
+func Cast_result(from rowType.ColInfo, to rowType.DataType) rowType.ColInfo {
+	if from.Type.Is_nested_select() || to.Is_nested_select() {
+		panic("can not cast a list of rows")
+	}
+	can_fail := false
+	switch {
+	case from.Type == to, to == rowType.String:
+	case from.Type == rowType.String:
+		can_fail = true
+	case (from.Type == rowType.Int || from.Type == rowType.Float || from.Type == rowType.Bool) && (to == rowType.Int || to == rowType.Float || to == rowType.Bool):
+	case from.Type == rowType.Json && to != rowType.Timestamp:
+		can_fail = true
+	default:
+		panic(fmt.Sprintf("can not cast a %s to a %s", from.Type.To_string(0), to.To_string(0)))
+	}
+	return rowType.ColInfo{Type: to, Nullable: from.Nullable || can_fail}
+}
+
+func Cast(value any, to rowType.DataType) any {
+	if value == nil {
+		return nil
+	}
+	if document, is_json := value.(json.RawMessage); is_json && to != rowType.Json {
+		//a json string, number or bool is cast from its value
+		var decoded any
+		json.Unmarshal(document, &decoded)
+		switch decoded := decoded.(type) {
+		case string, bool:
+			value = decoded
+		case float64:
+			value = decoded
+			if decoded == math.Trunc(decoded) {
+				value = int(decoded)
+			}
+		default:
+			if to != rowType.String {
+				return nil
+			}
+		}
+	}
+	switch to {
+	case rowType.String:
+		return utils.String_or_num_to_string(value)
+	case rowType.Int:
+		switch value := value.(type) {
+		case int:
+			return value
+		case float64:
+			return int(math.Round(value))
+		case bool:
+			if value {
+				return 1
+			}
+			return 0
+		case string:
+			if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				return n
+			}
+		}
+	case rowType.Float:
+		switch value := value.(type) {
+		case int:
+			return float64(value)
+		case float64:
+			return value
+		case bool:
+			if value {
+				return 1.0
+			}
+			return 0.0
+		case string:
+			if n, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				return n
+			}
+		}
+	case rowType.Bool:
+		switch value := value.(type) {
+		case int:
+			return value != 0
+		case float64:
+			return value != 0
+		case bool:
+			return value
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+				return b
+			}
+		}
+	case rowType.Timestamp:
+		switch value := value.(type) {
+		case time.Time:
+			return value
+		case string:
+			for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+				if timestamp, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+					return timestamp
+				}
+			}
+		}
+	case rowType.Json:
+		switch value := value.(type) {
+		case json.RawMessage:
+			return value
+		case string:
+			if json.Valid([]byte(value)) {
+				return json.RawMessage(value)
+			}
+		}
+	}
+	return nil
+}
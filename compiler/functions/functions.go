@@ -0,0 +1,222 @@
+// the scalar functions that can be called in a query, like LOWER(name) or ROUND(price, 2) (as opposed to aggregate functions, which fold many rows into one value)
+// each one knows what it returns given its arguments (so calls are checked when the query is compiled) and how to compute its value for a row
+package functions
+
+import (
+	"fmt"
+	"math"
+	"sql-compiler/compiler/rowType"
+	"sql-compiler/utils"
+	"strings"
+	"unicode/utf8"
+)
+
+type Function struct {
+	// the type of the result (and whether it can be null) given the arguments, panics when the arguments don't fit
+	Result       func(name string, args []rowType.ColInfo) rowType.ColInfo
+	Call         func(args []any) any
+	Unified_args bool //the arguments are all of one type, so a NULL argument is taken to be of the type of the others
+	Skips_nulls  bool //null arguments are left out (like in CONCAT), so a NULL argument is fine whatever type it is
+}
+
+// by the upper case name
+var functions = map[string]Function{
+	"LOWER":  strict([]rowType.DataType{rowType.String}, rowType.String, func(args []any) any { return strings.ToLower(args[0].(string)) }),
+	"UPPER":  strict([]rowType.DataType{rowType.String}, rowType.String, func(args []any) any { return strings.ToUpper(args[0].(string)) }),
+	"TRIM":   strict([]rowType.DataType{rowType.String}, rowType.String, func(args []any) any { return strings.TrimSpace(args[0].(string)) }),
+	"LENGTH": strict([]rowType.DataType{rowType.String}, rowType.Int, func(args []any) any { return utf8.RuneCountInString(args[0].(string)) }),
+	"SUBSTR": {Result: substr_result, Call: substr},
+	"CONCAT": {Result: concat_result, Skips_nulls: true, Call: concat},
+	"ABS":    {Result: abs_result, Call: abs},
+	"ROUND":  {Result: round_result, Call: round},
+	"COALESCE": {Result: coalesce_result, Unified_args: true, Call: func(args []any) any {
+		for _, arg := range args {
+			if arg != nil {
+				return arg
+			}
+		}
+		return nil
+	}},
+	"NULLIF": {Result: nullif_result, Unified_args: true, Call: func(args []any) any {
+		if args[0] != nil && args[1] != nil && utils.CompareValues(args[0], args[1]) == 0 {
+			return nil
+		}
+		return args[0]
+	}},
+}
+
+func Get(name string) (Function, bool) {
+	function, ok := functions[strings.ToUpper(name)]
+	return function, ok
+}
+
+// ///
+
+// a function that takes exactly the given types (an int is fine where a float is wanted) and that is null when any of its arguments are, without being called
+func strict(arg_types []rowType.DataType, return_type rowType.DataType, call func(args []any) any) Function {
+	return Function{
+		Result: func(name string, args []rowType.ColInfo) rowType.ColInfo {
+			expect_arg_count(name, args, len(arg_types), len(arg_types))
+			for i := range args {
+				expect_type(name, i, args[i], arg_types[i])
+			}
+			return rowType.ColInfo{Type: return_type, Nullable: any_nullable(args)}
+		},
+		Call: skip_nulls(call),
+	}
+}
+
+func skip_nulls(call func(args []any) any) func(args []any) any {
+	return func(args []any) any {
+		for _, arg := range args {
+			if arg == nil {
+				return nil
+			}
+		}
+		return call(args)
+	}
+}
+
+func expect_arg_count(name string, args []rowType.ColInfo, min_count int, max_count int) {
+	if len(args) < min_count || len(args) > max_count {
+		if min_count == max_count {
+			panic(fmt.Sprintf("%s takes %d arguments but got %d", name, min_count, len(args)))
+		}
+		panic(fmt.Sprintf("%s takes %d to %d arguments but got %d", name, min_count, max_count, len(args)))
+	}
+}
+
+func expect_type(name string, index int, arg rowType.ColInfo, type_ rowType.DataType) {
+	if arg.Type == type_ || (arg.Type == rowType.Int && type_ == rowType.Float) {
+		return
+	}
+	panic(fmt.Sprintf("argument %d of %s has to be a %s but got a %s", index+1, name, type_.To_string(0), arg.Type.To_string(0)))
+}
+
+func expect_numeric(name string, index int, arg rowType.ColInfo) {
+	if arg.Type != rowType.Int && arg.Type != rowType.Float {
+		panic(fmt.Sprintf("argument %d of %s has to be a number but got a %s", index+1, name, arg.Type.To_string(0)))
+	}
+}
+
+func any_nullable(args []rowType.ColInfo) bool {
+	for _, arg := range args {
+		if arg.Nullable {
+			return true
+		}
+	}
+	return false
+}
+
+// the type that all the values can be, ints and floats together are floats
+func Common_type(name string, args []rowType.ColInfo) rowType.DataType {
+	type_ := args[0].Type
+	for _, arg := range args[1:] {
+		switch {
+		case arg.Type == type_:
+		case (arg.Type == rowType.Int || arg.Type == rowType.Float) && (type_ == rowType.Int || type_ == rowType.Float):
+			type_ = rowType.Float
+		default:
+			panic(fmt.Sprintf("the values of %s have to be of the same type but got a %s and a %s", name, type_.To_string(0), arg.Type.To_string(0)))
+		}
+	}
+	return type_
+}
+
+// ///
+
+// SUBSTR(text, start) or SUBSTR(text, start, length), start counts from 1
+func substr_result(name string, args []rowType.ColInfo) rowType.ColInfo {
+	expect_arg_count(name, args, 2, 3)
+	expect_type(name, 0, args[0], rowType.String)
+	for i := 1; i < len(args); i++ {
+		expect_type(name, i, args[i], rowType.Int)
+	}
+	return rowType.ColInfo{Type: rowType.String, Nullable: any_nullable(args)}
+}
+
+var substr = skip_nulls(func(args []any) any {
+	runes := []rune(args[0].(string))
+	start := min(max(args[1].(int)-1, 0), len(runes))
+	end := len(runes)
+	if len(args) == 3 {
+		end = min(max(args[1].(int)-1+args[2].(int), start), len(runes))
+	}
+	return string(runes[start:end])
+})
+
+// every argument written as text one after the other, nulls are skipped (so its never null)
+func concat_result(name string, args []rowType.ColInfo) rowType.ColInfo {
+	expect_arg_count(name, args, 1, math.MaxInt)
+	for i := range args {
+		if args[i].Type.Is_nested_select() {
+			panic(fmt.Sprintf("argument %d of %s can not be a list of rows", i+1, name))
+		}
+	}
+	return rowType.ColInfo{Type: rowType.String}
+}
+
+func concat(args []any) any {
+	var res strings.Builder
+	for _, arg := range args {
+		if arg != nil {
+			res.WriteString(utils.String_or_num_to_string(arg))
+		}
+	}
+	return res.String()
+}
+
+func abs_result(name string, args []rowType.ColInfo) rowType.ColInfo {
+	expect_arg_count(name, args, 1, 1)
+	expect_numeric(name, 0, args[0])
+	return args[0]
+}
+
+var abs = skip_nulls(func(args []any) any {
+	switch value := args[0].(type) {
+	case int:
+		return max(value, -value)
+	default:
+		return math.Abs(value.(float64))
+	}
+})
+
+// ROUND(number) or ROUND(number, digits), rounding an int leaves it as it is
+func round_result(name string, args []rowType.ColInfo) rowType.ColInfo {
+	expect_arg_count(name, args, 1, 2)
+	expect_numeric(name, 0, args[0])
+	if len(args) == 2 {
+		expect_type(name, 1, args[1], rowType.Int)
+	}
+	return rowType.ColInfo{Type: args[0].Type, Nullable: any_nullable(args)}
+}
+
+var round = skip_nulls(func(args []any) any {
+	value, is_float := args[0].(float64)
+	if !is_float {
+		return args[0]
+	}
+	digits := 0
+	if len(args) == 2 {
+		digits = args[1].(int)
+	}
+	scale := math.Pow(10, float64(digits))
+	return math.Round(value*scale) / scale
+})
+
+// the first argument that isn't null, so its only null when every argument can be
+func coalesce_result(name string, args []rowType.ColInfo) rowType.ColInfo {
+	expect_arg_count(name, args, 1, math.MaxInt)
+	nullable := true
+	for _, arg := range args {
+		nullable = nullable && arg.Nullable
+	}
+	return rowType.ColInfo{Type: Common_type(name, args), Nullable: nullable}
+}
+
+// null when both arguments are equal, otherwise the first one
+func nullif_result(name string, args []rowType.ColInfo) rowType.ColInfo {
+	expect_arg_count(name, args, 2, 2)
+	Common_type(name, args)
+	return rowType.ColInfo{Type: args[0].Type, Nullable: true}
+}
@@ -0,0 +1,140 @@
+package functions
+
+import (
+	"encoding/json"
+	"sql-compiler/compiler/rowType"
+	"strings"
+	"testing"
+)
+
+func TestCalls(t *testing.T) {
+	call := func(name string, args ...any) any {
+		function, ok := Get(name)
+		if !ok {
+			t.Fatalf("no function %s", name)
+		}
+		return function.Call(args)
+	}
+	cases := []struct {
+		got      any
+		expected any
+	}{
+		{call("substr", "hello", 2, 3), "ell"},
+		{call("substr", "hello", 4), "lo"},
+		{call("substr", "hello", 0, 2), "h"},
+		{call("substr", "hello", 9), ""},
+		{call("LENGTH", "héllo"), 5},
+		{call("concat", "a", nil, 1, true), "a1true"},
+		{call("abs", -3), 3},
+		{call("round", 2.345, 2), 2.35},
+		{call("round", 7), 7},
+		{call("lower", nil), nil},
+		{call("coalesce", nil, nil, "c"), "c"},
+		{call("nullif", 1, 1.0), nil},
+		{call("nullif", 1, 2), 1},
+	}
+	for i, c := range cases {
+		if c.got != c.expected {
+			t.Errorf("case %d: expected %#v but got %#v", i, c.expected, c.got)
+		}
+	}
+}
+
+func TestCast(t *testing.T) {
+	cases := []struct {
+		value    any
+		to       rowType.DataType
+		expected any
+	}{
+		{" 42 ", rowType.Int, 42},
+		{"forty two", rowType.Int, nil},
+		{2.5, rowType.String, "2.5"},
+		{2.6, rowType.Int, 3},
+		{"true", rowType.Bool, true},
+		{0, rowType.Bool, false},
+		{json.RawMessage(`12`), rowType.Int, 12},
+		{json.RawMessage(`"text"`), rowType.String, "text"},
+		{json.RawMessage(`{"a":1}`), rowType.Int, nil},
+	}
+	for i, c := range cases {
+		if got := Cast(c.value, c.to); got != c.expected {
+			t.Errorf("case %d: expected %#v but got %#v", i, c.expected, got)
+		}
+	}
+}
+
+func TestResultTypes(t *testing.T) {
+	result := func(name string, args ...rowType.ColInfo) rowType.ColInfo {
+		function, _ := Get(name)
+		return function.Result(name, args)
+	}
+	if got := result("COALESCE", rowType.ColInfo{Type: rowType.Int, Nullable: true}, rowType.ColInfo{Type: rowType.Float}); got != (rowType.ColInfo{Type: rowType.Float}) {
+		t.Errorf("COALESCE of a nullable int and a float should be a float that isn't null, got %#v", got)
+	}
+	if got := result("LOWER", rowType.ColInfo{Type: rowType.String, Nullable: true}); got != (rowType.ColInfo{Type: rowType.String, Nullable: true}) {
+		t.Errorf("LOWER of a nullable string should be a nullable string, got %#v", got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("LOWER of an int should not compile")
+		}
+	}()
+	result("LOWER", rowType.ColInfo{Type: rowType.Int})
+}
+
+func TestRegisterGoFunction(t *testing.T) {
+	Register_go_function("test_repeat", func(text string, times int) string { return strings.Repeat(text, times) })
+	Register_go_function("test_or_default", func(text *string) string {
+		if text == nil {
+			return "default"
+		}
+		return *text
+	})
+	Register_go_function("test_half", func(n float64) *float64 {
+		if n == 0 {
+			return nil
+		}
+		half := n / 2
+		return &half
+	})
+	call := func(name string, args ...any) any {
+		function, _ := Get(name)
+		return function.Call(args)
+	}
+	cases := []struct {
+		got      any
+		expected any
+	}{
+		{call("TEST_REPEAT", "ab", 2), "abab"},
+		{call("test_repeat", nil, 2), nil},
+		{call("test_or_default", nil), "default"},
+		{call("test_or_default", "x"), "x"},
+		{call("test_half", 3), 1.5},
+		{call("test_half", 0), nil},
+	}
+	for i, c := range cases {
+		if c.got != c.expected {
+			t.Errorf("case %d: expected %#v but got %#v", i, c.expected, c.got)
+		}
+	}
+
+	result := func(name string, args ...rowType.ColInfo) rowType.ColInfo {
+		function, _ := Get(name)
+		return function.Result(name, args)
+	}
+	if got := result("test_repeat", rowType.ColInfo{Type: rowType.String, Nullable: true}, rowType.ColInfo{Type: rowType.Int}); got != (rowType.ColInfo{Type: rowType.String, Nullable: true}) {
+		t.Errorf("test_repeat of a nullable string should be nullable, got %#v", got)
+	}
+	if got := result("test_or_default", rowType.ColInfo{Type: rowType.String, Nullable: true}); got != (rowType.ColInfo{Type: rowType.String}) {
+		t.Errorf("test_or_default takes a pointer so it should not be nullable, got %#v", got)
+	}
+	if got := result("test_half", rowType.ColInfo{Type: rowType.Int}); got != (rowType.ColInfo{Type: rowType.Float, Nullable: true}) {
+		t.Errorf("test_half returns a pointer so it should be nullable, got %#v", got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("test_repeat with its arguments swapped should not compile")
+		}
+	}()
+	result("test_repeat", rowType.ColInfo{Type: rowType.Int}, rowType.ColInfo{Type: rowType.String})
+}
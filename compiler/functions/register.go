@@ -0,0 +1,105 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sql-compiler/compiler/rowType"
+	"strings"
+	"time"
+)
+
+// the go types that the values of each type are stored as
+var go_types = map[reflect.Type]rowType.DataType{
+	reflect.TypeFor[string]():          rowType.String,
+	reflect.TypeFor[int]():             rowType.Int,
+	reflect.TypeFor[float64]():         rowType.Float,
+	reflect.TypeFor[bool]():            rowType.Bool,
+	reflect.TypeFor[time.Time]():       rowType.Timestamp,
+	reflect.TypeFor[json.RawMessage](): rowType.Json,
+}
+
+// makes a function callable from queries, the name can't be taken by another function already
+func Register(name string, function Function) {
+	name = strings.ToUpper(name)
+	if _, exists := functions[name]; exists {
+		panic("there is already a function called " + name)
+	}
+	functions[name] = function
+}
+
+// registers a go function (like func(title string) string) whose signature says what types it takes and returns.
+// a call is null without the function being called when any argument that isn't a pointer is null, a pointer argument gets nil for null instead,
+// and a function that returns a pointer can return nil for null
+func Register_go_function(name string, go_function any) {
+	function_value := reflect.ValueOf(go_function)
+	function_type := function_value.Type()
+	if function_type.Kind() != reflect.Func || function_type.NumOut() != 1 || function_type.IsVariadic() {
+		panic(fmt.Sprintf("%s has to be a function that returns one value but got a %s", name, function_type))
+	}
+	arg_types := []rowType.DataType{}
+	strict := true
+	for i := range function_type.NumIn() {
+		type_, is_pointer := data_type_of(name, function_type.In(i))
+		arg_types = append(arg_types, type_)
+		strict = strict && !is_pointer
+	}
+	return_type, return_nullable := data_type_of(name, function_type.Out(0))
+
+	call := func(args []any) any {
+		in := make([]reflect.Value, len(args))
+		for i := range args {
+			in[i] = to_go_value(args[i], function_type.In(i))
+		}
+		out := function_value.Call(in)[0]
+		if out.Kind() == reflect.Pointer {
+			if out.IsNil() {
+				return nil
+			}
+			out = out.Elem()
+		}
+		return out.Interface()
+	}
+	if strict {
+		call = skip_nulls(call)
+	}
+	Register(name, Function{
+		Result: func(name string, args []rowType.ColInfo) rowType.ColInfo {
+			expect_arg_count(name, args, len(arg_types), len(arg_types))
+			nullable := return_nullable
+			for i := range args {
+				expect_type(name, i, args[i], arg_types[i])
+				nullable = nullable || (strict && args[i].Nullable)
+			}
+			return rowType.ColInfo{Type: return_type, Nullable: nullable}
+		},
+		Call: call,
+	})
+}
+
+func data_type_of(function_name string, go_type reflect.Type) (rowType.DataType, bool) {
+	is_pointer := go_type.Kind() == reflect.Pointer
+	if is_pointer {
+		go_type = go_type.Elem()
+	}
+	type_, ok := go_types[go_type]
+	if !ok {
+		panic(fmt.Sprintf("%s uses a %s, which is not one of the types a value can be", function_name, go_type))
+	}
+	return type_, is_pointer
+}
+
+func to_go_value(value any, go_type reflect.Type) reflect.Value {
+	if go_type.Kind() == reflect.Pointer {
+		if value == nil {
+			return reflect.Zero(go_type)
+		}
+		pointer := reflect.New(go_type.Elem())
+		pointer.Elem().Set(to_go_value(value, go_type.Elem()))
+		return pointer
+	}
+	if n, is_int := value.(int); is_int && go_type.Kind() == reflect.Float64 { //an int can be passed where a float is wanted
+		return reflect.ValueOf(float64(n))
+	}
+	return reflect.ValueOf(value)
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"sql-compiler/compiler/ast"
 	. "sql-compiler/compiler/parser/tokenizer"
+	"sql-compiler/compiler/rowType"
 	"sql-compiler/unwrap"
 	"strconv"
+	"strings"
 )
 
 type Parser struct {
@@ -27,6 +29,17 @@ func (p *Parser) expectIdent() string {
 	p.pos++
 	return ident
 }
+func (p *Parser) expectInt() int {
+	if p.Tokens[p.pos].Type != INT {
+		panic(fmt.Sprintf("expected INT but got %s at %d", p.Tokens[p.pos].Type, p.Tokens[p.pos].Pos))
+	}
+	n, err := strconv.Atoi(p.Tokens[p.pos].Literal)
+	if err != nil {
+		panic(err)
+	}
+	p.pos++
+	return n
+}
 func (p *Parser) expectIdentOf(ident string) {
 	if p.Tokens[p.pos].Type != IDENT {
 		panic("expected IDENT")
@@ -59,6 +72,9 @@ func (p *Parser) parse_col_or_expr_lit() any {
 	if token.Type == TRUE || token.Type == FALSE {
 		return token.Type == TRUE
 	}
+	if token.Type == NULL {
+		return ast.Null{}
+	}
 	if token.Type == INT {
 		n, err := strconv.Atoi(token.Literal)
 		if err != nil {
@@ -66,23 +82,267 @@ func (p *Parser) parse_col_or_expr_lit() any {
 		}
 		return n
 	}
+	if token.Type == FLOAT {
+		n, err := strconv.ParseFloat(token.Literal, 64)
+		if err != nil {
+			panic(err)
+		}
+		return n
+	}
+	if token.Type == CAST {
+		return p.parse_cast()
+	}
+	if token.Type == CASE {
+		return p.parse_case()
+	}
+	if token.Type == IDENT && p.inrange() && p.Tokens[p.pos].Type == LPAREN {
+		p.pos++
+		return p.parse_func_call_args(token.Literal)
+	}
 	p.pos = walk_back_pos
 	return p.parseCol()
 }
-func (p *Parser) parse_simple_expr() ast.Where {
-	Value1 := p.parse_col_or_expr_lit()
+
+// * and / bind tighter than + and -, and all of them are left associative, so "a - b * c - d" is "(a - (b * c)) - d"
+func (p *Parser) parse_value_expr() any {
+	left := p.parse_term()
+	for p.inrange() && (p.Tokens[p.pos].Type == PLUS || p.Tokens[p.pos].Type == MINUS) {
+		operator := p.Tokens[p.pos].Type
+		p.pos++
+		left = ast.Arithmetic{Left: left, Operator: operator, Right: p.parse_term()}
+	}
+	return left
+}
+func (p *Parser) parse_term() any {
+	left := p.parse_factor()
+	for p.inrange() && (p.Tokens[p.pos].Type == ASTERISK || p.Tokens[p.pos].Type == SLASH) {
+		operator := p.Tokens[p.pos].Type
+		p.pos++
+		left = ast.Arithmetic{Left: left, Operator: operator, Right: p.parse_factor()}
+	}
+	return left
+}
+
+// a negative number literal is just the number, the negation of anything else is subtracting it from 0
+func (p *Parser) parse_factor() any {
+	if p.optionallyExpect(MINUS) {
+		switch value := p.parse_factor().(type) {
+		case int:
+			return -value
+		case float64:
+			return -value
+		default:
+			return ast.Arithmetic{Left: 0, Operator: MINUS, Right: value}
+		}
+	}
+	if p.optionallyExpect(LPAREN) {
+		value := p.parse_value_expr()
+		p.expect(RPAREN)
+		return value
+	}
+	return p.parse_col_or_expr_lit()
+}
+
+// whether the parenthesis at the current position wraps a value (like "(age + 1) > 2") rather than a bool expression (like "(a == 1 OR b == 2)"),
+// which is the case when what comes after its closing parenthesis can only follow a value
+func (p *Parser) paren_wraps_value() bool {
+	depth := 0
+	for i := p.pos; i < len(p.Tokens); i++ {
+		switch p.Tokens[i].Type {
+		case LPAREN:
+			depth++
+		case RPAREN:
+			depth--
+			if depth == 0 {
+				if i+1 >= len(p.Tokens) {
+					return false
+				}
+				switch p.Tokens[i+1].Type {
+				case LT, GT, EQ, NEQ, ASSIGN, LE, GE, BETWEEN, IS, IN, NOT, LIKE, ILIKE, REGEX, PLUS, MINUS, ASTERISK, SLASH:
+					return true
+				}
+				return false
+			}
+		}
+	}
+	return false
+}
+
+// the names a type can go by in CAST
+var type_names = map[string]rowType.DataType{
+	"TEXT":      rowType.String,
+	"STRING":    rowType.String,
+	"VARCHAR":   rowType.String,
+	"INT":       rowType.Int,
+	"INTEGER":   rowType.Int,
+	"FLOAT":     rowType.Float,
+	"REAL":      rowType.Float,
+	"DOUBLE":    rowType.Float,
+	"BOOL":      rowType.Bool,
+	"BOOLEAN":   rowType.Bool,
+	"TIMESTAMP": rowType.Timestamp,
+	"JSON":      rowType.Json,
+}
+
+func (p *Parser) parse_cast() ast.Cast {
+	p.expect(LPAREN)
+	value := p.parse_value_expr()
+	p.expect(AS)
+	type_name := p.expectIdent()
+	type_, ok := type_names[strings.ToUpper(type_name)]
+	if !ok {
+		panic("unknown type " + type_name)
+	}
+	p.expect(RPAREN)
+	return ast.Cast{Value: value, Type: type_}
+}
+
+func (p *Parser) parse_case() ast.Case {
+	case_ := ast.Case{}
+	for p.optionallyExpect(WHEN) {
+		when := ast.Case_when{Condition: p.parse_or_expr()}
+		p.expect(THEN)
+		when.Then = p.parse_value_expr()
+		case_.Whens = append(case_.Whens, when)
+	}
+	if len(case_.Whens) == 0 {
+		panic("CASE needs at least one WHEN")
+	}
+	if p.optionallyExpect(ELSE) {
+		case_.Else = p.parse_value_expr()
+	}
+	p.expect(END)
+	return case_
+}
+
+func (p *Parser) parse_func_call_args(name string) ast.Func_call {
+	call := ast.Func_call{Name: name}
+	if p.optionallyExpect(ASTERISK) {
+		call.Star = true
+		p.expect(RPAREN)
+		return call
+	}
+	for !p.optionallyExpect(RPAREN) {
+		call.Args = append(call.Args, p.parse_value_expr())
+		if !p.optionallyExpect(COMMA) {
+			p.expect(RPAREN)
+			break
+		}
+	}
+	return call
+}
+func (p *Parser) parse_simple_expr() ast.Bool_expr {
+	if p.optionallyExpect(EXISTS) {
+		return ast.Exists{Select: p.parse_parenthesized_select()}
+	}
+	Value1 := p.parse_value_expr()
+	if p.optionallyExpect(IS) {
+		negated := p.optionallyExpect(NOT)
+		p.expect(NULL)
+		return ast.Is_null{Value: Value1, Negated: negated}
+	}
+	if p.pos+1 < len(p.Tokens) && p.Tokens[p.pos].Type == NOT && p.Tokens[p.pos+1].Type == IN {
+		p.pos++
+		return p.parse_in(Value1, true)
+	}
+	if p.inrange() && p.Tokens[p.pos].Type == IN {
+		return p.parse_in(Value1, false)
+	}
+	//"a NOT LIKE b" is "NOT a LIKE b", and the same for ILIKE and BETWEEN
+	if p.pos+1 < len(p.Tokens) && p.Tokens[p.pos].Type == NOT && (p.Tokens[p.pos+1].Type == LIKE || p.Tokens[p.pos+1].Type == ILIKE || p.Tokens[p.pos+1].Type == BETWEEN) {
+		p.pos++
+		return ast.Not_expr{Expr: p.parse_comparison(Value1)}
+	}
+	return p.parse_comparison(Value1)
+}
+
+// "a BETWEEN b AND c" is "a >= b AND a <= c", the AND is part of the BETWEEN so the bounds are parsed as values (which never contain an AND)
+func (p *Parser) parse_between(value any) ast.Bool_expr {
+	p.expect(BETWEEN)
+	low := p.parse_value_expr()
+	p.expect(AND)
+	high := p.parse_value_expr()
+	return ast.And_expr{
+		Left:  ast.Where{Value1: value, Operator: GE, Value2: low},
+		Right: ast.Where{Value1: value, Operator: LE, Value2: high},
+	}
+}
+
+// the comparison (or pattern match) of value1 with the value that comes after the operator
+func (p *Parser) parse_comparison(Value1 any) ast.Bool_expr {
 	operator := p.Tokens[p.pos].Type
-	if operator != LT && operator != GT && operator != EQ && operator != LE && operator != GE {
-		panic("expected ASSIGN or LT or GT or LE or GE instead of " + string(operator))
+	if operator == BETWEEN {
+		return p.parse_between(Value1)
+	}
+	if operator == ASSIGN {
+		//a single = is also equality, as there is no assignment in a condition
+		operator = EQ
+	}
+	if operator != LT && operator != GT && operator != EQ && operator != NEQ && operator != LE && operator != GE && operator != LIKE && operator != ILIKE && operator != REGEX {
+		panic("expected ASSIGN or EQ or NEQ or LT or GT or LE or GE or LIKE or ILIKE or ~ or BETWEEN instead of " + string(operator))
 	}
 	p.pos++
 
 	return ast.Where{
 		Value1:   Value1,
 		Operator: operator,
-		Value2:   p.parse_col_or_expr_lit(),
+		Value2:   p.parse_value_expr(),
 	}
 }
+
+// what comes after IN, either a select or a list of values
+func (p *Parser) parse_in(value any, negated bool) ast.Bool_expr {
+	p.expect(IN)
+	if p.pos+1 < len(p.Tokens) && p.Tokens[p.pos].Type == LPAREN && p.Tokens[p.pos+1].Type == SELECT {
+		return ast.In_select{Value: value, Select: p.parse_parenthesized_select(), Negated: negated}
+	}
+	in := ast.In_list{Value: value, Negated: negated}
+	p.expect(LPAREN)
+	for {
+		in.List = append(in.List, p.parse_value_expr())
+		if !p.optionallyExpect(COMMA) {
+			break
+		}
+	}
+	p.expect(RPAREN)
+	return in
+}
+
+func (p *Parser) parse_parenthesized_select() ast.Select {
+	p.expect(LPAREN)
+	p.expect(SELECT)
+	select_ := p.Parse_Select()
+	p.expect(RPAREN)
+	return select_
+}
+
+// OR binds the loosest, then AND, then NOT, so "a OR b AND NOT c" is "a OR (b AND (NOT c))"
+func (p *Parser) parse_or_expr() ast.Bool_expr {
+	left := p.parse_and_expr()
+	for p.optionallyExpect(OR) {
+		left = ast.Or_expr{Left: left, Right: p.parse_and_expr()}
+	}
+	return left
+}
+func (p *Parser) parse_and_expr() ast.Bool_expr {
+	left := p.parse_not_expr()
+	for p.optionallyExpect(AND) {
+		left = ast.And_expr{Left: left, Right: p.parse_not_expr()}
+	}
+	return left
+}
+func (p *Parser) parse_not_expr() ast.Bool_expr {
+	if p.optionallyExpect(NOT) {
+		return ast.Not_expr{Expr: p.parse_not_expr()}
+	}
+	if p.inrange() && p.Tokens[p.pos].Type == LPAREN && !p.paren_wraps_value() {
+		p.pos++
+		expr := p.parse_or_expr()
+		p.expect(RPAREN)
+		return expr
+	}
+	return p.parse_simple_expr()
+}
 func (p *Parser) parseCol() ast.Col {
 	col_or_table_name := p.expectIdent()
 	if p.optionallyExpect(DOT) {
@@ -93,18 +353,152 @@ func (p *Parser) parseCol() ast.Col {
 	}
 	return ast.Plain_col_name(col_or_table_name)
 }
+
+// "person AS p" or just "person p"
+// * or table.* in the selected values, which are only known to be columns once the tables are
+func (p *Parser) parse_star() (ast.Star, bool) {
+	if p.optionallyExpect(ASTERISK) {
+		return ast.Star{}, true
+	}
+	if p.pos+2 < len(p.Tokens) && p.Tokens[p.pos].Type == IDENT && p.Tokens[p.pos+1].Type == DOT && p.Tokens[p.pos+2].Type == ASTERISK {
+		star := ast.Star{Table_name: p.Tokens[p.pos].Literal}
+		p.pos += 3
+		return star, true
+	}
+	return ast.Star{}, false
+}
+
+func (p *Parser) parse_table_alias() string {
+	if p.optionallyExpect(AS) || (p.inrange() && p.Tokens[p.pos].Type == IDENT) {
+		return p.expectIdent()
+	}
+	return ""
+}
+
+// JOIN on its own is an INNER JOIN, and OUTER is optional after LEFT, RIGHT and FULL
+func (p *Parser) parse_join_kind() (ast.Join_kind, bool) {
+	kind := ast.Inner_join
+	switch {
+	case p.optionallyExpect(INNER):
+	case p.optionallyExpect(LEFT):
+		kind = ast.Left_join
+		p.optionallyExpect(OUTER)
+	case p.optionallyExpect(RIGHT):
+		kind = ast.Right_join
+		p.optionallyExpect(OUTER)
+	case p.optionallyExpect(FULL):
+		kind = ast.Full_join
+		p.optionallyExpect(OUTER)
+	case p.inrange() && p.Tokens[p.pos].Type == JOIN:
+	default:
+		return "", false
+	}
+	p.expect(JOIN)
+	return kind, true
+}
+
 func (p *Parser) Parse_Select() ast.Select {
+	with := p.parse_with()
+	s := p.parse_select_core()
+	s.With = with
+	for {
+		kind, is_set_operation := p.parse_set_operation_kind()
+		if !is_set_operation {
+			break
+		}
+		operation := ast.Set_operation{Kind: kind, All: p.optionallyExpect(ALL)}
+		p.expect(SELECT)
+		operation.Select = p.parse_select_core()
+		s.Set_operations = append(s.Set_operations, operation)
+	}
+	if p.optionallyExpect(ORDER) {
+		p.expect(BY)
+		for {
+			item := ast.Order_by_item{Col: p.parseCol()}
+			if p.optionallyExpect(DESC) {
+				item.Desc = true
+			} else {
+				p.optionallyExpect(ASC)
+			}
+			s.Order_by = append(s.Order_by, item)
+			if !p.optionallyExpect(COMMA) {
+				break
+			}
+		}
+	}
+	if p.optionallyExpect(LIMIT) {
+		s.Limit = unwrap.Some(p.expectInt())
+	}
+	if p.optionallyExpect(OFFSET) {
+		s.Offset = unwrap.Some(p.expectInt())
+	}
+
+	return s
+}
+
+// "WITH [RECURSIVE] name AS (SELECT ...), ..." before a select
+func (p *Parser) parse_with() []ast.Cte {
+	if !p.optionallyExpect(WITH) {
+		return nil
+	}
+	recursive := p.optionallyExpect(RECURSIVE)
+	ctes := []ast.Cte{}
+	for {
+		cte := ast.Cte{Name: p.expectIdent(), Recursive: recursive}
+		p.expect(AS)
+		cte.Select = p.parse_parenthesized_select()
+		ctes = append(ctes, cte)
+		if !p.optionallyExpect(COMMA) {
+			break
+		}
+	}
+	return ctes
+}
+
+func (p *Parser) parse_set_operation_kind() (ast.Set_operation_kind, bool) {
+	switch {
+	case p.optionallyExpect(UNION):
+		return ast.Union, true
+	case p.optionallyExpect(INTERSECT):
+		return ast.Intersect, true
+	case p.optionallyExpect(EXCEPT):
+		return ast.Except, true
+	}
+	return "", false
+}
+
+// a select up to (and including) its HAVING, what comes after that is for all the selects that are combined by set operations
+func (p *Parser) parse_select_core() ast.Select {
 	s := ast.Select{}
 	p.optionallyExpect(SELECT)
+	if p.optionallyExpect(DISTINCT) {
+		s.Distinct = true
+		if p.optionallyExpect(ON) {
+			p.expect(LPAREN)
+			for {
+				s.Distinct_on = append(s.Distinct_on, p.parseCol())
+				if !p.optionallyExpect(COMMA) {
+					break
+				}
+			}
+			p.expect(RPAREN)
+		}
+	}
 	var Value_to_select any
 	for !p.optionallyExpect(FROM) {
 		var alias string
-		if p.optionallyExpect(LPAREN) {
+		if p.pos+1 < len(p.Tokens) && p.Tokens[p.pos].Type == LPAREN && p.Tokens[p.pos+1].Type == SELECT {
+			p.pos++
 			Value_to_select = p.Parse_Select()
 			p.expect(RPAREN)
 			alias = Value_to_select.(ast.Select).Table
+		} else if star, is_star := p.parse_star(); is_star {
+			Value_to_select = star
 		} else {
-			Value_to_select = p.parse_col_or_expr_lit()
+			Value_to_select = p.parse_value_expr()
+		}
+		if _, is_star := Value_to_select.(ast.Star); is_star && p.Tokens[p.pos].Type == AS {
+			panic("* can not be given an alias")
 		}
 		if p.optionallyExpect(AS) {
 			alias = p.expectIdent()
@@ -116,21 +510,32 @@ func (p *Parser) Parse_Select() ast.Select {
 		}
 	}
 	s.Table = p.expectIdent()
+	s.Table_alias = p.parse_table_alias()
+	for {
+		kind, is_join := p.parse_join_kind()
+		if !is_join {
+			break
+		}
+		join := ast.Join{Kind: kind, Table: p.expectIdent()}
+		join.Alias = p.parse_table_alias()
+		p.expect(ON)
+		join.On = p.parse_or_expr()
+		s.Joins = append(s.Joins, join)
+	}
 	if p.optionallyExpect(WHERE) {
-		for p.inrange() && (p.Tokens[p.pos].Type != RPAREN) {
-			where := p.parse_simple_expr()
-			s.Wheres = append(s.Wheres, where)
-			if !p.optionallyExpect(AND) {
+		s.Wheres = ast.Split_conjuncts(p.parse_or_expr())
+	}
+	if p.optionallyExpect(GROUP) {
+		p.expect(BY)
+		for {
+			s.Group_by = append(s.Group_by, p.parseCol())
+			if !p.optionallyExpect(COMMA) {
 				break
 			}
 		}
 	}
-	if p.optionallyExpect(GROUP) {
-		p.expect(BY)
-		s.GroupByCol = unwrap.Some(p.parseCol())
-	} else {
-		s.GroupByCol = unwrap.None[ast.Col]()
+	if p.optionallyExpect(HAVING) {
+		s.Having = ast.Split_conjuncts(p.parse_or_expr())
 	}
-
 	return s
 }
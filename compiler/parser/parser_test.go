@@ -1,9 +1,12 @@
 package parser
 
 import (
+	"reflect"
 	"sql-compiler/compare"
 	"sql-compiler/compiler/ast"
 	"sql-compiler/compiler/parser/tokenizer"
+	"sql-compiler/compiler/rowType"
+	"sql-compiler/unwrap"
 	"testing"
 )
 
@@ -15,8 +18,8 @@ func TestParser(t *testing.T) {
 	p := Parser{Tokens: l.Tokenize()}
 	expected := &ast.Select{
 		Table: "person",
-		Wheres: []ast.Where{
-			{
+		Wheres: []ast.Bool_expr{
+			ast.Where{
 				Value1:   ast.Table_access{Table_name: "person", Col_name: "age"},
 				Operator: tokenizer.GE,
 				Value2:   3,
@@ -29,8 +32,8 @@ func TestParser(t *testing.T) {
 			{
 				Value_to_select: ast.Select{
 					Table: "todo",
-					Wheres: []ast.Where{
-						{
+					Wheres: []ast.Bool_expr{
+						ast.Where{
 							Value1:   ast.Table_access{Table_name: "todo", Col_name: "person_id"},
 							Operator: tokenizer.EQ,
 							Value2:   ast.Table_access{Table_name: "person", Col_name: "id"},
@@ -54,3 +57,471 @@ func TestParser(t *testing.T) {
 	}
 
 }
+
+func TestParserBoolPrecedence(t *testing.T) {
+	src := `SELECT title FROM todo WHERE done == false OR NOT is_public == true AND (person_id == 1 OR person_id == 2) AND id > 3 `
+	l := tokenizer.NewLexer(src)
+	p := Parser{Tokens: l.Tokenize()}
+	expected := &ast.Select{
+		Table: "todo",
+		Wheres: []ast.Bool_expr{
+			ast.Or_expr{
+				Left: ast.Where{Value1: ast.Plain_col_name("done"), Operator: tokenizer.EQ, Value2: false},
+				Right: ast.And_expr{
+					Left: ast.And_expr{
+						Left: ast.Not_expr{Expr: ast.Where{Value1: ast.Plain_col_name("is_public"), Operator: tokenizer.EQ, Value2: true}},
+						Right: ast.Or_expr{
+							Left:  ast.Where{Value1: ast.Plain_col_name("person_id"), Operator: tokenizer.EQ, Value2: 1},
+							Right: ast.Where{Value1: ast.Plain_col_name("person_id"), Operator: tokenizer.EQ, Value2: 2},
+						},
+					},
+					Right: ast.Where{Value1: ast.Plain_col_name("id"), Operator: tokenizer.GT, Value2: 3},
+				},
+			},
+		},
+		Selected_values: []ast.Selected_value{
+			{Value_to_select: ast.Plain_col_name("title")},
+		},
+	}
+	got := p.Parse_Select()
+	output, err := compare.Compare(expected, got, "")
+	println(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*expected, got) { //the json comparison can not tell an And_expr apart from an Or_expr
+		t.Fatalf("expected %#v but got %#v", *expected, got)
+	}
+}
+
+func TestParserTopLevelAndsAreSplit(t *testing.T) {
+	src := `SELECT title FROM todo WHERE (done == false AND person_id == 1) AND NOT (is_public == true OR id < 2) `
+	l := tokenizer.NewLexer(src)
+	p := Parser{Tokens: l.Tokenize()}
+	expected := &ast.Select{
+		Table: "todo",
+		Wheres: []ast.Bool_expr{
+			ast.Where{Value1: ast.Plain_col_name("done"), Operator: tokenizer.EQ, Value2: false},
+			ast.Where{Value1: ast.Plain_col_name("person_id"), Operator: tokenizer.EQ, Value2: 1},
+			ast.Not_expr{Expr: ast.Or_expr{
+				Left:  ast.Where{Value1: ast.Plain_col_name("is_public"), Operator: tokenizer.EQ, Value2: true},
+				Right: ast.Where{Value1: ast.Plain_col_name("id"), Operator: tokenizer.LT, Value2: 2},
+			}},
+		},
+		Selected_values: []ast.Selected_value{
+			{Value_to_select: ast.Plain_col_name("title")},
+		},
+	}
+	got := p.Parse_Select()
+	output, err := compare.Compare(expected, got, "")
+	println(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*expected, got) { //the json comparison can not tell an And_expr apart from an Or_expr
+		t.Fatalf("expected %#v but got %#v", *expected, got)
+	}
+}
+
+func TestParserOrderByLimitOffset(t *testing.T) {
+	src := `SELECT name, age FROM person WHERE age > 3 ORDER BY age DESC, person.name ASC, id LIMIT 10 OFFSET 20 `
+	l := tokenizer.NewLexer(src)
+	p := Parser{Tokens: l.Tokenize()}
+	expected := &ast.Select{
+		Table: "person",
+		Wheres: []ast.Bool_expr{
+			ast.Where{Value1: ast.Plain_col_name("age"), Operator: tokenizer.GT, Value2: 3},
+		},
+		Selected_values: []ast.Selected_value{
+			{Value_to_select: ast.Plain_col_name("name")},
+			{Value_to_select: ast.Plain_col_name("age")},
+		},
+		Order_by: []ast.Order_by_item{
+			{Col: ast.Plain_col_name("age"), Desc: true},
+			{Col: ast.Table_access{Table_name: "person", Col_name: "name"}},
+			{Col: ast.Plain_col_name("id")},
+		},
+		Limit:  unwrap.Some(10),
+		Offset: unwrap.Some(20),
+	}
+	got := p.Parse_Select()
+	output, err := compare.Compare(expected, got, "")
+	println(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*expected, got) {
+		t.Fatalf("expected %#v but got %#v", *expected, got)
+	}
+}
+
+func TestParserAggregateCalls(t *testing.T) {
+	src := `SELECT COUNT(*), avg(person.age) AS average_age FROM person `
+	l := tokenizer.NewLexer(src)
+	p := Parser{Tokens: l.Tokenize()}
+	expected := &ast.Select{
+		Table: "person",
+		Selected_values: []ast.Selected_value{
+			{Value_to_select: ast.Func_call{Name: "COUNT", Star: true}},
+			{Value_to_select: ast.Func_call{Name: "avg", Args: []any{ast.Table_access{Table_name: "person", Col_name: "age"}}}, Alias: "average_age"},
+		},
+	}
+	got := p.Parse_Select()
+	if !reflect.DeepEqual(*expected, got) {
+		t.Fatalf("expected %#v but got %#v", *expected, got)
+	}
+}
+
+func TestParserGroupByHaving(t *testing.T) {
+	src := `SELECT age, state, COUNT(*) AS n FROM person GROUP BY age, person.state HAVING COUNT(*) > 2 AND age < 50 `
+	l := tokenizer.NewLexer(src)
+	p := Parser{Tokens: l.Tokenize()}
+	expected := &ast.Select{
+		Table: "person",
+		Selected_values: []ast.Selected_value{
+			{Value_to_select: ast.Plain_col_name("age")},
+			{Value_to_select: ast.Plain_col_name("state")},
+			{Value_to_select: ast.Func_call{Name: "COUNT", Star: true}, Alias: "n"},
+		},
+		Group_by: []ast.Col{ast.Plain_col_name("age"), ast.Table_access{Table_name: "person", Col_name: "state"}},
+		Having: []ast.Bool_expr{
+			ast.Where{Value1: ast.Func_call{Name: "COUNT", Star: true}, Operator: tokenizer.GT, Value2: 2},
+			ast.Where{Value1: ast.Plain_col_name("age"), Operator: tokenizer.LT, Value2: 50},
+		},
+	}
+	got := p.Parse_Select()
+	if !reflect.DeepEqual(*expected, got) {
+		t.Fatalf("expected %#v but got %#v", *expected, got)
+	}
+}
+
+func TestParserJoins(t *testing.T) {
+	src := `SELECT t.title, p.name FROM todo t LEFT OUTER JOIN person AS p ON t.person_id == p.id JOIN tag ON tag.id == t.id WHERE p.age > 3 `
+	l := tokenizer.NewLexer(src)
+	p := Parser{Tokens: l.Tokenize()}
+	expected := &ast.Select{
+		Table:       "todo",
+		Table_alias: "t",
+		Joins: []ast.Join{
+			{Kind: ast.Left_join, Table: "person", Alias: "p", On: ast.Where{Value1: ast.Table_access{Table_name: "t", Col_name: "person_id"}, Operator: tokenizer.EQ, Value2: ast.Table_access{Table_name: "p", Col_name: "id"}}},
+			{Kind: ast.Inner_join, Table: "tag", On: ast.Where{Value1: ast.Table_access{Table_name: "tag", Col_name: "id"}, Operator: tokenizer.EQ, Value2: ast.Table_access{Table_name: "t", Col_name: "id"}}},
+		},
+		Wheres: []ast.Bool_expr{
+			ast.Where{Value1: ast.Table_access{Table_name: "p", Col_name: "age"}, Operator: tokenizer.GT, Value2: 3},
+		},
+		Selected_values: []ast.Selected_value{
+			{Value_to_select: ast.Table_access{Table_name: "t", Col_name: "title"}},
+			{Value_to_select: ast.Table_access{Table_name: "p", Col_name: "name"}},
+		},
+	}
+	got := p.Parse_Select()
+	if !reflect.DeepEqual(*expected, got) {
+		t.Fatalf("expected %#v but got %#v", *expected, got)
+	}
+}
+
+func TestParserNulls(t *testing.T) {
+	src := `SELECT title FROM todo WHERE description IS NULL AND person_id IS NOT NULL OR description == NULL `
+	l := tokenizer.NewLexer(src)
+	p := Parser{Tokens: l.Tokenize()}
+	expected := &ast.Select{
+		Table: "todo",
+		Wheres: []ast.Bool_expr{
+			ast.Or_expr{
+				Left: ast.And_expr{
+					Left:  ast.Is_null{Value: ast.Plain_col_name("description")},
+					Right: ast.Is_null{Value: ast.Plain_col_name("person_id"), Negated: true},
+				},
+				Right: ast.Where{Value1: ast.Plain_col_name("description"), Operator: tokenizer.EQ, Value2: ast.Null{}},
+			},
+		},
+		Selected_values: []ast.Selected_value{
+			{Value_to_select: ast.Plain_col_name("title")},
+		},
+	}
+	got := p.Parse_Select()
+	if !reflect.DeepEqual(*expected, got) {
+		t.Fatalf("expected %#v but got %#v", *expected, got)
+	}
+}
+
+func TestParserFloatLiteral(t *testing.T) {
+	src := `SELECT name, 2.5 AS weight FROM event WHERE score >= 1.25 `
+	l := tokenizer.NewLexer(src)
+	p := Parser{Tokens: l.Tokenize()}
+	expected := &ast.Select{
+		Table: "event",
+		Wheres: []ast.Bool_expr{
+			ast.Where{Value1: ast.Plain_col_name("score"), Operator: tokenizer.GE, Value2: 1.25},
+		},
+		Selected_values: []ast.Selected_value{
+			{Value_to_select: ast.Plain_col_name("name")},
+			{Value_to_select: 2.5, Alias: "weight"},
+		},
+	}
+	got := p.Parse_Select()
+	if !reflect.DeepEqual(*expected, got) {
+		t.Fatalf("expected %#v but got %#v", *expected, got)
+	}
+}
+
+func TestParserArithmetic(t *testing.T) {
+	src := `SELECT price * quantity AS total, -age FROM item WHERE (age + 1) * 2 > 21 - -3 AND (a == 1 OR b - c - d < 3) `
+	l := tokenizer.NewLexer(src)
+	p := Parser{Tokens: l.Tokenize()}
+	expected := &ast.Select{
+		Table: "item",
+		Wheres: []ast.Bool_expr{
+			ast.Where{
+				Value1: ast.Arithmetic{
+					Left:     ast.Arithmetic{Left: ast.Plain_col_name("age"), Operator: tokenizer.PLUS, Right: 1},
+					Operator: tokenizer.ASTERISK,
+					Right:    2,
+				},
+				Operator: tokenizer.GT,
+				Value2:   ast.Arithmetic{Left: 21, Operator: tokenizer.MINUS, Right: -3},
+			},
+			ast.Or_expr{
+				Left: ast.Where{Value1: ast.Plain_col_name("a"), Operator: tokenizer.EQ, Value2: 1},
+				Right: ast.Where{
+					Value1: ast.Arithmetic{
+						Left:     ast.Arithmetic{Left: ast.Plain_col_name("b"), Operator: tokenizer.MINUS, Right: ast.Plain_col_name("c")},
+						Operator: tokenizer.MINUS,
+						Right:    ast.Plain_col_name("d"),
+					},
+					Operator: tokenizer.LT,
+					Value2:   3,
+				},
+			},
+		},
+		Selected_values: []ast.Selected_value{
+			{Value_to_select: ast.Arithmetic{Left: ast.Plain_col_name("price"), Operator: tokenizer.ASTERISK, Right: ast.Plain_col_name("quantity")}, Alias: "total"},
+			{Value_to_select: ast.Arithmetic{Left: 0, Operator: tokenizer.MINUS, Right: ast.Plain_col_name("age")}},
+		},
+	}
+	got := p.Parse_Select()
+	if !reflect.DeepEqual(*expected, got) {
+		t.Fatalf("expected %#v but got %#v", *expected, got)
+	}
+}
+
+func TestParserCastAndCase(t *testing.T) {
+	src := `SELECT CAST(age AS INTEGER), CASE WHEN age < 18 THEN "minor" WHEN age IS NULL THEN NULL ELSE upper(name) END AS kind FROM person `
+	l := tokenizer.NewLexer(src)
+	p := Parser{Tokens: l.Tokenize()}
+	expected := &ast.Select{
+		Table: "person",
+		Selected_values: []ast.Selected_value{
+			{Value_to_select: ast.Cast{Value: ast.Plain_col_name("age"), Type: rowType.Int}},
+			{Value_to_select: ast.Case{
+				Whens: []ast.Case_when{
+					{Condition: ast.Where{Value1: ast.Plain_col_name("age"), Operator: tokenizer.LT, Value2: 18}, Then: "minor"},
+					{Condition: ast.Is_null{Value: ast.Plain_col_name("age")}, Then: ast.Null{}},
+				},
+				Else: ast.Func_call{Name: "upper", Args: []any{ast.Plain_col_name("name")}},
+			}, Alias: "kind"},
+		},
+	}
+	got := p.Parse_Select()
+	if !reflect.DeepEqual(*expected, got) {
+		t.Fatalf("expected %#v but got %#v", *expected, got)
+	}
+}
+
+func TestParserDistinct(t *testing.T) {
+	for src, expected := range map[string]ast.Select{
+		`SELECT DISTINCT state FROM person `: {
+			Distinct:        true,
+			Table:           "person",
+			Selected_values: []ast.Selected_value{{Value_to_select: ast.Plain_col_name("state")}},
+		},
+		`SELECT DISTINCT ON (state, person.age) name, state, age FROM person `: {
+			Distinct:    true,
+			Distinct_on: []ast.Col{ast.Plain_col_name("state"), ast.Table_access{Table_name: "person", Col_name: "age"}},
+			Table:       "person",
+			Selected_values: []ast.Selected_value{
+				{Value_to_select: ast.Plain_col_name("name")},
+				{Value_to_select: ast.Plain_col_name("state")},
+				{Value_to_select: ast.Plain_col_name("age")},
+			},
+		},
+	} {
+		p := Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+		got := p.Parse_Select()
+		if !reflect.DeepEqual(expected, got) {
+			t.Fatalf("%s: expected %#v but got %#v", src, expected, got)
+		}
+	}
+}
+
+func TestParserSetOperations(t *testing.T) {
+	src := `SELECT id FROM todo WHERE done == true UNION ALL SELECT id FROM todo EXCEPT SELECT todo_id FROM todo_tag ORDER BY id LIMIT 2 `
+	p := Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+	expected := ast.Select{
+		Table:           "todo",
+		Selected_values: []ast.Selected_value{{Value_to_select: ast.Plain_col_name("id")}},
+		Wheres:          []ast.Bool_expr{ast.Where{Value1: ast.Plain_col_name("done"), Operator: tokenizer.EQ, Value2: true}},
+		Set_operations: []ast.Set_operation{
+			{Kind: ast.Union, All: true, Select: ast.Select{Table: "todo", Selected_values: []ast.Selected_value{{Value_to_select: ast.Plain_col_name("id")}}}},
+			{Kind: ast.Except, Select: ast.Select{Table: "todo_tag", Selected_values: []ast.Selected_value{{Value_to_select: ast.Plain_col_name("todo_id")}}}},
+		},
+		Order_by: []ast.Order_by_item{{Col: ast.Plain_col_name("id")}},
+		Limit:    unwrap.Some(2),
+	}
+	got := p.Parse_Select()
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %#v but got %#v", expected, got)
+	}
+}
+
+func TestParserInAndExists(t *testing.T) {
+	src := `SELECT id FROM person WHERE id IN (1, 2) AND age NOT IN (SELECT age FROM person) AND NOT EXISTS (SELECT id FROM todo WHERE todo.person_id == person.id) `
+	p := Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+	expected := ast.Select{
+		Table:           "person",
+		Selected_values: []ast.Selected_value{{Value_to_select: ast.Plain_col_name("id")}},
+		Wheres: []ast.Bool_expr{
+			ast.In_list{Value: ast.Plain_col_name("id"), List: []any{1, 2}},
+			ast.In_select{Value: ast.Plain_col_name("age"), Select: ast.Select{Table: "person", Selected_values: []ast.Selected_value{{Value_to_select: ast.Plain_col_name("age")}}}, Negated: true},
+			ast.Not_expr{Expr: ast.Exists{Select: ast.Select{
+				Table:           "todo",
+				Selected_values: []ast.Selected_value{{Value_to_select: ast.Plain_col_name("id")}},
+				Wheres:          []ast.Bool_expr{ast.Where{Value1: ast.Table_access{Table_name: "todo", Col_name: "person_id"}, Operator: tokenizer.EQ, Value2: ast.Table_access{Table_name: "person", Col_name: "id"}}},
+			}}},
+		},
+	}
+	got := p.Parse_Select()
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %#v but got %#v", expected, got)
+	}
+
+	src = `SELECT id FROM person WHERE (age) IN (20, 30) AND (age + 1) NOT IN (SELECT age FROM person) `
+	p = Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+	expected = ast.Select{
+		Table:           "person",
+		Selected_values: []ast.Selected_value{{Value_to_select: ast.Plain_col_name("id")}},
+		Wheres: []ast.Bool_expr{
+			ast.In_list{Value: ast.Plain_col_name("age"), List: []any{20, 30}},
+			ast.In_select{
+				Value:   ast.Arithmetic{Left: ast.Plain_col_name("age"), Operator: tokenizer.PLUS, Right: 1},
+				Select:  ast.Select{Table: "person", Selected_values: []ast.Selected_value{{Value_to_select: ast.Plain_col_name("age")}}},
+				Negated: true,
+			},
+		},
+	}
+	got = p.Parse_Select()
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %#v but got %#v", expected, got)
+	}
+}
+
+func TestParserPatternMatching(t *testing.T) {
+	src := `SELECT id FROM todo WHERE title LIKE "a%" AND title NOT ILIKE "_b" AND title ~ "^c" `
+	p := Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+	expected := ast.Select{
+		Table:           "todo",
+		Selected_values: []ast.Selected_value{{Value_to_select: ast.Plain_col_name("id")}},
+		Wheres: []ast.Bool_expr{
+			ast.Where{Value1: ast.Plain_col_name("title"), Operator: tokenizer.LIKE, Value2: "a%"},
+			ast.Not_expr{Expr: ast.Where{Value1: ast.Plain_col_name("title"), Operator: tokenizer.ILIKE, Value2: "_b"}},
+			ast.Where{Value1: ast.Plain_col_name("title"), Operator: tokenizer.REGEX, Value2: "^c"},
+		},
+	}
+	got := p.Parse_Select()
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %#v but got %#v", expected, got)
+	}
+
+	src = `SELECT id FROM todo WHERE (title) LIKE "a%" AND (title) NOT ILIKE "_b" AND (title) ~ "^c" `
+	p = Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+	got = p.Parse_Select()
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %#v but got %#v", expected, got)
+	}
+}
+
+func TestParserNotEqualAndBetween(t *testing.T) {
+	src := `SELECT id FROM todo WHERE id != 1 AND id <> 2 AND person_id = 3 AND id BETWEEN 4 AND 5 AND id NOT BETWEEN 6 AND 7 `
+	p := Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+	id := ast.Plain_col_name("id")
+	expected := ast.Select{
+		Table:           "todo",
+		Selected_values: []ast.Selected_value{{Value_to_select: id}},
+		Wheres: []ast.Bool_expr{
+			ast.Where{Value1: id, Operator: tokenizer.NEQ, Value2: 1},
+			ast.Where{Value1: id, Operator: tokenizer.NEQ, Value2: 2},
+			ast.Where{Value1: ast.Plain_col_name("person_id"), Operator: tokenizer.EQ, Value2: 3},
+			ast.Where{Value1: id, Operator: tokenizer.GE, Value2: 4},
+			ast.Where{Value1: id, Operator: tokenizer.LE, Value2: 5},
+			ast.Not_expr{Expr: ast.And_expr{
+				Left:  ast.Where{Value1: id, Operator: tokenizer.GE, Value2: 6},
+				Right: ast.Where{Value1: id, Operator: tokenizer.LE, Value2: 7},
+			}},
+		},
+	}
+	got := p.Parse_Select()
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %#v but got %#v", expected, got)
+	}
+
+	src = `SELECT id FROM todo WHERE (id + 1) != 1 AND (person_id) = 3 AND (id) BETWEEN 4 AND 5 `
+	p = Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+	expected.Wheres = []ast.Bool_expr{
+		ast.Where{Value1: ast.Arithmetic{Left: id, Operator: tokenizer.PLUS, Right: 1}, Operator: tokenizer.NEQ, Value2: 1},
+		ast.Where{Value1: ast.Plain_col_name("person_id"), Operator: tokenizer.EQ, Value2: 3},
+		ast.Where{Value1: id, Operator: tokenizer.GE, Value2: 4},
+		ast.Where{Value1: id, Operator: tokenizer.LE, Value2: 5},
+	}
+	got = p.Parse_Select()
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %#v but got %#v", expected, got)
+	}
+}
+
+func TestParserWith(t *testing.T) {
+	src := `WITH RECURSIVE thread AS (SELECT id FROM comment WHERE parent_id IS NULL UNION ALL SELECT comment.id FROM comment JOIN thread ON comment.parent_id == thread.id) SELECT id FROM thread `
+	p := Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+	expected := ast.Select{
+		With: []ast.Cte{{
+			Name:      "thread",
+			Recursive: true,
+			Select: ast.Select{
+				Table:           "comment",
+				Selected_values: []ast.Selected_value{{Value_to_select: ast.Plain_col_name("id")}},
+				Wheres:          []ast.Bool_expr{ast.Is_null{Value: ast.Plain_col_name("parent_id")}},
+				Set_operations: []ast.Set_operation{{Kind: ast.Union, All: true, Select: ast.Select{
+					Table:           "comment",
+					Selected_values: []ast.Selected_value{{Value_to_select: ast.Table_access{Table_name: "comment", Col_name: "id"}}},
+					Joins: []ast.Join{{
+						Kind:  ast.Inner_join,
+						Table: "thread",
+						On:    ast.Where{Value1: ast.Table_access{Table_name: "comment", Col_name: "parent_id"}, Operator: tokenizer.EQ, Value2: ast.Table_access{Table_name: "thread", Col_name: "id"}},
+					}},
+				}}},
+			},
+		}},
+		Table:           "thread",
+		Selected_values: []ast.Selected_value{{Value_to_select: ast.Plain_col_name("id")}},
+	}
+	got := p.Parse_Select()
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %#v but got %#v", expected, got)
+	}
+}
+
+func TestParserStar(t *testing.T) {
+	src := `SELECT *, person.*, (SELECT * FROM todo) AS todos FROM person `
+	p := Parser{Tokens: tokenizer.NewLexer(src).Tokenize()}
+	expected := ast.Select{
+		Table: "person",
+		Selected_values: []ast.Selected_value{
+			{Value_to_select: ast.Star{}},
+			{Value_to_select: ast.Star{Table_name: "person"}},
+			{Value_to_select: ast.Select{Table: "todo", Selected_values: []ast.Selected_value{{Value_to_select: ast.Star{}}}}, Alias: "todos"},
+		},
+	}
+	got := p.Parse_Select()
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %#v but got %#v", expected, got)
+	}
+}
@@ -10,15 +10,50 @@ type TokenType string
 
 const (
 	// Keywords
-	SELECT TokenType = "SELECT"
-	FROM   TokenType = "FROM"
-	WHERE  TokenType = "WHERE"
-	AND    TokenType = "AND"
-	TRUE   TokenType = "TRUE"
-	FALSE  TokenType = "FALSE"
-	AS     TokenType = "AS"
-	GROUP  TokenType = "GROUP"
-	BY     TokenType = "BY"
+	SELECT    TokenType = "SELECT"
+	FROM      TokenType = "FROM"
+	WHERE     TokenType = "WHERE"
+	AND       TokenType = "AND"
+	OR        TokenType = "OR"
+	NOT       TokenType = "NOT"
+	TRUE      TokenType = "TRUE"
+	FALSE     TokenType = "FALSE"
+	AS        TokenType = "AS"
+	GROUP     TokenType = "GROUP"
+	BY        TokenType = "BY"
+	HAVING    TokenType = "HAVING"
+	JOIN      TokenType = "JOIN"
+	INNER     TokenType = "INNER"
+	LEFT      TokenType = "LEFT"
+	RIGHT     TokenType = "RIGHT"
+	FULL      TokenType = "FULL"
+	OUTER     TokenType = "OUTER"
+	ON        TokenType = "ON"
+	NULL      TokenType = "NULL"
+	IS        TokenType = "IS"
+	ORDER     TokenType = "ORDER"
+	ASC       TokenType = "ASC"
+	DESC      TokenType = "DESC"
+	LIMIT     TokenType = "LIMIT"
+	OFFSET    TokenType = "OFFSET"
+	CAST      TokenType = "CAST"
+	CASE      TokenType = "CASE"
+	WHEN      TokenType = "WHEN"
+	THEN      TokenType = "THEN"
+	ELSE      TokenType = "ELSE"
+	END       TokenType = "END"
+	DISTINCT  TokenType = "DISTINCT"
+	UNION     TokenType = "UNION"
+	ALL       TokenType = "ALL"
+	INTERSECT TokenType = "INTERSECT"
+	EXCEPT    TokenType = "EXCEPT"
+	IN        TokenType = "IN"
+	EXISTS    TokenType = "EXISTS"
+	LIKE      TokenType = "LIKE"
+	ILIKE     TokenType = "ILIKE"
+	BETWEEN   TokenType = "BETWEEN"
+	WITH      TokenType = "WITH"
+	RECURSIVE TokenType = "RECURSIVE"
 
 	// Special
 	ILLEGAL TokenType = "ILLEGAL"
@@ -43,6 +78,10 @@ const (
 	GT TokenType = ">"
 	GE TokenType = ">="
 	EQ TokenType = "=="
+	// != and <>
+	NEQ TokenType = "!="
+	// a regular expression match
+	REGEX TokenType = "~"
 
 	// Delimiters
 	COMMA     TokenType = ","
@@ -108,22 +147,92 @@ func (l *Lexer) peekChar() rune {
 // ---------------- KEYWORDS ----------------
 
 var keywords = map[string]TokenType{
-	"SELECT": SELECT,
-	"select": SELECT,
-	"FROM":   FROM,
-	"from":   FROM,
-	"WHERE":  WHERE,
-	"where":  WHERE,
-	"AND":    AND,
-	"and":    AND,
-	"true":   TRUE,
-	"false":  FALSE,
-	"AS":     AS,
-	"as":     AS,
-	"GROUP":  GROUP,
-	"group":  GROUP,
-	"BY":     BY,
-	"by":     BY,
+	"SELECT":    SELECT,
+	"select":    SELECT,
+	"FROM":      FROM,
+	"from":      FROM,
+	"WHERE":     WHERE,
+	"where":     WHERE,
+	"AND":       AND,
+	"and":       AND,
+	"OR":        OR,
+	"or":        OR,
+	"NOT":       NOT,
+	"not":       NOT,
+	"true":      TRUE,
+	"false":     FALSE,
+	"AS":        AS,
+	"as":        AS,
+	"GROUP":     GROUP,
+	"group":     GROUP,
+	"BY":        BY,
+	"by":        BY,
+	"HAVING":    HAVING,
+	"having":    HAVING,
+	"JOIN":      JOIN,
+	"join":      JOIN,
+	"INNER":     INNER,
+	"inner":     INNER,
+	"LEFT":      LEFT,
+	"left":      LEFT,
+	"RIGHT":     RIGHT,
+	"right":     RIGHT,
+	"FULL":      FULL,
+	"full":      FULL,
+	"OUTER":     OUTER,
+	"outer":     OUTER,
+	"ON":        ON,
+	"on":        ON,
+	"NULL":      NULL,
+	"null":      NULL,
+	"IS":        IS,
+	"is":        IS,
+	"ORDER":     ORDER,
+	"order":     ORDER,
+	"ASC":       ASC,
+	"asc":       ASC,
+	"DESC":      DESC,
+	"desc":      DESC,
+	"LIMIT":     LIMIT,
+	"limit":     LIMIT,
+	"OFFSET":    OFFSET,
+	"offset":    OFFSET,
+	"CAST":      CAST,
+	"cast":      CAST,
+	"CASE":      CASE,
+	"case":      CASE,
+	"WHEN":      WHEN,
+	"when":      WHEN,
+	"THEN":      THEN,
+	"then":      THEN,
+	"ELSE":      ELSE,
+	"else":      ELSE,
+	"END":       END,
+	"end":       END,
+	"DISTINCT":  DISTINCT,
+	"distinct":  DISTINCT,
+	"UNION":     UNION,
+	"union":     UNION,
+	"ALL":       ALL,
+	"all":       ALL,
+	"INTERSECT": INTERSECT,
+	"intersect": INTERSECT,
+	"EXCEPT":    EXCEPT,
+	"except":    EXCEPT,
+	"IN":        IN,
+	"in":        IN,
+	"EXISTS":    EXISTS,
+	"exists":    EXISTS,
+	"LIKE":      LIKE,
+	"like":      LIKE,
+	"ILIKE":     ILIKE,
+	"ilike":     ILIKE,
+	"BETWEEN":   BETWEEN,
+	"between":   BETWEEN,
+	"WITH":      WITH,
+	"with":      WITH,
+	"RECURSIVE": RECURSIVE,
+	"recursive": RECURSIVE,
 }
 
 func lookupIdent(ident string) TokenType {
@@ -180,11 +289,21 @@ func (l *Lexer) NextToken() Token {
 		}
 		tok = newToken(SLASH, l.ch, l.position)
 	case '!':
-		tok = newToken(BANG, l.ch, l.position)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = newToken(NEQ, l.ch, l.position)
+		} else {
+			tok = newToken(BANG, l.ch, l.position)
+		}
+	case '~':
+		tok = newToken(REGEX, l.ch, l.position)
 	case '<':
 		if l.peekChar() == '=' {
 			l.readChar()
 			tok = newToken(LE, l.ch, l.position)
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = newToken(NEQ, l.ch, l.position)
 		} else {
 			tok = newToken(LT, l.ch, l.position)
 		}
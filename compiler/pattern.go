@@ -0,0 +1,58 @@
+package compiler
+
+import (
+	"regexp"
+	. "sql-compiler/compiler/parser/tokenizer"
+	"strings"
+)
+
+func is_pattern_operator(operator TokenType) bool {
+	return operator == LIKE || operator == ILIKE || operator == REGEX
+}
+
+// the pattern is compiled once here instead of for every row, LIKE and ILIKE are turned into the regular expression that matches the same strings
+func compile_pattern(operator TokenType, pattern any) *regexp.Regexp {
+	pattern_string, ok := pattern.(string)
+	if !ok {
+		panic("the pattern of " + string(operator) + " has to be a string")
+	}
+	if operator == REGEX {
+		compiled, err := regexp.Compile(pattern_string)
+		if err != nil {
+			panic("invalid regular expression " + pattern_string + ": " + err.Error())
+		}
+		return compiled
+	}
+	return regexp.MustCompile(like_to_regexp(pattern_string, operator == ILIKE))
+}
+
+// % matches any number of characters and _ a single one, a \ makes the character after it match only itself
+func like_to_regexp(pattern string, case_insensitive bool) string {
+	res := strings.Builder{}
+	res.WriteString("^")
+	if case_insensitive {
+		res.WriteString("(?i)")
+	}
+	res.WriteString("(?s)")
+	escaped := false
+	for _, ch := range pattern {
+		switch {
+		case escaped:
+			res.WriteString(regexp.QuoteMeta(string(ch)))
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case ch == '%':
+			res.WriteString(".*")
+		case ch == '_':
+			res.WriteString(".")
+		default:
+			res.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	if escaped {
+		panic("a LIKE pattern can not end with \\")
+	}
+	res.WriteString("$")
+	return res.String()
+}
@@ -0,0 +1,74 @@
+package compiler
+
+import (
+	"math"
+	"slices"
+	"sql-compiler/compiler/ast"
+	"sql-compiler/compiler/rowType"
+	"sql-compiler/compiler/state_full_byte_code/byte_code"
+	"sql-compiler/db_tables"
+	pubsub "sql-compiler/pub_sub"
+	"sql-compiler/unwrap"
+)
+
+// the part of the rows a bound with a value of the parent select is guessed to let through, as its value is only known when the query runs
+const unknown_bound_selectivity = 1.0 / 3
+
+// the select can read all the rows of its table, the channel of a hash index or a range of an ordered index,
+// the WHERE still checks every row it gets, so the planner picks whichever is estimated to give the fewest rows
+func Choose_access_path(table *db_tables.Table, select_ *ast.Select) byte_code.Access_path {
+	candidates := []byte_code.Access_path{}
+	prefixes := hash_candidates(table, select_)
+	//on a tie the channel of more columns is picked, as the estimates are the same when the table has no rows yet
+	slices.SortStableFunc(prefixes, func(a []byte_code.ColValuePair, b []byte_code.ColValuePair) int { return len(b) - len(a) })
+	for _, prefix := range prefixes {
+		candidates = append(candidates, byte_code.Access_path{Kind: byte_code.Hash_channel, Cols_and_values: prefix, Estimated_rows: hash_channel_rows(table, prefix)})
+	}
+	for _, index_range := range range_candidates(table, select_) {
+		candidates = append(candidates, byte_code.Access_path{Kind: byte_code.Range_index, Range: unwrap.Some(index_range), Estimated_rows: range_rows(table, index_range)})
+	}
+	candidates = append(candidates, byte_code.Access_path{Kind: byte_code.Full_table, Estimated_rows: float64(table.R_Table.Row_count())})
+
+	best := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if candidate.Estimated_rows < best.Estimated_rows {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// the channel of literal values is counted, when a value is of the parent select its only known when the query runs, so the size is guessed to be the average one
+func hash_channel_rows(table *db_tables.Table, prefix []byte_code.ColValuePair) float64 {
+	cols := []string{}
+	values := rowType.RowType{}
+	known := true
+	for _, col_and_value := range prefix {
+		cols = append(cols, col_and_value.Col)
+		values = append(values, col_and_value.Value)
+		_, is_location := col_and_value.Value.(byte_code.Runtime_value_relative_location)
+		known = known && !is_location
+	}
+	index := table.Index_starting_with(cols...)
+	if known {
+		return float64(index.Channel_size(values))
+	}
+	return index.Stats().Average_channel_size(len(cols))
+}
+
+// the rows between the bounds that are known are counted, each bound that is a value of the parent select lets through a part of them
+func range_rows(table *db_tables.Table, index_range byte_code.Index_range) float64 {
+	unknown_bounds := 0
+	known := func(bound unwrap.Option[byte_code.Index_bound]) unwrap.Option[pubsub.Bound] {
+		if bound.IsNone() {
+			return unwrap.None[pubsub.Bound]()
+		}
+		if _, is_location := bound.Unwrap().Value.(byte_code.Runtime_value_relative_location); is_location {
+			unknown_bounds++
+			return unwrap.None[pubsub.Bound]()
+		}
+		return unwrap.Some(pubsub.Bound{Value: bound.Unwrap().Value, Inclusive: bound.Unwrap().Inclusive})
+	}
+	count := table.Get_index(index_range.Col).Count_in_range(known(index_range.Low), known(index_range.High))
+	return float64(count) * math.Pow(unknown_bound_selectivity, float64(unknown_bounds))
+}
@@ -0,0 +1,117 @@
+package compiler
+
+import (
+	"sql-compiler/compiler/ast"
+	. "sql-compiler/compiler/parser/tokenizer"
+	. "sql-compiler/compiler/rowType"
+	"sql-compiler/compiler/state_full_byte_code/byte_code"
+	"sql-compiler/db_tables"
+	pubsub "sql-compiler/pub_sub"
+	"sql-compiler/unwrap"
+	"strings"
+)
+
+// the rows can be narrowed down with <, <=, >, >= (which BETWEEN is made of) or a LIKE 'prefix%' on a column with an ordered index,
+// the WHERE still checks every condition, so only one lower and one upper bound of each column is needed
+func range_candidates(table *db_tables.Table, select_ *ast.Select) []byte_code.Index_range {
+	ranges := map[string]*byte_code.Index_range{}
+	cols := []string{} //in the order they are first compared, so the same query always gets the same candidates
+	for _, where := range select_.Wheres {
+		for _, conjunct := range ast.Split_conjuncts(where) {
+			where, ok := conjunct.(ast.Where)
+			if !ok {
+				continue
+			}
+			col, ok := indexed_col(table, select_, where.Value1)
+			if !ok || table.Get_index(col).Kind != pubsub.Ordered {
+				continue
+			}
+			low, high, ok := range_bounds(table, select_, col, where)
+			if !ok {
+				continue
+			}
+			if _, ok := ranges[col]; !ok {
+				ranges[col] = &byte_code.Index_range{Col: col}
+				cols = append(cols, col)
+			}
+			if ranges[col].Low.IsNone() {
+				ranges[col].Low = low
+			}
+			if ranges[col].High.IsNone() {
+				ranges[col].High = high
+			}
+		}
+	}
+	candidates := []byte_code.Index_range{}
+	for _, col := range cols {
+		candidates = append(candidates, *ranges[col])
+	}
+	return candidates
+}
+
+func range_bounds(table *db_tables.Table, select_ *ast.Select, col string, where ast.Where) (unwrap.Option[byte_code.Index_bound], unwrap.Option[byte_code.Index_bound], bool) {
+	none := unwrap.None[byte_code.Index_bound]()
+	if where.Operator == LIKE {
+		pattern, is_string := where.Value2.(string)
+		if !is_string || table.Columns[table.Get_col_index(col)].Type != String {
+			return none, none, false
+		}
+		prefix := like_prefix(pattern)
+		if prefix == "" {
+			return none, none, false
+		}
+		high := none
+		if after, ok := after_prefix(prefix); ok {
+			high = unwrap.Some(byte_code.Index_bound{Value: after})
+		}
+		return unwrap.Some(byte_code.Index_bound{Value: prefix, Inclusive: true}), high, true
+	}
+	if _, is_null := where.Value2.(ast.Null); is_null {
+		return none, none, false
+	}
+	value, ok := index_value(table, select_, col, where.Value2)
+	if !ok {
+		return none, none, false
+	}
+	switch where.Operator {
+	case GT, GE:
+		return unwrap.Some(byte_code.Index_bound{Value: value, Inclusive: where.Operator == GE}), none, true
+	case LT, LE:
+		return none, unwrap.Some(byte_code.Index_bound{Value: value, Inclusive: where.Operator == LE}), true
+	default:
+		return none, none, false
+	}
+}
+
+// the characters a LIKE pattern starts with before its first % or _
+func like_prefix(pattern string) string {
+	prefix := strings.Builder{}
+	escaped := false
+	for _, ch := range pattern {
+		switch {
+		case escaped:
+			prefix.WriteRune(ch)
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case ch == '%' || ch == '_':
+			return prefix.String()
+		default:
+			prefix.WriteRune(ch)
+		}
+	}
+	return prefix.String()
+}
+
+// the first string after every string that starts with the prefix (strings are compared byte by byte), there is none when the prefix is only 0xff bytes
+func after_prefix(prefix string) (string, bool) {
+	bytes := []byte(prefix)
+	for len(bytes) > 0 && bytes[len(bytes)-1] == 0xff {
+		bytes = bytes[:len(bytes)-1]
+	}
+	if len(bytes) == 0 {
+		return "", false
+	}
+	bytes[len(bytes)-1]++
+	return string(bytes), true
+}
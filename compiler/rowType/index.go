@@ -33,9 +33,17 @@ const (
 	String DataType = iota
 	Int
 	Bool
+	Float
+	Timestamp //a time.Time, sent to the client as an ISO-8601 string
+	Json      //a json document, kept as its encoded text (a json.RawMessage)
 )
 
 // any other enum values will be as a NestedSelect_index
+const first_nested_select_index = 6 //the amount of placeholders at the start of NestedSelectsRowSchema
+
+func (this DataType) Is_nested_select() bool {
+	return this >= first_nested_select_index
+}
 
 func (r RowSchema) To_string(depth int) string {
 	indent := strings.Repeat("  ", depth)
@@ -49,6 +57,9 @@ func (r RowSchema) To_string(depth int) string {
 		b.WriteString(col.Name)
 		b.WriteByte(':')
 		b.WriteString(col.Type.To_string(depth + 1))
+		if col.Nullable {
+			b.WriteString(" | null")
+		}
 		b.WriteByte('\n')
 	}
 
@@ -66,12 +77,19 @@ func (this DataType) To_string(depth int) string {
 		return "number"
 	case Bool:
 		return "boolean"
+	case Float:
+		return "number"
+	case Timestamp:
+		return "string"
+	case Json:
+		return "unknown"
 	default:
 		return fmt.Sprintf(`{[key: string]: %s}`, NestedSelectsRowSchema[int(this)].To_string(depth+1))
 	}
 }
 
 type ColInfo struct {
-	Name string
-	Type DataType
+	Name     string
+	Type     DataType
+	Nullable bool //whether the column can hold null (nil)
 }
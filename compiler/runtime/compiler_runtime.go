@@ -2,7 +2,9 @@ package compiler_runtime
 
 import (
 	"fmt"
+	"slices"
 	"sql-compiler/compiler"
+	"sql-compiler/compiler/functions"
 	"sql-compiler/compiler/parser"
 	"sql-compiler/compiler/parser/tokenizer"
 	"sql-compiler/compiler/rowType"
@@ -13,77 +15,12 @@ import (
 	pubsub "sql-compiler/pub_sub"
 	option "sql-compiler/unwrap"
 	"sql-compiler/utils"
-	. "sql-compiler/utils"
-	"strconv"
 )
 
-var compare_methods = map[string]func(value1 any, value2 any) bool{
-
-	"==": func(value1 any, value2 any) bool {
-		switch value1 := value1.(type) {
-		case string:
-			return value1 == value2.(string)
-		case int:
-			return value1 == value2.(int)
-		case bool:
-			return value1 == value2.(bool)
-		default:
-			panic(fmt.Sprintf("types %T and %T do not match", value1, value2))
-		}
-	},
-	">": func(value1 any, value2 any) bool {
-		switch value1 := value1.(type) {
-		case string:
-			return value1 > value2.(string)
-		case int:
-			return value1 > value2.(int)
-		case bool:
-			return value1 == value2.(bool)
-		default:
-			panic(fmt.Sprintf("types %T and %T do not match", value1, value2))
-		}
-	},
-	"<": func(value1 any, value2 any) bool {
-		switch value1 := value1.(type) {
-		case string:
-			return value1 < value2.(string)
-		case int:
-			return value1 < value2.(int)
-		case bool:
-			return value1 == value2.(bool)
-		default:
-			panic(fmt.Sprintf("types %T and %T do not match", value1, value2))
-		}
-	},
-	">=": func(value1 any, value2 any) bool {
-		switch value1 := value1.(type) {
-		case string:
-			return value1 >= value2.(string)
-		case int:
-			return value1 >= value2.(int)
-		case bool:
-			return value1 == value2.(bool)
-		default:
-			panic(fmt.Sprintf("types %T and %T do not match", value1, value2))
-		}
-	},
-	"<=": func(value1 any, value2 any) bool {
-		switch value1 := value1.(type) {
-		case string:
-			return value1 <= value2.(string)
-		case int:
-			return value1 <= value2.(int)
-		case bool:
-			return value1 == value2.(bool)
-		default:
-			panic(fmt.Sprintf("types %T and %T do not match", value1, value2))
-		}
-	},
-}
-
-func filter(row_context state_full_byte_code.Row_context, wheres []byte_code.Where) bool {
+// only the rows the wheres are true for get through, a row they are unknown for is filtered out like a false one
+func filter(row_context state_full_byte_code.Row_context, wheres []byte_code.Bool_expr) bool {
 	for _, where := range wheres {
-		if !compare_methods[where.Compare_type](row_context.Track_value_if_is_relative_location(where.Value_1), row_context.Track_value_if_is_relative_location(where.Value_2)) {
+		if row_context.Eval_bool(where) != state_full_byte_code.True {
 			return false
 		}
 	}
@@ -94,11 +31,14 @@ func map_over(row_context state_full_byte_code.Row_context, selected_values_byte
 	row := rowType.RowType{}
 	for i, select_value_byte_code := range selected_values_byte_code { ///select_value_byte_code could just be a plain value
 		switch select_value_byte_code := select_value_byte_code.(type) {
-		case byte_code.Runtime_value_relative_location:
-			row = append(row, row_context.Get_value(select_value_byte_code))
+		case byte_code.Runtime_value_relative_location, byte_code.Arithmetic, byte_code.Func_call, byte_code.Case:
+			row = append(row, row_context.Eval(select_value_byte_code))
 		case byte_code.Select:
 			childs_row_context := state_full_byte_code.Row_context{Row: row_context.Row, Parent_context: option.Some(&row_context)}
-			childs_row_schema := rowType.NestedSelectsRowSchema[row_schema[i].Type]
+			childs_row_schema := rowType.RowSchema{row_schema[i]}
+			if !select_value_byte_code.Is_scalar {
+				childs_row_schema = rowType.NestedSelectsRowSchema[row_schema[i].Type]
+			}
 			row = append(row, select_byte_code_to_observable(select_value_byte_code, option.Some(&childs_row_context), childs_row_schema))
 		default:
 			row = append(row, select_value_byte_code)
@@ -107,46 +47,240 @@ func map_over(row_context state_full_byte_code.Row_context, selected_values_byte
 	return row
 }
 
+// the values can be values of the parent select, which are only known once its row is
+func index_channel(table_name string, cols_and_values []byte_code.ColValuePair, parent_context option.Option[*state_full_byte_code.Row_context]) pubsub.ObservableI {
+	table := db_tables.Tables.Get(table_name)
+	cols, values := []string{}, rowType.RowType{}
+	for _, col_and_value := range cols_and_values {
+		value := col_and_value.Value
+		if location, is_location := value.(byte_code.Runtime_value_relative_location); is_location {
+			value = parent_context.Unwrap().Get_value(location)
+			if value == nil {
+				//nothing is equal to null, so there are no rows (this channel is never attached to the table)
+				return pubsub.NewChannel(table.R_Table)
+			}
+		}
+		//a nil that isn't from the parent select is from an IS NULL
+		cols, values = append(cols, col_and_value.Col), append(values, value)
+	}
+	return table.Index_starting_with(cols...).Channel_of(values)
+}
+
+// the bounds can be values of the parent select, which are only known once its row is
+func range_channel(table_name string, index_range byte_code.Index_range, parent_context option.Option[*state_full_byte_code.Row_context]) pubsub.ObservableI {
+	table := db_tables.Tables.Get(table_name)
+	bound := func(bound option.Option[byte_code.Index_bound]) (option.Option[pubsub.Bound], bool) {
+		if bound.IsNone() {
+			return option.None[pubsub.Bound](), true
+		}
+		value := bound.Unwrap().Value
+		if location, is_location := value.(byte_code.Runtime_value_relative_location); is_location {
+			value = parent_context.Unwrap().Get_value(location)
+		}
+		return option.Some(pubsub.Bound{Value: value, Inclusive: bound.Unwrap().Inclusive}), value != nil
+	}
+	low, low_ok := bound(index_range.Low)
+	high, high_ok := bound(index_range.High)
+	if !low_ok || !high_ok {
+		//nothing is in a range that ends at null, so there are no rows (this channel is never attached to the table)
+		return pubsub.NewChannel(table.R_Table)
+	}
+	return table.Index_on(index_range.Col).Range_channel(low, high)
+}
+
 func select_byte_code_to_observable(select_byte_code byte_code.Select, parent_context option.Option[*state_full_byte_code.Row_context], row_schema rowType.RowSchema) pubsub.ObservableI {
 	var current_observable pubsub.ObservableI
-	if select_byte_code.Col_and_value_to_index_by.Col != "" {
-		//ints are cast to strings when placed and queried from indexes
-		channel_value := select_byte_code.Col_and_value_to_index_by.Value
-		switch channel_value := channel_value.(type) {
-		case byte_code.Runtime_value_relative_location:
-			tracked_channel_value := parent_context.Unwrap().Get_value(channel_value)
-			current_observable = db_tables.Tables.Get(select_byte_code.Table_name).Index_on(select_byte_code.Col_and_value_to_index_by.Col).Get_or_create_channel_not_with_row(String_or_num_to_string(tracked_channel_value))
-		case string:
-			current_observable = db_tables.Tables.Get(select_byte_code.Table_name).Index_on(select_byte_code.Col_and_value_to_index_by.Col).Get_or_create_channel_not_with_row(channel_value)
-		case int:
-			int_str := strconv.Itoa(channel_value)
-			current_observable = db_tables.Tables.Get(select_byte_code.Table_name).Index_on(select_byte_code.Col_and_value_to_index_by.Col).Get_or_create_channel_not_with_row(int_str)
-		default:
-			//bools are not supported for indexing indexes
-			panic(fmt.Sprintf("%T %s", channel_value, channel_value))
-		}
-	} else {
-		current_observable = &db_tables.Tables.Get(select_byte_code.Table_name).R_Table
+	access_path := select_byte_code.Access_path
+	switch {
+	case select_byte_code.Cte.IsSome():
+		current_observable = cte_observable(select_byte_code.Cte.Unwrap())
+	case access_path.Kind == byte_code.Hash_channel:
+		current_observable = index_channel(select_byte_code.Table_name, access_path.Cols_and_values, parent_context)
+	case access_path.Kind == byte_code.Range_index:
+		current_observable = range_channel(select_byte_code.Table_name, access_path.Range.Unwrap(), parent_context)
+	default:
+		current_observable = db_tables.Tables.Get(select_byte_code.Table_name).R_Table
 	}
 
-	// Apply GROUP BY if specified (before Filter and Map)
-	if select_byte_code.Group_by_col_index.IsSome() {
-		current_observable = current_observable.GroupBy_on(select_byte_code.Group_by_col_index.Unwrap())
+	for _, join := range select_byte_code.Joins {
+		current_observable = join_on(current_observable, join)
 	}
 
-	current_observable = current_observable.Filter_on(func(row rowType.RowType) bool {
+	current_observable = pubsub.Filter_on(current_observable, func(row rowType.RowType) bool {
 		return filter(state_full_byte_code.Row_context{Row: row, Parent_context: parent_context}, select_byte_code.Wheres_byte_code)
-	}).Map_on(func(row rowType.RowType) rowType.RowType {
-		return map_over(state_full_byte_code.Row_context{Row: row, Parent_context: parent_context}, select_byte_code.Selected_values_byte_code, row_schema)
 	})
-	current_observable.(*pubsub.Mapper).RowSchema = option.Some(row_schema)
-	if select_byte_code.Group_by_col_index.IsSome() {
-		current_observable = current_observable.GroupBy_on(select_byte_code.Group_by_col_index.Unwrap())
+	for _, semi_join := range select_byte_code.Semi_joins {
+		current_observable = semi_join_on(current_observable, semi_join, parent_context)
+	}
+	if select_byte_code.Aggregation.IsSome() {
+		current_observable = aggregate(current_observable, select_byte_code, parent_context, row_schema)
+	} else {
+		mapper := pubsub.Map_on(current_observable, func(row rowType.RowType) rowType.RowType {
+			row_context := state_full_byte_code.Row_context{Row: row, Parent_context: parent_context}
+			mapped := map_over(row_context, select_byte_code.Selected_values_byte_code, row_schema)
+			for _, key_value := range select_byte_code.Join_key_values {
+				mapped = append(mapped, row_context.Eval(key_value))
+			}
+			return mapped
+		})
+		mapper.RowSchema = option.Some(row_schema)
+		mapper.Key_col = select_byte_code.Key_col_index
+		if len(select_byte_code.Join_key_values) > 0 {
+			mapper.Join_keys_from = len(select_byte_code.Selected_values_byte_code)
+		}
+		current_observable = mapper
+	}
+	if len(select_byte_code.Distinct_col_indexes) > 0 {
+		current_observable = pubsub.NewDistinct(current_observable, select_byte_code.Distinct_col_indexes)
+	}
+	for _, operation := range select_byte_code.Set_operations {
+		//the selects of a set operation have no nested selects, so the combined row schema works for each of them
+		operation_observable := select_byte_code_to_observable(operation.Select, parent_context, row_schema)
+		current_observable = pubsub.NewSet_operation(pubsub.Set_operation_kind(operation.Kind), operation.All, current_observable, operation_observable, row_schema)
+	}
+	if len(select_byte_code.Order_by) > 0 || select_byte_code.Limit.IsSome() || select_byte_code.Offset != 0 {
+		sort_keys := []pubsub.Sort_key{}
+		for _, order_by_col := range select_byte_code.Order_by {
+			sort_keys = append(sort_keys, pubsub.Sort_key{Col_index: order_by_col.Col_index, Desc: order_by_col.Desc})
+		}
+		current_observable = pubsub.NewOrderBy(current_observable, sort_keys, select_byte_code.Offset, select_byte_code.Limit)
+	}
+	if len(select_byte_code.Group_by_col_indexes) > 0 {
+		current_observable = pubsub.GroupBy_on(current_observable, select_byte_code.Group_by_col_indexes...)
 	}
 	return current_observable
 
 }
 
+var join_kinds = map[string]pubsub.Join_kind{
+	"INNER": pubsub.Inner_join,
+	"LEFT":  pubsub.Left_join,
+	"RIGHT": pubsub.Right_join,
+	"FULL":  pubsub.Full_join,
+}
+
+func join_on(current_observable pubsub.ObservableI, join byte_code.Join) *pubsub.Join {
+	left_key := func(row rowType.RowType) rowType.RowType {
+		row_context := state_full_byte_code.Row_context{Row: row}
+		key := rowType.RowType{}
+		for _, value := range join.Left_key {
+			key = append(key, row_context.Eval(value))
+		}
+		return key
+	}
+	right_key := func(row rowType.RowType) rowType.RowType {
+		key := rowType.RowType{}
+		for _, col_index := range join.Right_key {
+			key = append(key, row[col_index])
+		}
+		return key
+	}
+	var joined pubsub.ObservableI = db_tables.Tables.Get(join.Table_name).R_Table
+	if join.Cte.IsSome() {
+		joined = cte_observable(join.Cte.Unwrap())
+	}
+	return pubsub.NewJoin(join_kinds[join.Kind], current_observable, joined, left_key, right_key)
+}
+
+// the rows of a WITH query, a recursive one joins the rows it has so far with the table in its Step (as the join of the Step would), until no new rows come from that
+func cte_observable(cte *byte_code.Cte) pubsub.ObservableI {
+	anchor := select_byte_code_to_observable(cte.Select, option.None[*state_full_byte_code.Row_context](), cte.Row_schema)
+	if cte.Recursion.IsNone() {
+		return anchor
+	}
+	recursion := cte.Recursion.Unwrap()
+	join := recursion.Step.Joins[0]
+	table_name := recursion.Step.Table_name
+	if recursion.Cte_first {
+		table_name = join.Table_name
+	}
+	//the Left_key is evaluated on the first of the two rows and the Right_key indexes into the second
+	first_key := func(row rowType.RowType) rowType.RowType {
+		row_context := state_full_byte_code.Row_context{Row: row}
+		key := rowType.RowType{}
+		for _, value := range join.Left_key {
+			key = append(key, row_context.Eval(value))
+		}
+		return key
+	}
+	second_key := func(row rowType.RowType) rowType.RowType {
+		key := rowType.RowType{}
+		for _, col_index := range join.Right_key {
+			key = append(key, row[col_index])
+		}
+		return key
+	}
+	edge_key, row_key := first_key, second_key
+	if recursion.Cte_first {
+		edge_key, row_key = second_key, first_key
+	}
+	step := func(edge rowType.RowType, row rowType.RowType) (rowType.RowType, bool) {
+		joined := slices.Concat(edge, row)
+		if recursion.Cte_first {
+			joined = slices.Concat(row, edge)
+		}
+		row_context := state_full_byte_code.Row_context{Row: joined}
+		if !filter(row_context, recursion.Step.Wheres_byte_code) {
+			return nil, false
+		}
+		return map_over(row_context, recursion.Step.Selected_values_byte_code, cte.Row_schema), true
+	}
+	return pubsub.NewRecursive_union(recursion.All, anchor, db_tables.Tables.Get(table_name).R_Table, edge_key, row_key, step, cte.Row_schema)
+}
+
+// the subquery doesn't depend on the row (its correlated columns are part of the keys instead), so it is a single observable whose rows are matched against each row's Left_key
+func semi_join_on(current_observable pubsub.ObservableI, semi_join byte_code.Semi_join, parent_context option.Option[*state_full_byte_code.Row_context]) *pubsub.Semi_join {
+	left_key := func(row rowType.RowType) rowType.RowType {
+		row_context := state_full_byte_code.Row_context{Row: row, Parent_context: parent_context}
+		key := rowType.RowType{}
+		for _, value := range semi_join.Left_key {
+			key = append(key, row_context.Eval(value))
+		}
+		return key
+	}
+	right_key := func(row rowType.RowType) rowType.RowType {
+		return row
+	}
+	subquery := select_byte_code_to_observable(semi_join.Select, option.None[*state_full_byte_code.Row_context](), semi_join.Row_schema)
+	return pubsub.NewSemi_join(semi_join.Anti, semi_join.Is_in, current_observable, subquery, left_key, right_key)
+}
+
+// folds the filtered rows into their groups' aggregates, HAVING and the selected values are then evaluated on each group row (its parent being the same as the table rows' parent, so correlated columns still resolve)
+func aggregate(source pubsub.ObservableI, select_byte_code byte_code.Select, parent_context option.Option[*state_full_byte_code.Row_context], row_schema rowType.RowSchema) *pubsub.Aggregate {
+	aggregation := select_byte_code.Aggregation.Unwrap()
+	var group_by func(rowType.RowType) rowType.RowType
+	if len(aggregation.Group_by) > 0 {
+		group_by = func(row rowType.RowType) rowType.RowType {
+			row_context := state_full_byte_code.Row_context{Row: row, Parent_context: parent_context}
+			key_values := rowType.RowType{}
+			for _, col := range aggregation.Group_by {
+				key_values = append(key_values, row_context.Eval(col))
+			}
+			return key_values
+		}
+	}
+	inputs := []pubsub.Aggregate_input{}
+	for _, call := range aggregation.Aggregates {
+		input := pubsub.Aggregate_input{Func: call.Func}
+		if call.Arg != nil {
+			input.Arg = func(row rowType.RowType) any {
+				row_context := state_full_byte_code.Row_context{Row: row, Parent_context: parent_context}
+				return row_context.Eval(call.Arg)
+			}
+		}
+		inputs = append(inputs, input)
+	}
+	var having func(rowType.RowType) bool
+	if len(aggregation.Having) > 0 {
+		having = func(group_row rowType.RowType) bool {
+			return filter(state_full_byte_code.Row_context{Row: group_row, Parent_context: parent_context}, aggregation.Having)
+		}
+	}
+	return pubsub.NewAggregate(source, group_by, inputs, having, func(group_row rowType.RowType) rowType.RowType {
+		return map_over(state_full_byte_code.Row_context{Row: group_row, Parent_context: parent_context}, select_byte_code.Selected_values_byte_code, row_schema)
+	}, row_schema)
+}
+
 func Query_to_observer(src string) pubsub.ObservableI {
 	l := tokenizer.NewLexer(src)
 	parser := parser.Parser{Tokens: l.Tokenize()}
@@ -154,6 +288,7 @@ func Query_to_observer(src string) pubsub.ObservableI {
 		fmt.Printf("%-8s %q @%d\n", t.Type, t.Literal, t.Pos)
 	}
 	select_ := parser.Parse_Select()
+	select_.Resolve_ctes(nil)
 	select_.Recursively_link_children()
 	// display.DisplayStruct(select_)
 	compiler.Recursively_set_selects_row_schema(&select_)
@@ -162,8 +297,27 @@ func Query_to_observer(src string) pubsub.ObservableI {
 	display.DisplayStruct(select_byte_code)
 
 	obs := select_byte_code_to_observable(select_byte_code, option.None[*state_full_byte_code.Row_context](), select_.Row_schema)
-	obs.To_display(option.Some(select_.Row_schema))
+	pubsub.To_display(obs, option.Some(select_.Row_schema))
 	fmt.Printf("type %s=%s\n", utils.Capitalize(select_.Table), select_.Row_schema.To_string(0))
 
 	return obs
 }
+
+// makes a go function callable from queries as name(args...), what it takes and returns comes from its signature, like func(title string) string
+// (see functions.Register_go_function for how nulls are passed), functions have to be registered before the queries that use them are compiled
+func Register_function(name string, go_function any) {
+	if compiler.Is_aggregate_function(name) {
+		panic(name + " is an aggregate function")
+	}
+	functions.Register_go_function(name, go_function)
+}
+
+// makes an aggregate callable from queries as name(arg), result_type gives the type of what it returns for the type of its argument (panicking when it can't take that type)
+// and new_aggregator makes the aggregator each group keeps its state in, aggregates have to be registered before the queries that use them are compiled
+func Register_aggregate(name string, result_type func(arg_type rowType.DataType) rowType.DataType, new_aggregator func() pubsub.Aggregator) {
+	if _, is_function := functions.Get(name); is_function {
+		panic(name + " is a scalar function")
+	}
+	compiler.Register_aggregate_type(name, result_type)
+	pubsub.Register_aggregate(name, new_aggregator)
+}
@@ -2,13 +2,57 @@ package compiler_runtime
 
 import (
 	"encoding/json"
+	"maps"
+	"os"
+	"slices"
+	"sql-compiler/assert"
 	"sql-compiler/compare"
 	"sql-compiler/compiler/rowType"
 	"sql-compiler/db_tables"
+	event_emitter_tree "sql-compiler/eventEmitterTree"
+	"sql-compiler/local_live_db"
 	pubsub "sql-compiler/pub_sub"
+	"sql-compiler/utils"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
+// the tables only the tests select from
+func TestMain(m *testing.M) {
+	db_tables.Tables.Add("event", db_tables.NewTable("event", rowType.RowSchema{{Name: "name", Type: rowType.String}, {Name: "at", Type: rowType.Timestamp}, {Name: "score", Type: rowType.Float}, {Name: "details", Type: rowType.Json, Nullable: true}, {Name: "id", Type: rowType.Int}}))
+	db_tables.Tables.Get("event").Index_on("at", pubsub.Ordered)
+	db_tables.Tables.Add("comment", db_tables.NewTable("comment", rowType.RowSchema{{Name: "text", Type: rowType.String}, {Name: "parent_id", Type: rowType.Int, Nullable: true}, {Name: "id", Type: rowType.Int}},
+		db_tables.Primary_key("id"), db_tables.Auto_increment("id")))
+	os.Exit(m.Run())
+}
+
+// keeps a local copy of what obs holds up to date through the messages the tree emits
+func live_sync(t *testing.T, obs pubsub.ObservableI, on_message ...func(event_emitter_tree.SyncMessage)) *local_live_db.LocalLiveDB {
+	db := &local_live_db.LocalLiveDB{Data: map[string]any{}}
+	tree := event_emitter_tree.EventEmitterTree{On_message: func(message event_emitter_tree.SyncMessage) {
+		for _, listen := range on_message {
+			listen(message)
+		}
+		if err := db.HandleUpdate(message); err != nil {
+			t.Fatal(err)
+		}
+	}}
+	json.Unmarshal([]byte(pubsub.ObserverToJson(obs, obs.GetRowSchema())), &db.Data)
+	tree.SyncFromObservable(obs, "")
+	return db
+}
+
+func check_data(t *testing.T, expected map[string]any, actual map[string]any) {
+	t.Helper()
+	std_message, err := compare.Compare(expected, actual, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+}
+
 func TestObservedLists(t *testing.T) {
 	// defer func() {
 	// 	if r := recover(); r != nil {
@@ -18,8 +62,8 @@ func TestObservedLists(t *testing.T) {
 	src := `SELECT person.name, person.email, person.id FROM person `
 	people := db_tables.Tables.Get("person")
 	id := len(people.R_Table.Rows)
-	people.Insert(rowType.RowType{"example-name", "example-email", 23, "state", id})
-	people.Insert(rowType.RowType{"example-name-2", "example-email-2", 23, "state-2", id})
+	people.Insert(rowType.RowType{"example-name", "example-email", 23, "state", id, "profile-picture"})
+	people.Insert(rowType.RowType{"example-name-2", "example-email-2", 23, "state-2", id, "profile-picture-2"})
 
 	obs := Query_to_observer(src)
 
@@ -47,3 +91,1099 @@ func TestObservedLists(t *testing.T) {
 	}
 	t.Log(std_message)
 }
+
+func TestOrAndNotInWhere(t *testing.T) {
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"private and open", "", false, 1, false, 100})
+	todos.Insert(rowType.RowType{"public and done", "", true, 1, true, 101})
+	todos.Insert(rowType.RowType{"private and done", "", true, 1, false, 102})
+	todos.Insert(rowType.RowType{"public and open", "", false, 2, true, 103})
+
+	obs := Query_to_observer(`SELECT title, id FROM todo WHERE (done == false OR is_public == true) AND NOT person_id == 2 `)
+
+	expected := map[string]any{
+		"private and open": map[string]any{"title": "private and open", "id": 100},
+		"public and done":  map[string]any{"title": "public and done", "id": 101},
+	}
+
+	json_string := pubsub.ObserverToJson(obs, obs.GetRowSchema())
+	var actual map[string]any
+	json.Unmarshal([]byte(json_string), &actual)
+
+	std_message, err := compare.Compare(expected, actual, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+}
+
+func TestOrInCorrelatedSubquery(t *testing.T) {
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"or-tester", "or-tester-email", 40, "state", 200, "profile-picture"})
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"or-tester open", "", false, 200, false, 201})
+	todos.Insert(rowType.RowType{"or-tester public", "", true, 200, true, 202})
+	todos.Insert(rowType.RowType{"or-tester hidden", "", true, 200, false, 203})
+	todos.Insert(rowType.RowType{"someone elses", "", false, 1, false, 204})
+
+	obs := Query_to_observer(`SELECT person.name, (
+		SELECT todo.title FROM todo WHERE todo.person_id == person.id AND (todo.done == false OR todo.is_public == true)
+	) AS todos FROM person WHERE person.name == "or-tester" `)
+
+	expected := map[string]any{
+		"or-tester": map[string]any{
+			"name": "or-tester",
+			"todos": map[string]any{
+				"or-tester open":   map[string]any{"title": "or-tester open"},
+				"or-tester public": map[string]any{"title": "or-tester public"},
+			},
+		},
+	}
+
+	json_string := pubsub.ObserverToJson(obs, obs.GetRowSchema())
+	var actual map[string]any
+	json.Unmarshal([]byte(json_string), &actual)
+
+	std_message, err := compare.Compare(expected, actual, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+}
+
+func TestOrderByLimitOffset(t *testing.T) {
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"third", "", false, 300, false, 303})
+	todos.Insert(rowType.RowType{"first", "", false, 300, false, 301})
+	todos.Insert(rowType.RowType{"fourth", "", false, 300, false, 304})
+	todos.Insert(rowType.RowType{"second", "", false, 300, false, 302})
+
+	obs := Query_to_observer(`SELECT title, id FROM todo WHERE todo.person_id == 300 ORDER BY id DESC LIMIT 2 OFFSET 1 `)
+	titles := func() []string {
+		res := []string{}
+		for row := range obs.Pull {
+			res = append(res, row[0].(string))
+		}
+		return res
+	}
+	assert.TAssert(t, utils.CompareSlices(titles(), []string{"third", "second"}))
+
+	todos.Insert(rowType.RowType{"fifth", "", false, 300, false, 305})
+	assert.TAssert(t, utils.CompareSlices(titles(), []string{"fourth", "third"}))
+
+	defer func() {
+		assert.TAssert(t, recover() == "ORDER BY column id must be one of the selected columns")
+	}()
+	Query_to_observer(`SELECT title FROM todo WHERE todo.person_id == 300 ORDER BY id DESC LIMIT 2 `)
+}
+
+func TestAggregates(t *testing.T) {
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"aggregate-1", "", 20, "aggregate-state", 400, ""})
+	people.Insert(rowType.RowType{"aggregate-2", "", 30, "aggregate-state", 401, ""})
+
+	obs := Query_to_observer(`SELECT COUNT(*), AVG(age) AS average_age, MIN(name), MAX(age) FROM person WHERE state == "aggregate-state" `)
+	current := func() map[string]any {
+		var actual map[string]any
+		json.Unmarshal([]byte(pubsub.ObserverToJson(obs, obs.GetRowSchema())), &actual)
+		return actual
+	}
+
+	expected := map[string]any{"0": map[string]any{"count": 2, "average_age": 25, "min": "aggregate-1", "max": 30}}
+	std_message, err := compare.Compare(expected, current(), "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+
+	people.Insert(rowType.RowType{"aggregate-0", "", 40, "aggregate-state", 402, ""})
+	expected = map[string]any{"0": map[string]any{"count": 3, "average_age": 30, "min": "aggregate-0", "max": 40}}
+	std_message, err = compare.Compare(expected, current(), "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+}
+
+func TestCorrelatedScalarAggregate(t *testing.T) {
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"counted", "", 50, "state", 500, ""})
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"counted 1", "", false, 500, false, 501})
+
+	obs := Query_to_observer(`SELECT person.name, (SELECT COUNT(*) FROM todo WHERE todo.person_id == person.id) AS todo_count FROM person WHERE person.name == "counted" `)
+	count_messages := 0
+	db := live_sync(t, obs, func(message event_emitter_tree.SyncMessage) {
+		if message.Path == "/counted/todo_count" {
+			count_messages++
+		}
+	})
+
+	expected := map[string]any{"counted": map[string]any{"name": "counted", "todo_count": 1}}
+	check_data(t, expected, db.Data)
+
+	todos.Insert(rowType.RowType{"counted 2", "", false, 500, false, 502})
+	todos.Insert(rowType.RowType{"not counted", "", false, 1, false, 503})
+	expected = map[string]any{"counted": map[string]any{"name": "counted", "todo_count": 2}}
+	check_data(t, expected, db.Data)
+
+	//after the parent row is updated only the count of its new row is synced
+	people.R_Table.Update_field_where_eq(people.Columns, "id", 500, 2, 51)
+	count_messages = 0
+	todos.Insert(rowType.RowType{"counted 3", "", false, 500, false, 504})
+	assert.TAssert(t, count_messages == 1)
+	expected = map[string]any{"counted": map[string]any{"name": "counted", "todo_count": 3}}
+	check_data(t, expected, db.Data)
+
+	//and once its removed the count isn't synced to a path that's gone
+	people.R_Table.Remove_where_eq(people.Columns, "id", 500)
+	todos.Insert(rowType.RowType{"counted 4", "", false, 500, false, 505})
+	check_data(t, map[string]any{}, db.Data)
+}
+
+func TestGroupByHaving(t *testing.T) {
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"having-1", "", 20, "having-state", 600, ""})
+	people.Insert(rowType.RowType{"having-2", "", 20, "having-state", 601, ""})
+	people.Insert(rowType.RowType{"having-3", "", 30, "having-state", 602, ""})
+
+	obs := Query_to_observer(`SELECT age, COUNT(*) AS n, MAX(name) FROM person WHERE state == "having-state" GROUP BY age HAVING COUNT(*) > 1 `)
+	db := live_sync(t, obs)
+
+	expected := map[string]any{"20": map[string]any{"age": 20, "n": 2, "max": "having-2"}}
+	check_data(t, expected, db.Data)
+
+	people.Insert(rowType.RowType{"having-4", "", 30, "having-state", 603, ""})
+	people.Insert(rowType.RowType{"having-5", "", 20, "having-state", 604, ""})
+	expected = map[string]any{
+		"20": map[string]any{"age": 20, "n": 3, "max": "having-5"},
+		"30": map[string]any{"age": 30, "n": 2, "max": "having-4"},
+	}
+	check_data(t, expected, db.Data)
+}
+
+func TestMultiColumnGroupBy(t *testing.T) {
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"multi-1", "", 20, "multi-a", 700, ""})
+	people.Insert(rowType.RowType{"multi-2", "", 20, "multi-a", 701, ""})
+	people.Insert(rowType.RowType{"multi-3", "", 20, "multi-b", 702, ""})
+
+	aggregated := Query_to_observer(`SELECT state, age, COUNT(*) AS n FROM person WHERE age == 20 AND id >= 700 GROUP BY state, age `)
+	live := live_sync(t, aggregated)
+	//the values of a group are one path segment, so the group's row isn't nested under its first value
+	expected := map[string]any{
+		"multi-a,20": map[string]any{"state": "multi-a", "age": 20, "n": 2},
+		"multi-b,20": map[string]any{"state": "multi-b", "age": 20, "n": 1},
+	}
+	check_data(t, expected, live.Data)
+	people.Insert(rowType.RowType{"multi-5", "", 20, "multi-b", 704, ""})
+	people.Insert(rowType.RowType{"multi-6", "", 20, "multi/c", 705, ""})
+	people.R_Table.Remove_where_eq(people.Columns, "id", 700)
+	expected = map[string]any{
+		"multi-a,20":   map[string]any{"state": "multi-a", "age": 20, "n": 1},
+		"multi-b,20":   map[string]any{"state": "multi-b", "age": 20, "n": 2},
+		"multi%2Fc,20": map[string]any{"state": "multi/c", "age": 20, "n": 1},
+	}
+	check_data(t, expected, live.Data)
+	fresh := map[string]any{}
+	json.Unmarshal([]byte(pubsub.ObserverToJson(aggregated, aggregated.GetRowSchema())), &fresh)
+	check_data(t, fresh, live.Data)
+
+	//groups can have the same row and are still kept apart
+	counts := Query_to_observer(`SELECT COUNT(*) AS n FROM person WHERE age == 20 AND id >= 700 GROUP BY state, age `)
+	live_counts := live_sync(t, counts)
+	people.Insert(rowType.RowType{"multi-7", "", 20, "multi/c", 706, ""})
+	expected = map[string]any{
+		"multi-a,20":   map[string]any{"n": 1},
+		"multi-b,20":   map[string]any{"n": 2},
+		"multi%2Fc,20": map[string]any{"n": 2},
+	}
+	check_data(t, expected, live_counts.Data)
+
+	pathed := Query_to_observer(`SELECT id, state, age FROM person WHERE id >= 700 GROUP BY state, age `)
+	//the json of a group by isn't nested under the groups like its paths are, so only the changes are synced here
+	db := local_live_db.LocalLiveDB{Data: map[string]any{}}
+	tree := event_emitter_tree.EventEmitterTree{On_message: func(message event_emitter_tree.SyncMessage) {
+		if err := db.HandleUpdate(message); err != nil {
+			t.Fatal(err)
+		}
+	}}
+	tree.SyncFromObservable(pathed, "")
+	people.Insert(rowType.RowType{"multi-4", "", 21, "multi-b", 703, ""})
+	expected = map[string]any{
+		"multi-b": map[string]any{"21": map[string]any{"703": map[string]any{"id": 703, "state": "multi-b", "age": 21}}},
+	}
+	check_data(t, expected, db.Data)
+}
+
+func TestJoins(t *testing.T) {
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"joiner", "", 30, "state", 800, ""})
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"joined todo", "", false, 800, false, 801})
+	todos.Insert(rowType.RowType{"other joined todo", "", false, 800, false, 802})
+	tags := db_tables.Tables.Get("tag")
+	tags.Insert(rowType.RowType{"join-urgent", 810})
+	tags.Insert(rowType.RowType{"join-home", 811})
+	todo_tags := db_tables.Tables.Get("todo_tag")
+	todo_tags.Insert(rowType.RowType{801, 810})
+
+	owners := Query_to_observer(`SELECT t.title, p.name AS owner FROM todo t JOIN person AS p ON t.person_id == p.id WHERE p.name == "joiner" `)
+	var actual map[string]any
+	json.Unmarshal([]byte(pubsub.ObserverToJson(owners, owners.GetRowSchema())), &actual)
+	//a row of a join is keyed by the key of each of its tables
+	expected := map[string]any{
+		"joined todo,joiner":       map[string]any{"title": "joined todo", "owner": "joiner"},
+		"other joined todo,joiner": map[string]any{"title": "other joined todo", "owner": "joiner"},
+	}
+	std_message, err := compare.Compare(expected, actual, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+
+	tagged := Query_to_observer(`SELECT tag.name, todo.title FROM todo_tag JOIN todo ON todo_tag.todo_id == todo.id INNER JOIN tag ON todo_tag.tag_id == tag.id WHERE todo.person_id == 800 `)
+	names := func() []string {
+		res := []string{}
+		for row := range tagged.Pull {
+			res = append(res, row[0].(string)+" "+row[1].(string))
+		}
+		return res
+	}
+	assert.TAssert(t, utils.CompareSlices(names(), []string{"join-urgent joined todo"}))
+
+	todo_tags.Insert(rowType.RowType{802, 811})
+	todo_tags.Insert(rowType.RowType{801, 811})
+	assert.TAssert(t, utils.CompareSlices(names(), []string{"join-urgent joined todo", "join-home other joined todo", "join-home joined todo"}))
+}
+
+func TestLeftJoinPadsWithNulls(t *testing.T) {
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"left-with", "", 30, "left-join-state", 900, ""})
+	people.Insert(rowType.RowType{"left-without", "", 30, "left-join-state", 901, ""})
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"left todo", "", false, 900, false, 902})
+
+	obs := Query_to_observer(`SELECT p.name, t.title FROM person p LEFT JOIN todo t ON t.person_id == p.id WHERE p.state == "left-join-state" `)
+	db := live_sync(t, obs)
+	check_data(t, map[string]any{
+		"left-with,left todo": map[string]any{"name": "left-with", "title": "left todo"},
+		"left-without,null":   map[string]any{"name": "left-without", "title": nil},
+	}, db.Data)
+
+	//the padded row is stored under another key once it has a match
+	todos.Insert(rowType.RowType{"found a todo", "", false, 901, false, 903})
+	check_data(t, map[string]any{
+		"left-with,left todo":       map[string]any{"name": "left-with", "title": "left todo"},
+		"left-without,found a todo": map[string]any{"name": "left-without", "title": "found a todo"},
+	}, db.Data)
+}
+
+func TestJoinedRowsKeyedByEachTable(t *testing.T) {
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"one to many", "", false, 2400, false, 2401})
+	tags := db_tables.Tables.Get("tag")
+	tags.Insert(rowType.RowType{"one-to-many-red", 2410})
+	tags.Insert(rowType.RowType{"one-to-many-blue", 2411})
+	todo_tags := db_tables.Tables.Get("todo_tag")
+	todo_tags.Insert(rowType.RowType{2401, 2410})
+
+	obs := Query_to_observer(`SELECT todo.title, tag.name FROM todo JOIN todo_tag ON todo_tag.todo_id == todo.id JOIN tag ON tag.id == todo_tag.tag_id WHERE todo.id == 2401 `)
+	db := live_sync(t, obs)
+	fresh := func() map[string]any {
+		var res map[string]any
+		json.Unmarshal([]byte(pubsub.ObserverToJson(obs, obs.GetRowSchema())), &res)
+		return res
+	}
+	red := map[string]any{"title": "one to many", "name": "one-to-many-red"}
+	blue := map[string]any{"title": "one to many", "name": "one-to-many-blue"}
+	check_data(t, map[string]any{"one to many,2401,one-to-many-red": red}, db.Data)
+
+	//a second match of the same todo is a row of its own
+	todo_tags.Insert(rowType.RowType{2401, 2411})
+	check_data(t, map[string]any{"one to many,2401,one-to-many-red": red, "one to many,2401,one-to-many-blue": blue}, db.Data)
+	check_data(t, fresh(), db.Data)
+	todo_tags.R_Table.Remove_where_eq(todo_tags.Columns, "tag_id", 2410)
+	check_data(t, map[string]any{"one to many,2401,one-to-many-blue": blue}, db.Data)
+	check_data(t, fresh(), db.Data)
+
+	//the tables' declared primary keys are used instead of their first columns
+	db_tables.Tables.Add("writer", db_tables.NewTable("writer", rowType.RowSchema{{Name: "name", Type: rowType.String}, {Name: "id", Type: rowType.Int}}, db_tables.Primary_key("id")))
+	db_tables.Tables.Add("article", db_tables.NewTable("article", rowType.RowSchema{{Name: "title", Type: rowType.String}, {Name: "writer_id", Type: rowType.Int}, {Name: "id", Type: rowType.Int}}, db_tables.Primary_key("id")))
+	writers, articles := db_tables.Tables.Get("writer"), db_tables.Tables.Get("article")
+	writers.Insert(rowType.RowType{"ann", 1})
+	articles.Insert(rowType.RowType{"first", 1, 5})
+	articles.Insert(rowType.RowType{"first", 1, 6})
+	by_writer := live_sync(t, Query_to_observer(`SELECT w.name, a.title FROM writer w JOIN article a ON a.writer_id == w.id `))
+	check_data(t, map[string]any{
+		"1,5": map[string]any{"name": "ann", "title": "first"},
+		"1,6": map[string]any{"name": "ann", "title": "first"},
+	}, by_writer.Data)
+	articles.R_Table.Update_field_where_eq(articles.Columns, "id", 6, 0, "second")
+	writers.R_Table.Update_field_where_eq(writers.Columns, "id", 1, 0, "anne")
+	check_data(t, map[string]any{
+		"1,5": map[string]any{"name": "anne", "title": "first"},
+		"1,6": map[string]any{"name": "anne", "title": "second"},
+	}, by_writer.Data)
+}
+
+func TestNulls(t *testing.T) {
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"null described", nil, false, 1000, false, 1001})
+	todos.Insert(rowType.RowType{"described", "a description", false, 1000, false, 1002})
+
+	is_null := Query_to_observer(`SELECT title, description FROM todo WHERE description IS NULL AND person_id == 1000 `)
+	var actual map[string]any
+	json.Unmarshal([]byte(pubsub.ObserverToJson(is_null, is_null.GetRowSchema())), &actual)
+	expected := map[string]any{
+		"null described": map[string]any{"title": "null described", "description": nil},
+	}
+	std_message, err := compare.Compare(expected, actual, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+
+	//comparing with null is unknown, and so is NOT of it, so the null row isn't in either of these
+	titles := func(obs pubsub.ObservableI) []string {
+		res := []string{}
+		for row := range obs.Pull {
+			res = append(res, row[0].(string))
+		}
+		return res
+	}
+	not_equal := Query_to_observer(`SELECT title FROM todo WHERE person_id == 1000 AND NOT description == "something else" `)
+	assert.TAssert(t, utils.CompareSlices(titles(not_equal), []string{"described"}))
+	equal := Query_to_observer(`SELECT title FROM todo WHERE person_id == 1000 AND description == NULL `)
+	assert.TAssert(t, utils.CompareSlices(titles(equal), []string{}))
+	is_not_null := Query_to_observer(`SELECT title FROM todo WHERE person_id == 1000 AND (description IS NOT NULL OR done == true) `)
+	assert.TAssert(t, utils.CompareSlices(titles(is_not_null), []string{"described"}))
+
+	//this one reads the rows straight from the null channel of the description index
+	null_channel := Query_to_observer(`SELECT title FROM todo WHERE description IS NULL `)
+	assert.TAssert(t, utils.CompareSlices(titles(null_channel), []string{"null described"}))
+
+	todos.Insert(rowType.RowType{"also null described", nil, false, 1000, false, 1003})
+	assert.TAssert(t, utils.CompareSlices(titles(null_channel), []string{"null described", "also null described"}))
+	assert.TAssert(t, utils.CompareSlices(titles(is_null), []string{"null described", "also null described"}))
+	assert.TAssert(t, utils.CompareSlices(titles(not_equal), []string{"described"}))
+}
+
+func TestFloatTimestampJsonColumns(t *testing.T) {
+	events := db_tables.Tables.Get("event")
+	events.Insert(rowType.RowType{"launch", time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC), 2.5, json.RawMessage(`{"rocket":"big","crew":[1,2]}`), 1})
+	events.Insert(rowType.RowType{"too early", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 9.0, nil, 2})
+	events.Insert(rowType.RowType{"too low", time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), 0.5, nil, 3})
+
+	obs := Query_to_observer(`SELECT name, at, score, details FROM event WHERE at >= "2024-01-02" AND score > 1 `)
+	assert.TAssert(t, strings.Contains(obs.GetRowSchema().To_string(0), "at:string\n"))
+	assert.TAssert(t, strings.Contains(obs.GetRowSchema().To_string(0), "details:unknown | null\n"))
+	var actual map[string]any
+	json.Unmarshal([]byte(pubsub.ObserverToJson(obs, obs.GetRowSchema())), &actual)
+	expected := map[string]any{
+		"launch": map[string]any{"name": "launch", "at": "2024-01-03T10:00:00Z", "score": 2.5, "details": map[string]any{"rocket": "big", "crew": []any{1.0, 2.0}}},
+	}
+	std_message, err := compare.Compare(expected, actual, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+
+	//the string is read as a timestamp to find the channel of the index on at
+	at := Query_to_observer(`SELECT name FROM event WHERE at == "2024-01-04T00:00:00Z" `)
+	names := func() []string {
+		res := []string{}
+		for row := range at.Pull {
+			res = append(res, row[0].(string))
+		}
+		return res
+	}
+	assert.TAssert(t, utils.CompareSlices(names(), []string{"too low"}))
+	events.Insert(rowType.RowType{"same time", time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), 3.0, json.RawMessage(`[]`), 4})
+	assert.TAssert(t, utils.CompareSlices(names(), []string{"too low", "same time"}))
+}
+
+func TestJsonColumnUpdate(t *testing.T) {
+	events := db_tables.Tables.Get("event")
+	events.Insert(rowType.RowType{"json update", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), 1.0, json.RawMessage(`{"v":1}`), 5})
+
+	obs := Query_to_observer(`SELECT name, details FROM event ORDER BY name `)
+	db := live_sync(t, obs)
+
+	//a json value is a slice of bytes, so the rows holding one can't be compared with ==
+	events.R_Table.Update_field_where_eq(events.Columns, "id", 5, 3, json.RawMessage(`{"v":2}`))
+	fresh := map[string]any{}
+	json.Unmarshal([]byte(pubsub.ObserverToJson(obs, obs.GetRowSchema())), &fresh)
+	assert.TAssert(t, fresh["json update"].(map[string]any)["details"].(map[string]any)["v"] == 2.0)
+	check_data(t, fresh, db.Data)
+}
+
+func TestArithmetic(t *testing.T) {
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"arith-young", "", 20, "arith-state", 1100, ""})
+	people.Insert(rowType.RowType{"arith-old", "", 41, "arith-state", 1101, ""})
+
+	obs := Query_to_observer(`SELECT name, age + 1 AS next_age, age / 2 AS half, age * 1.5 AS older FROM person WHERE state == "arith-state" AND (age + 1) * 2 > 50 `)
+	var actual map[string]any
+	json.Unmarshal([]byte(pubsub.ObserverToJson(obs, obs.GetRowSchema())), &actual)
+	expected := map[string]any{
+		"arith-old": map[string]any{"name": "arith-old", "next_age": 42, "half": 20, "older": 61.5},
+	}
+	std_message, err := compare.Compare(expected, actual, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+	assert.TAssert(t, strings.Contains(obs.GetRowSchema().To_string(0), "half:number | null\n"))
+
+	average := Query_to_observer(`SELECT SUM(age) * 10 / COUNT(*) AS scaled_average FROM person WHERE state == "arith-state" `)
+	scaled_average := func() any {
+		for row := range average.Pull {
+			return row[0]
+		}
+		return nil
+	}
+	assert.TAssert(t, scaled_average() == 305)
+	people.Insert(rowType.RowType{"arith-third", "", 2, "arith-state", 1102, ""})
+	assert.TAssert(t, scaled_average() == 210)
+}
+
+func TestScalarFunctions(t *testing.T) {
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"  Buy Milk ", nil, false, 1200, false, 1201})
+	todos.Insert(rowType.RowType{"Walk the dog", "around the park", true, 1200, true, 1202})
+
+	obs := Query_to_observer(`SELECT id, LOWER(TRIM(title)) AS slug, CONCAT(SUBSTR(title, 1, 4), "-", id) AS code, COALESCE(description, "no description") AS description,
+		CASE WHEN done == true THEN "done" WHEN is_public == true THEN "shared" ELSE "open" END AS status, CAST(id AS text) AS id_text, ROUND(id / 7.0, 2) AS ratio
+		FROM todo WHERE person_id == 1200 AND LENGTH(title) > 5 ORDER BY id `)
+	var actual map[string]any
+	json.Unmarshal([]byte(pubsub.ObserverToJson(obs, obs.GetRowSchema())), &actual)
+	expected := map[string]any{
+		"1201": map[string]any{"id": 1201, "slug": "buy milk", "code": "  Bu-1201", "description": "no description", "status": "open", "id_text": "1201", "ratio": 171.57},
+		"1202": map[string]any{"id": 1202, "slug": "walk the dog", "code": "Walk-1202", "description": "around the park", "status": "done", "id_text": "1202", "ratio": 171.71},
+	}
+	std_message, err := compare.Compare(expected, actual, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+
+	//a NULL argument is of the type of the other arguments
+	with_null := Query_to_observer(`SELECT id, COALESCE(NULL, description, title) AS text, NULLIF(description, NULL) AS same FROM todo WHERE person_id == 1200 `)
+	assert.TAssert(t, strings.Contains(with_null.GetRowSchema().To_string(0), "text:string\n"))
+	actual = map[string]any{}
+	json.Unmarshal([]byte(pubsub.ObserverToJson(with_null, with_null.GetRowSchema())), &actual)
+	expected = map[string]any{
+		"1201": map[string]any{"id": 1201, "text": "  Buy Milk ", "same": nil},
+		"1202": map[string]any{"id": 1202, "text": "around the park", "same": "around the park"},
+	}
+	std_message, err = compare.Compare(expected, actual, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+	//CONCAT leaves a NULL out
+	concat_null := Query_to_observer(`SELECT id, CONCAT(title, NULL, "!") AS shout FROM todo WHERE person_id == 1200 `)
+	actual = map[string]any{}
+	json.Unmarshal([]byte(pubsub.ObserverToJson(concat_null, concat_null.GetRowSchema())), &actual)
+	std_message, err = compare.Compare(map[string]any{
+		"1201": map[string]any{"id": 1201, "shout": "  Buy Milk !"},
+		"1202": map[string]any{"id": 1202, "shout": "Walk the dog!"},
+	}, actual, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+	for _, src := range []string{`SELECT COALESCE(NULL, NULL) AS nothing FROM todo `, `SELECT LOWER(NULL) AS lower FROM todo `} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s should not compile", src)
+				}
+			}()
+			Query_to_observer(src)
+		}()
+	}
+}
+
+func TestRegisteredFunctions(t *testing.T) {
+	Register_function("initials", func(title string) string {
+		initials := ""
+		for _, word := range strings.Fields(title) {
+			initials += strings.ToUpper(word[:1])
+		}
+		return initials
+	})
+	Register_function("describe", func(description *string) string {
+		if description == nil {
+			return "none"
+		}
+		return "some"
+	})
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"write the report", nil, false, 1300, false, 1301})
+	todos.Insert(rowType.RowType{"x", "short", false, 1300, false, 1302})
+
+	obs := Query_to_observer(`SELECT id, initials(title) AS initials, describe(description) AS described FROM todo WHERE person_id == 1300 AND LENGTH(initials(title)) > 1 `)
+	var actual map[string]any
+	json.Unmarshal([]byte(pubsub.ObserverToJson(obs, obs.GetRowSchema())), &actual)
+	expected := map[string]any{
+		"1301": map[string]any{"id": 1301, "initials": "WTR", "described": "none"},
+	}
+	std_message, err := compare.Compare(expected, actual, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("initials of an int should not compile")
+		}
+	}()
+	Query_to_observer(`SELECT initials(id) AS initials FROM todo`)
+}
+
+// keeps every distinct value it was given with how many times, and results in them sorted as a json array
+type distinct_set_aggregator struct {
+	counts map[string]int
+}
+
+func (this *distinct_set_aggregator) Add(value any) {
+	this.counts[value.(string)]++
+}
+func (this *distinct_set_aggregator) Retract(value any) {
+	this.counts[value.(string)]--
+	if this.counts[value.(string)] == 0 {
+		delete(this.counts, value.(string))
+	}
+}
+func (this *distinct_set_aggregator) Result() any {
+	values := slices.Sorted(maps.Keys(this.counts))
+	result, _ := json.Marshal(values)
+	return json.RawMessage(result)
+}
+
+func TestRegisteredAggregates(t *testing.T) {
+	Register_aggregate("distinct_set", func(arg_type rowType.DataType) rowType.DataType {
+		if arg_type != rowType.String {
+			panic("distinct_set needs a string")
+		}
+		return rowType.Json
+	}, func() pubsub.Aggregator { return &distinct_set_aggregator{counts: map[string]int{}} })
+
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"set-b", "", 20, "set-state", 1400, ""})
+	people.Insert(rowType.RowType{"set-a", "", 20, "set-state", 1401, ""})
+	people.Insert(rowType.RowType{"set-c", "", 30, "set-state", 1402, ""})
+
+	obs := Query_to_observer(`SELECT age, distinct_set(name) AS names FROM person WHERE state == "set-state" GROUP BY age `)
+	db := live_sync(t, obs)
+
+	expected := map[string]any{
+		"20": map[string]any{"age": 20, "names": []any{"set-a", "set-b"}},
+		"30": map[string]any{"age": 30, "names": []any{"set-c"}},
+	}
+	check_data(t, expected, db.Data)
+
+	people.Insert(rowType.RowType{"set-a", "", 30, "set-state", 1403, ""})
+	people.R_Table.Remove_where_eq(people.Columns, "id", 1401)
+	expected = map[string]any{
+		"20": map[string]any{"age": 20, "names": []any{"set-b"}},
+		"30": map[string]any{"age": 30, "names": []any{"set-a", "set-c"}},
+	}
+	check_data(t, expected, db.Data)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("distinct_set of an int should not compile")
+		}
+	}()
+	Query_to_observer(`SELECT distinct_set(age) AS ages FROM person`)
+}
+
+func TestDistinct(t *testing.T) {
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"distinct-1", "", 20, "distinct-a", 1500, ""})
+	people.Insert(rowType.RowType{"distinct-2", "", 30, "distinct-a", 1501, ""})
+	people.Insert(rowType.RowType{"distinct-3", "", 20, "distinct-b", 1502, ""})
+
+	states := Query_to_observer(`SELECT DISTINCT state FROM person WHERE id >= 1500 AND id < 1600 `)
+	oldest := Query_to_observer(`SELECT DISTINCT ON (state) state, name FROM person WHERE id >= 1500 AND id < 1600 `)
+	pairs := Query_to_observer(`SELECT DISTINCT state, age FROM person WHERE id >= 1500 AND id < 1600 `)
+	states_db, oldest_db, pairs_db := live_sync(t, states), live_sync(t, oldest), live_sync(t, pairs)
+	check_data(t, map[string]any{
+		"distinct-a": map[string]any{"state": "distinct-a"},
+		"distinct-b": map[string]any{"state": "distinct-b"},
+	}, states_db.Data)
+	check_data(t, map[string]any{
+		"distinct-a": map[string]any{"state": "distinct-a", "name": "distinct-1"},
+		"distinct-b": map[string]any{"state": "distinct-b", "name": "distinct-3"},
+	}, oldest_db.Data)
+	//the values of several columns are one path segment
+	check_data(t, map[string]any{
+		"distinct-a,20": map[string]any{"state": "distinct-a", "age": 20},
+		"distinct-a,30": map[string]any{"state": "distinct-a", "age": 30},
+		"distinct-b,20": map[string]any{"state": "distinct-b", "age": 20},
+	}, pairs_db.Data)
+
+	//distinct-a is still there as long as one of its rows is, and distinct-b goes away with its only row
+	people.R_Table.Remove_where_eq(people.Columns, "id", 1500)
+	people.R_Table.Remove_where_eq(people.Columns, "id", 1502)
+	people.Insert(rowType.RowType{"distinct-4", "", 40, "distinct-c", 1503, ""})
+	check_data(t, map[string]any{
+		"distinct-a": map[string]any{"state": "distinct-a"},
+		"distinct-c": map[string]any{"state": "distinct-c"},
+	}, states_db.Data)
+	check_data(t, map[string]any{
+		"distinct-a": map[string]any{"state": "distinct-a", "name": "distinct-2"},
+		"distinct-c": map[string]any{"state": "distinct-c", "name": "distinct-4"},
+	}, oldest_db.Data)
+
+	people.R_Table.Update_field_where_eq(people.Columns, "id", 1501, 2, 35)
+	expected_pairs := map[string]any{
+		"distinct-a,35": map[string]any{"state": "distinct-a", "age": 35},
+		"distinct-c,40": map[string]any{"state": "distinct-c", "age": 40},
+	}
+	check_data(t, expected_pairs, pairs_db.Data)
+	check_data(t, pairs_db.Data, expected_pairs) //no stale keys are left behind
+}
+
+func TestSetOperations(t *testing.T) {
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"set-op-1", "", 20, "set-op-a", 1600, ""})
+	people.Insert(rowType.RowType{"set-op-2", "", 95, "set-op-a", 1601, ""})
+	people.Insert(rowType.RowType{"set-op-3", "", 96, "set-op-b", 1602, ""})
+
+	in_range := `id >= 1600 AND id < 1700`
+	union := Query_to_observer(`SELECT id, name FROM person WHERE state == "set-op-a" AND ` + in_range + ` UNION SELECT id, name FROM person WHERE age >= 90 AND ` + in_range + ` ORDER BY id `)
+	except := Query_to_observer(`SELECT id, name FROM person WHERE state == "set-op-a" AND ` + in_range + ` EXCEPT SELECT id, name FROM person WHERE age >= 90 AND ` + in_range + ` `)
+	union_db, except_db := live_sync(t, union), live_sync(t, except)
+	check_data(t, map[string]any{
+		"1600": map[string]any{"id": 1600, "name": "set-op-1"},
+		"1601": map[string]any{"id": 1601, "name": "set-op-2"},
+		"1602": map[string]any{"id": 1602, "name": "set-op-3"},
+	}, union_db.Data)
+	check_data(t, map[string]any{
+		"1600": map[string]any{"id": 1600, "name": "set-op-1"},
+	}, except_db.Data)
+
+	//1601 is in both sides of the union, so it only goes away once its in neither
+	people.R_Table.Update_where_eq(people.Columns, "id", 1601, rowType.RowType{"set-op-2", "", 50, "set-op-a", 1601, ""})
+	people.R_Table.Remove_where_eq(people.Columns, "id", 1602)
+	check_data(t, map[string]any{
+		"1600": map[string]any{"id": 1600, "name": "set-op-1"},
+		"1601": map[string]any{"id": 1601, "name": "set-op-2"},
+	}, union_db.Data)
+	check_data(t, map[string]any{
+		"1600": map[string]any{"id": 1600, "name": "set-op-1"},
+		"1601": map[string]any{"id": 1601, "name": "set-op-2"},
+	}, except_db.Data)
+
+	people.R_Table.Update_where_eq(people.Columns, "id", 1600, rowType.RowType{"set-op-1", "", 91, "set-op-c", 1600, ""})
+	people.R_Table.Update_where_eq(people.Columns, "id", 1601, rowType.RowType{"set-op-2", "", 50, "set-op-c", 1601, ""})
+	check_data(t, map[string]any{
+		"1600": map[string]any{"id": 1600, "name": "set-op-1"},
+	}, union_db.Data)
+	check_data(t, map[string]any{}, except_db.Data)
+}
+
+func TestInAndExists(t *testing.T) {
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"in-1", "", 30, "in-a", 1700, ""})
+	people.Insert(rowType.RowType{"in-2", "", 40, "in-a", 1701, ""})
+	people.Insert(rowType.RowType{"in-3", "", 50, "in-b", 1702, ""})
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"in-open", "", false, 1700, false, 1701})
+	todos.Insert(rowType.RowType{"in-done", "", true, 1701, false, 1702})
+
+	in_range := `id >= 1700 AND id < 1800`
+	in_list := Query_to_observer(`SELECT id FROM person WHERE id IN (1700, 1702, 5) `)
+	exists := Query_to_observer(`SELECT id FROM person WHERE ` + in_range + ` AND EXISTS (SELECT title FROM todo WHERE todo.person_id == person.id AND done == false) `)
+	not_in := Query_to_observer(`SELECT id FROM person WHERE ` + in_range + ` AND age NOT IN (SELECT age FROM person WHERE state == "in-b") `)
+	in_list_db, exists_db, not_in_db := live_sync(t, in_list), live_sync(t, exists), live_sync(t, not_in)
+	check_data(t, map[string]any{
+		"1700": map[string]any{"id": 1700},
+		"1702": map[string]any{"id": 1702},
+	}, in_list_db.Data)
+	check_data(t, map[string]any{
+		"1700": map[string]any{"id": 1700},
+	}, exists_db.Data)
+	check_data(t, map[string]any{
+		"1700": map[string]any{"id": 1700},
+		"1701": map[string]any{"id": 1701},
+	}, not_in_db.Data)
+
+	//the subqueries' rows changing changes which rows pass
+	todos.R_Table.Update_where_eq(todos.Columns, "id", 1702, rowType.RowType{"in-done", "", false, 1701, false, 1702})
+	todos.R_Table.Remove_where_eq(todos.Columns, "id", 1701)
+	people.R_Table.Update_where_eq(people.Columns, "id", 1701, rowType.RowType{"in-2", "", 40, "in-b", 1701, ""})
+	check_data(t, map[string]any{
+		"1701": map[string]any{"id": 1701},
+	}, exists_db.Data)
+	check_data(t, map[string]any{
+		"1700": map[string]any{"id": 1700},
+	}, not_in_db.Data)
+}
+
+func TestPatternMatching(t *testing.T) {
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"Buy milk", nil, false, 1800, false, 1801})
+	todos.Insert(rowType.RowType{"buy 100% juice", nil, false, 1800, false, 1802})
+	todos.Insert(rowType.RowType{"Walk the dog", nil, false, 1800, false, 1803})
+
+	matching := func(condition string) map[string]any {
+		obs := Query_to_observer(`SELECT id FROM todo WHERE person_id == 1800 AND ` + condition + ` `)
+		var actual map[string]any
+		json.Unmarshal([]byte(pubsub.ObserverToJson(obs, obs.GetRowSchema())), &actual)
+		return actual
+	}
+	check := func(expected []int, actual map[string]any) {
+		t.Helper()
+		expected_rows := map[string]any{}
+		for _, id := range expected {
+			expected_rows[strconv.Itoa(id)] = map[string]any{"id": id}
+		}
+		std_message, err := compare.Compare(expected_rows, actual, "")
+		if err != nil {
+			t.Log(std_message)
+			t.Fatal(err)
+		}
+	}
+	check([]int{1802}, matching(`title LIKE "buy%"`))
+	check([]int{1801, 1802}, matching(`title ILIKE "BUY%"`))
+	check([]int{1803}, matching(`title NOT ILIKE "buy%"`))
+	check([]int{1801}, matching(`title LIKE "_uy m_lk"`))
+	check([]int{1802}, matching(`title LIKE "%\\%%"`))
+	check([]int{1801, 1803}, matching(`title ~ "^[A-Z]"`))
+	//a null is neither like nor not like anything
+	check([]int{}, matching(`description LIKE "%" OR description NOT LIKE "%"`))
+}
+
+func TestNotEqualAndBetween(t *testing.T) {
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"first", nil, false, 1900, false, 1901})
+	todos.Insert(rowType.RowType{"second", nil, true, 1900, false, 1902})
+	todos.Insert(rowType.RowType{"third", nil, false, 1900, false, 1903})
+
+	matching := func(condition string) map[string]any {
+		obs := Query_to_observer(`SELECT id FROM todo WHERE person_id = 1900 AND ` + condition + ` `)
+		var actual map[string]any
+		json.Unmarshal([]byte(pubsub.ObserverToJson(obs, obs.GetRowSchema())), &actual)
+		return actual
+	}
+	check := func(expected []int, actual map[string]any) {
+		t.Helper()
+		expected_rows := map[string]any{}
+		for _, id := range expected {
+			expected_rows[strconv.Itoa(id)] = map[string]any{"id": id}
+		}
+		std_message, err := compare.Compare(expected_rows, actual, "")
+		if err != nil {
+			t.Log(std_message)
+			t.Fatal(err)
+		}
+	}
+	check([]int{1901, 1903}, matching(`id != 1902`))
+	check([]int{1901, 1903}, matching(`id <> 1902`))
+	check([]int{1901, 1902}, matching(`id BETWEEN 1900 AND 1902`))
+	check([]int{1903}, matching(`id NOT BETWEEN 1900 AND 1902 AND id BETWEEN 1900.5 AND 2000`))
+	//false is ordered before true
+	check([]int{1902}, matching(`done > false`))
+	check([]int{1901, 1903}, matching(`done < true`))
+	check([]int{}, matching(`description != "anything"`))
+	check([]int{1901}, matching(`(id + 1) = 1902`))
+	check([]int{1901, 1903}, matching(`(id) != 1902`))
+	check([]int{1902, 1903}, matching(`(id) BETWEEN 1902 AND 1903`))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("comparing an int with a bool should not compile")
+		}
+	}()
+	Query_to_observer(`SELECT id FROM todo WHERE id > true `)
+}
+
+func TestWith(t *testing.T) {
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"with open", "", false, 2050, false, 2051})
+	todos.Insert(rowType.RowType{"with done", "", true, 2050, false, 2052})
+
+	obs := Query_to_observer(`WITH mine AS (SELECT id, title, done FROM todo WHERE person_id == 2050), open AS (SELECT id, title FROM mine WHERE done == false) SELECT id, title FROM open `)
+	var actual map[string]any
+	json.Unmarshal([]byte(pubsub.ObserverToJson(obs, obs.GetRowSchema())), &actual)
+	expected := map[string]any{
+		"2051": map[string]any{"id": 2051, "title": "with open"},
+	}
+	std_message, err := compare.Compare(expected, actual, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+}
+
+func TestWithRecursive(t *testing.T) {
+	comments := db_tables.Tables.Get("comment")
+	comments.Insert(rowType.RowType{"root", nil, 2000})
+	comments.Insert(rowType.RowType{"reply", 2000, 2001})
+	comments.Insert(rowType.RowType{"reply to reply", 2001, 2002})
+	comments.Insert(rowType.RowType{"other reply", 2000, 2003})
+	comments.Insert(rowType.RowType{"other root", nil, 2010})
+	comments.Insert(rowType.RowType{"cycle root", nil, 2100})
+	comments.Insert(rowType.RowType{"cycle a", 2100, 2101})
+	comments.Insert(rowType.RowType{"cycle b", 2101, 2102})
+
+	thread := Query_to_observer(`WITH RECURSIVE thread AS (
+		SELECT id, 0 AS depth FROM comment WHERE id == 2000
+		UNION ALL
+		SELECT comment.id, thread.depth + 1 AS depth FROM comment JOIN thread ON comment.parent_id == thread.id
+	) SELECT id, depth FROM thread `)
+	cycle := Query_to_observer(`WITH RECURSIVE reachable AS (
+		SELECT id FROM comment WHERE id == 2100
+		UNION
+		SELECT comment.id FROM reachable JOIN comment ON comment.parent_id == reachable.id
+	) SELECT id FROM reachable `)
+	thread_db, cycle_db := live_sync(t, thread), live_sync(t, cycle)
+	depths := func(id_depths ...int) map[string]any {
+		res := map[string]any{}
+		for i := 0; i < len(id_depths); i += 2 {
+			res[strconv.Itoa(id_depths[i])] = map[string]any{"id": id_depths[i], "depth": id_depths[i+1]}
+		}
+		return res
+	}
+	check_data(t, depths(2000, 0, 2001, 1, 2002, 2, 2003, 1), thread_db.Data)
+
+	comments.Insert(rowType.RowType{"deep reply", 2002, 2004})
+	check_data(t, depths(2000, 0, 2001, 1, 2002, 2, 2003, 1, 2004, 3), thread_db.Data)
+
+	//moving a reply to another thread takes its replies with it, and moving it back brings them back at their new depth
+	comments.R_Table.Update_where_eq(comments.Columns, "id", 2001, rowType.RowType{"reply", 2010, 2001})
+	check_data(t, depths(2000, 0, 2003, 1), thread_db.Data)
+	comments.R_Table.Update_where_eq(comments.Columns, "id", 2001, rowType.RowType{"reply", 2003, 2001})
+	check_data(t, depths(2000, 0, 2003, 1, 2001, 2, 2002, 3, 2004, 4), thread_db.Data)
+
+	comments.R_Table.Remove_where_eq(comments.Columns, "id", 2003)
+	check_data(t, depths(2000, 0), thread_db.Data)
+
+	check_data(t, map[string]any{
+		"2100": map[string]any{"id": 2100},
+		"2101": map[string]any{"id": 2101},
+		"2102": map[string]any{"id": 2102},
+	}, cycle_db.Data)
+	//rows that only lead to each other aren't reachable anymore
+	comments.R_Table.Update_where_eq(comments.Columns, "id", 2101, rowType.RowType{"cycle a", 2102, 2101})
+	check_data(t, map[string]any{
+		"2100": map[string]any{"id": 2100},
+	}, cycle_db.Data)
+	comments.R_Table.Update_where_eq(comments.Columns, "id", 2102, rowType.RowType{"cycle b", 2100, 2102})
+	check_data(t, map[string]any{
+		"2100": map[string]any{"id": 2100},
+		"2101": map[string]any{"id": 2101},
+		"2102": map[string]any{"id": 2102},
+	}, cycle_db.Data)
+
+	//a reply that becomes a root is both a new anchor row and a row that isn't reached anymore, which is one update of each row it leads to
+	comments.Insert(rowType.RowType{"forest root", nil, 2020})
+	comments.Insert(rowType.RowType{"forest reply", 2020, 2021})
+	comments.Insert(rowType.RowType{"forest reply to reply", 2021, 2022})
+	forest_db := live_sync(t, Query_to_observer(`WITH RECURSIVE forest AS (
+		SELECT id, 0 AS depth FROM comment WHERE parent_id IS NULL AND id >= 2020 AND id < 2030
+		UNION
+		SELECT comment.id, forest.depth + 1 AS depth FROM comment JOIN forest ON comment.parent_id == forest.id
+	) SELECT id, depth FROM forest `))
+	check_data(t, depths(2020, 0, 2021, 1, 2022, 2), forest_db.Data)
+	comments.R_Table.Update_where_eq(comments.Columns, "id", 2021, rowType.RowType{"forest reply", nil, 2021})
+	check_data(t, depths(2020, 0, 2021, 0, 2022, 1), forest_db.Data)
+	comments.R_Table.Update_where_eq(comments.Columns, "id", 2021, rowType.RowType{"forest reply", 2020, 2021})
+	check_data(t, depths(2020, 0, 2021, 1, 2022, 2), forest_db.Data)
+}
+
+func TestSelectStar(t *testing.T) {
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"star-tester", "star-tester-email", 30, "state", 2200, "profile-picture"})
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"star open", "", false, 2200, false, 2201})
+	todos.Insert(rowType.RowType{"star done", "", true, 2200, true, 2202})
+
+	obs := Query_to_observer(`SELECT person.*, (SELECT * FROM todo WHERE todo.person_id == person.id) AS todos FROM person WHERE person.name == "star-tester" `)
+	names := []string{}
+	for _, col := range obs.GetRowSchema() {
+		names = append(names, col.Name)
+	}
+	if !slices.Equal(names, []string{"name", "email", "age", "state", "id", "profile_picture", "todos"}) {
+		t.Fatalf("expected the columns of person followed by todos but got %v", names)
+	}
+
+	var actual map[string]any
+	json.Unmarshal([]byte(pubsub.ObserverToJson(obs, obs.GetRowSchema())), &actual)
+	todo := func(title string, done bool, is_public bool, id int) map[string]any {
+		return map[string]any{"title": title, "description": "", "done": done, "person_id": 2200, "is_public": is_public, "id": id}
+	}
+	expected := map[string]any{
+		"star-tester": map[string]any{
+			"name": "star-tester", "email": "star-tester-email", "age": 30, "state": "state", "id": 2200, "profile_picture": "profile-picture",
+			"todos": map[string]any{
+				"star open": todo("star open", false, false, 2201),
+				"star done": todo("star done", true, true, 2202),
+			},
+		},
+	}
+	std_message, err := compare.Compare(expected, actual, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+
+	//a query keeps the columns its * was expanded to when it was compiled, a column the table gets after that is only selected by the queries compiled after it
+	db_tables.Tables.Add("star_schema", db_tables.NewTable("star_schema", rowType.RowSchema{{Name: "id", Type: rowType.Int}}))
+	star_schema := db_tables.Tables.Get("star_schema")
+	compiled_before := Query_to_observer(`SELECT * FROM star_schema `)
+	before_db := live_sync(t, compiled_before)
+	star_schema.Columns = append(star_schema.Columns, rowType.ColInfo{Name: "label", Type: rowType.String})
+	star_schema.Insert(rowType.RowType{1, "one"})
+	compiled_after := Query_to_observer(`SELECT * FROM star_schema `)
+	if len(compiled_before.GetRowSchema()) != 1 || len(compiled_after.GetRowSchema()) != 2 {
+		t.Fatalf("expected only the query compiled after the new column to select it but got %v and %v", compiled_before.GetRowSchema(), compiled_after.GetRowSchema())
+	}
+	check_data(t, map[string]any{"1": map[string]any{"id": 1}}, before_db.Data)
+	actual = nil
+	json.Unmarshal([]byte(pubsub.ObserverToJson(compiled_after, compiled_after.GetRowSchema())), &actual)
+	check_data(t, map[string]any{"1": map[string]any{"id": 1, "label": "one"}}, actual)
+}
+
+func TestOrderedIndexRanges(t *testing.T) {
+	events := db_tables.Tables.Get("event")
+	march := func(day int) time.Time { return time.Date(2025, 3, day, 12, 0, 0, 0, time.UTC) }
+	events.Insert(rowType.RowType{"before", time.Date(2025, 2, 20, 0, 0, 0, 0, time.UTC), 1.0, nil, 2301})
+	events.Insert(rowType.RowType{"early march", march(2), 1.0, nil, 2302})
+	events.Insert(rowType.RowType{"late march", march(30), 1.0, nil, 2303})
+	events.Insert(rowType.RowType{"after", time.Date(2025, 4, 2, 0, 0, 0, 0, time.UTC), 1.0, nil, 2304})
+
+	comments := db_tables.Tables.Get("comment")
+	comments.Index_on("text", pubsub.Ordered)
+	comments.Insert(rowType.RowType{"range a", nil, 2401})
+	comments.Insert(rowType.RowType{"ranged", nil, 2402})
+	comments.Insert(rowType.RowType{"range b", nil, 2403})
+
+	in_march := live_sync(t, Query_to_observer(`SELECT id, name FROM event WHERE at BETWEEN "2025-03-01" AND "2025-03-31" `))
+	starting_with_range := live_sync(t, Query_to_observer(`SELECT id FROM comment WHERE text LIKE "range %" `))
+
+	check_data(t, map[string]any{
+		"2302": map[string]any{"id": 2302, "name": "early march"},
+		"2303": map[string]any{"id": 2303, "name": "late march"},
+	}, in_march.Data)
+	check_data(t, map[string]any{"2401": map[string]any{"id": 2401}, "2403": map[string]any{"id": 2403}}, starting_with_range.Data)
+
+	//rows move in and out of the range as their value changes
+	events.Insert(rowType.RowType{"mid march", march(15), 1.0, nil, 2305})
+	events.R_Table.Update_field_where_eq(events.Columns, "id", 2301, 1, march(1))
+	events.R_Table.Update_field_where_eq(events.Columns, "id", 2303, 1, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC))
+	events.R_Table.Update_field_where_eq(events.Columns, "id", 2302, 0, "still early march")
+	events.R_Table.Remove_where_eq(events.Columns, "id", 2305)
+	check_data(t, map[string]any{
+		"2301": map[string]any{"id": 2301, "name": "before"},
+		"2302": map[string]any{"id": 2302, "name": "still early march"},
+	}, in_march.Data)
+
+	comments.R_Table.Update_field_where_eq(comments.Columns, "id", 2402, 0, "range d")
+	comments.R_Table.Update_field_where_eq(comments.Columns, "id", 2401, 0, "rang")
+	check_data(t, map[string]any{"2402": map[string]any{"id": 2402}, "2403": map[string]any{"id": 2403}}, starting_with_range.Data)
+}
+
+func TestCompositeIndex(t *testing.T) {
+	people := db_tables.Tables.Get("person")
+	people.Insert(rowType.RowType{"composite-tester", "composite-tester-email", 30, "state", 2500, "profile-picture"})
+	todos := db_tables.Tables.Get("todo")
+	todos.Insert(rowType.RowType{"composite open", "", false, 2500, false, 2501})
+	todos.Insert(rowType.RowType{"composite done", "", true, 2500, false, 2502})
+
+	obs := Query_to_observer(`SELECT person.name, (
+		SELECT todo.id FROM todo WHERE todo.person_id == person.id AND todo.done == false
+	) AS open FROM person WHERE person.name == "composite-tester" `)
+	db := live_sync(t, obs)
+	check := func(open_ids ...int) {
+		t.Helper()
+		open := map[string]any{}
+		for _, id := range open_ids {
+			open[strconv.Itoa(id)] = map[string]any{"id": id}
+		}
+		check_data(t, map[string]any{"composite-tester": map[string]any{"name": "composite-tester", "open": open}}, db.Data)
+	}
+	check(2501)
+
+	todos.R_Table.Update_field_where_eq(todos.Columns, "id", 2501, 2, true)
+	todos.R_Table.Update_field_where_eq(todos.Columns, "id", 2502, 2, false)
+	todos.Insert(rowType.RowType{"composite new", "", false, 2500, false, 2503})
+	check(2502, 2503)
+}
+
+func TestPrimaryKeyAndUnique(t *testing.T) {
+	db_tables.Tables.Add("account", db_tables.NewTable("account", rowType.RowSchema{{Name: "email", Type: rowType.String}, {Name: "name", Type: rowType.String, Nullable: true}, {Name: "id", Type: rowType.Int}},
+		db_tables.Primary_key("id"), db_tables.Auto_increment("id"), db_tables.Unique("email")))
+	accounts := db_tables.Tables.Get("account")
+	accounts.Insert(rowType.RowType{"a@mail.com", "a", nil})
+	accounts.Insert(rowType.RowType{"b@mail.com", nil, 10})
+	accounts.Insert(rowType.RowType{"c@mail.com", "c", nil})
+
+	obs := Query_to_observer(`SELECT email, name, id FROM account `)
+	db := live_sync(t, obs)
+	expected := map[string]any{
+		"0":  map[string]any{"email": "a@mail.com", "name": "a", "id": 0},
+		"10": map[string]any{"email": "b@mail.com", "name": nil, "id": 10},
+		"11": map[string]any{"email": "c@mail.com", "name": "c", "id": 11},
+	}
+	check := func() {
+		t.Helper()
+		check_data(t, expected, db.Data)
+	}
+	check()
+
+	//the row stays under its id when the first column changes
+	accounts.R_Table.Update_field_where_eq(accounts.Columns, "id", 0, 0, "new-a@mail.com")
+	expected["0"] = map[string]any{"email": "new-a@mail.com", "name": "a", "id": 0}
+	check()
+
+	if err := accounts.Insert(rowType.RowType{"b@mail.com", "b again", nil}); err == nil || err.Error() != "duplicate key: (email)=(b@mail.com) already exists" {
+		t.Fatalf("expected a second b@mail.com to be rejected but got %v", err)
+	}
+	if err := accounts.Insert(rowType.RowType{"d@mail.com", "d", 10}); err == nil || err.Error() != "duplicate key: (id)=(10) already exists" {
+		t.Fatalf("expected a second id 10 to be rejected but got %v", err)
+	}
+	if _, ok := accounts.R_Table.Update_field_where_eq(accounts.Columns, "id", 11, 0, "b@mail.com").(*pubsub.Unique_violation); !ok {
+		t.Fatal("expected updating c@mail.com to b@mail.com to be rejected")
+	}
+	check()
+	//like a sql sequence, the id given to the rejected row isn't handed out again
+	if id := accounts.Next_row_id(); id != 13 {
+		t.Fatalf("expected the next id to be 13 but got %d", id)
+	}
+
+	//without the primary key the rows are keyed by the first column
+	by_email := map[string]any{}
+	json.Unmarshal([]byte(pubsub.ObserverToJson(Query_to_observer(`SELECT email FROM account `), rowType.RowSchema{{Name: "email", Type: rowType.String}})), &by_email)
+	if _, ok := by_email["b@mail.com"]; !ok {
+		t.Fatalf("expected the rows to be keyed by email but got %v", by_email)
+	}
+
+	//rows that are grouped without aggregates are also stored under their id
+	paths := []string{}
+	grouped_tree := event_emitter_tree.EventEmitterTree{On_message: func(message event_emitter_tree.SyncMessage) {
+		paths = append(paths, message.Path)
+	}}
+	grouped_tree.SyncFromObservable(Query_to_observer(`SELECT email, name, id FROM account GROUP BY name `), "")
+	accounts.R_Table.Update_field_where_eq(accounts.Columns, "id", 0, 0, "newer-a@mail.com")
+	if !slices.Equal(paths, []string{"/a/0"}) {
+		t.Fatalf("expected the update to be under the group and id of the row but got %v", paths)
+	}
+
+	//without an auto increment column or a primary key, the ids inserted into the id column are skipped too
+	db_tables.Tables.Add("numbered", db_tables.NewTable("numbered", rowType.RowSchema{{Name: "name", Type: rowType.String}, {Name: "id", Type: rowType.Int}}))
+	numbered := db_tables.Tables.Get("numbered")
+	if id := numbered.Next_row_id(); id != 0 {
+		t.Fatalf("expected the first id to be 0 but got %d", id)
+	}
+	numbered.Insert(rowType.RowType{"seven", 7})
+	if id := numbered.Next_row_id(); id != 8 {
+		t.Fatalf("expected the next id to skip the inserted 7 but got %d", id)
+	}
+}
@@ -0,0 +1,112 @@
+package compiler
+
+import (
+	"slices"
+	"sql-compiler/compiler/ast"
+	"sql-compiler/compiler/functions"
+	. "sql-compiler/compiler/parser/tokenizer"
+	. "sql-compiler/compiler/rowType"
+	"sql-compiler/compiler/state_full_byte_code/byte_code"
+	"sql-compiler/unwrap"
+)
+
+// the IN (SELECT ...) or EXISTS a WHERE conjunct is (possibly under a NOT), ok is false for any other conjunct
+func as_subquery_predicate(expr ast.Bool_expr) (subquery ast.Bool_expr, anti bool, ok bool) {
+	if not, is_not := expr.(ast.Not_expr); is_not {
+		subquery, anti, ok = as_subquery_predicate(not.Expr)
+		return subquery, !anti, ok
+	}
+	switch expr := expr.(type) {
+	case ast.In_select:
+		return expr, expr.Negated, true
+	case ast.Exists:
+		return expr, false, true
+	}
+	return nil, false, false
+}
+
+// whether the column is in one of the select's own tables (and not in one of its parents')
+func is_own_col(select_ *ast.Select, col ast.Col) bool {
+	var col_name string
+	switch col := col.(type) {
+	case ast.Plain_col_name:
+		col_name = string(col)
+	case ast.Table_access:
+		col_name = col.Col_name
+	default:
+		return false
+	}
+	return slices.ContainsFunc(select_sources(select_), func(source table_source) bool {
+		if table_access, ok := col.(ast.Table_access); ok && table_access.Table_name != source.name {
+			return false
+		}
+		return source.table.Get_col_index(col_name) != -1
+	})
+}
+
+// the subquery is compiled on its own (so its rows don't depend on the outer row), a "inner_col == outer_col" conjunct of its WHERE is taken out of it
+// and instead the inner column is added to what it selects and the outer column to the Left_key, so the rows are matched on them
+func make_semi_join_byte_code(select_ *ast.Select, subquery ast.Bool_expr, anti bool) byte_code.Semi_join {
+	res := byte_code.Semi_join{Anti: anti}
+	var inner ast.Select
+	switch subquery := subquery.(type) {
+	case ast.In_select:
+		inner = subquery.Select
+		res.Is_in = true
+		expand_stars(&inner)
+		if len(inner.Selected_values) != 1 {
+			panic("the select of IN has to select a single value")
+		}
+		res.Left_key = append(res.Left_key, get_Runtime_value_relative_location_if_Col(select_, subquery.Value))
+	case ast.Exists:
+		inner = subquery.Select
+	}
+	if has_nested_select(&inner) {
+		panic("the select of IN or EXISTS can not have nested selects")
+	}
+	inner.Parent_select = unwrap.None[*ast.Select]()
+
+	wheres := []ast.Bool_expr{}
+	correlated_cols := []ast.Selected_value{}
+	for _, where := range inner.Wheres {
+		for _, conjunct := range ast.Split_conjuncts(where) {
+			if _, _, is_subquery := as_subquery_predicate(conjunct); is_subquery {
+				panic("IN (SELECT ...) and EXISTS can not be nested in each other")
+			}
+			comparison, ok := conjunct.(ast.Where)
+			col1, ok1 := comparison.Value1.(ast.Col)
+			col2, ok2 := comparison.Value2.(ast.Col)
+			if !ok || comparison.Operator != EQ || !ok1 || !ok2 || is_own_col(&inner, col1) == is_own_col(&inner, col2) {
+				wheres = append(wheres, conjunct)
+				continue
+			}
+			if !is_own_col(&inner, col1) {
+				col1, col2 = col2, col1
+			}
+			correlated_cols = append(correlated_cols, ast.Selected_value{Value_to_select: col1})
+			res.Left_key = append(res.Left_key, get_Runtime_value_relative_location_if_Col(select_, col2))
+		}
+	}
+	inner.Wheres = wheres
+
+	if len(correlated_cols) > 0 && (Is_aggregated(&inner) || len(inner.Set_operations) > 0 || inner.Limit.IsSome() || inner.Offset.IsSome() || len(inner.Distinct_on) > 0) {
+		panic("a correlated IN (SELECT ...) or EXISTS can not have aggregates, set operations, DISTINCT ON, LIMIT or OFFSET")
+	}
+	if !res.Is_in {
+		if Is_aggregated(&inner) || len(inner.Set_operations) > 0 {
+			panic("the select of EXISTS can not have aggregates or set operations")
+		}
+		//only whether there are rows matters, so only the correlated columns are kept
+		inner.Selected_values = nil
+		inner.Order_by = nil
+	}
+	inner.Selected_values = append(inner.Selected_values, correlated_cols...)
+
+	inner.Recursively_link_children()
+	res.Row_schema = Recursively_set_selects_row_schema(&inner)
+	if res.Is_in {
+		functions.Common_type("IN", []ColInfo{value_col_info(select_, subquery.(ast.In_select).Value), res.Row_schema[0]})
+	}
+	res.Select = unkeyed(Make_select_byte_code(&inner))
+	return res
+}
@@ -0,0 +1,54 @@
+package state_full_byte_code
+
+import "fmt"
+
+// null when either side is null (or when dividing by zero), ints stay ints and anything with a float becomes a float
+func arithmetic(operator string, left any, right any) any {
+	if left == nil || right == nil {
+		return nil
+	}
+	left_int, left_is_int := left.(int)
+	right_int, right_is_int := right.(int)
+	if left_is_int && right_is_int {
+		switch operator {
+		case "+":
+			return left_int + right_int
+		case "-":
+			return left_int - right_int
+		case "*":
+			return left_int * right_int
+		case "/":
+			if right_int == 0 {
+				return nil
+			}
+			return left_int / right_int
+		}
+		panic("unknown arithmetic operator " + operator)
+	}
+	left_float, right_float := to_float(left), to_float(right)
+	switch operator {
+	case "+":
+		return left_float + right_float
+	case "-":
+		return left_float - right_float
+	case "*":
+		return left_float * right_float
+	case "/":
+		if right_float == 0 {
+			return nil
+		}
+		return left_float / right_float
+	}
+	panic("unknown arithmetic operator " + operator)
+}
+
+func to_float(value any) float64 {
+	switch value := value.(type) {
+	case int:
+		return float64(value)
+	case float64:
+		return value
+	default:
+		panic(fmt.Sprintf("can not do arithmetic on a %T", value))
+	}
+}
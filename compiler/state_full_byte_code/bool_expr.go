@@ -0,0 +1,188 @@
+package state_full_byte_code
+
+import (
+	"fmt"
+	"regexp"
+	"sql-compiler/compiler/state_full_byte_code/byte_code"
+	"sql-compiler/utils"
+	"time"
+)
+
+// sql's three valued logic, comparing with a null (nil) is neither true nor false but unknown
+type Truth int
+
+const (
+	False Truth = iota
+	Unknown
+	True
+)
+
+func truth_of(b bool) Truth {
+	if b {
+		return True
+	}
+	return False
+}
+
+// a comparison is unknown when either side is null, otherwise the comparison decides
+func three_valued(compare func(value1 any, value2 any) bool) func(value1 any, value2 any) Truth {
+	return func(value1 any, value2 any) Truth {
+		if value1 == nil || value2 == nil {
+			return Unknown
+		}
+		return truth_of(compare(comparable_values(value1, value2)))
+	}
+}
+
+// an int compared with a float is compared as a float, and a string compared with a timestamp is read as a timestamp
+func comparable_values(value1 any, value2 any) (any, any) {
+	switch value1 := value1.(type) {
+	case int:
+		if value2, is_float := value2.(float64); is_float {
+			return float64(value1), value2
+		}
+	case float64:
+		if value2, is_int := value2.(int); is_int {
+			return value1, float64(value2)
+		}
+	case time.Time:
+		if value2, is_string := value2.(string); is_string {
+			return value1, utils.Parse_timestamp(value2)
+		}
+	case string:
+		if value2, is_timestamp := value2.(time.Time); is_timestamp {
+			return utils.Parse_timestamp(value1), value2
+		}
+	}
+	return value1, value2
+}
+
+// the pattern of LIKE, ILIKE and ~ was compiled into a regular expression when the query was compiled
+var match_pattern = three_valued(func(value1 any, value2 any) bool {
+	value, is_string := value1.(string)
+	if !is_string {
+		panic(fmt.Sprintf("only strings can be matched against a pattern but got a %T", value1))
+	}
+	return value2.(*regexp.Regexp).MatchString(value)
+})
+
+func values_equal(value1 any, value2 any) bool {
+	switch value1 := value1.(type) {
+	case string:
+		return value1 == value2.(string)
+	case int:
+		return value1 == value2.(int)
+	case float64:
+		return value1 == value2.(float64)
+	case time.Time:
+		return value1.Equal(value2.(time.Time))
+	case bool:
+		return value1 == value2.(bool)
+	default:
+		panic(fmt.Sprintf("types %T and %T do not match", value1, value2))
+	}
+}
+
+// bools are ordered with false before true
+var compare_methods = map[string]func(value1 any, value2 any) Truth{
+	"==": three_valued(values_equal),
+	"!=": three_valued(func(value1 any, value2 any) bool {
+		return !values_equal(value1, value2)
+	}),
+	">": three_valued(func(value1 any, value2 any) bool {
+		switch value1 := value1.(type) {
+		case string:
+			return value1 > value2.(string)
+		case int:
+			return value1 > value2.(int)
+		case float64:
+			return value1 > value2.(float64)
+		case time.Time:
+			return value1.After(value2.(time.Time))
+		case bool:
+			return value1 && !value2.(bool)
+		default:
+			panic(fmt.Sprintf("types %T and %T do not match", value1, value2))
+		}
+	}),
+	"<": three_valued(func(value1 any, value2 any) bool {
+		switch value1 := value1.(type) {
+		case string:
+			return value1 < value2.(string)
+		case int:
+			return value1 < value2.(int)
+		case float64:
+			return value1 < value2.(float64)
+		case time.Time:
+			return value1.Before(value2.(time.Time))
+		case bool:
+			return !value1 && value2.(bool)
+		default:
+			panic(fmt.Sprintf("types %T and %T do not match", value1, value2))
+		}
+	}),
+	">=": three_valued(func(value1 any, value2 any) bool {
+		switch value1 := value1.(type) {
+		case string:
+			return value1 >= value2.(string)
+		case int:
+			return value1 >= value2.(int)
+		case float64:
+			return value1 >= value2.(float64)
+		case time.Time:
+			return !value1.Before(value2.(time.Time))
+		case bool:
+			return value1 || !value2.(bool)
+		default:
+			panic(fmt.Sprintf("types %T and %T do not match", value1, value2))
+		}
+	}),
+	"<=": three_valued(func(value1 any, value2 any) bool {
+		switch value1 := value1.(type) {
+		case string:
+			return value1 <= value2.(string)
+		case int:
+			return value1 <= value2.(int)
+		case float64:
+			return value1 <= value2.(float64)
+		case time.Time:
+			return !value1.After(value2.(time.Time))
+		case bool:
+			return !value1 || value2.(bool)
+		default:
+			panic(fmt.Sprintf("types %T and %T do not match", value1, value2))
+		}
+	}),
+	"LIKE":  match_pattern,
+	"ILIKE": match_pattern,
+	"~":     match_pattern,
+}
+
+// with false < unknown < true, and is the lesser of its two sides, or is the greater, and not swaps true and false (unknown stays unknown)
+func (this *Row_context) Eval_bool(expr byte_code.Bool_expr) Truth {
+	switch expr := expr.(type) {
+	case byte_code.Where:
+		return compare_methods[expr.Compare_type](this.Eval(expr.Value_1), this.Eval(expr.Value_2))
+	case byte_code.Is_null:
+		return truth_of((this.Eval(expr.Value) == nil) != expr.Negated)
+	case byte_code.In_list:
+		//the same as comparing with each of the values and ORing the results
+		value := this.Eval(expr.Value)
+		res := False
+		for _, item := range expr.List {
+			res = max(res, compare_methods["=="](value, this.Eval(item)))
+		}
+		if expr.Negated {
+			return True - res
+		}
+		return res
+	case byte_code.And:
+		return min(this.Eval_bool(expr.Left), this.Eval_bool(expr.Right))
+	case byte_code.Or:
+		return max(this.Eval_bool(expr.Left), this.Eval_bool(expr.Right))
+	case byte_code.Not:
+		return True - this.Eval_bool(expr.Expr)
+	default:
+		panic(fmt.Sprintf("unhandled bool expression %T", expr))
+	}
+}
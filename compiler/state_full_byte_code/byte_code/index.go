@@ -1,27 +1,190 @@
 package byte_code
 
-import "sql-compiler/unwrap"
+import (
+	"sql-compiler/compiler/rowType"
+	"sql-compiler/unwrap"
+)
 
 type Expression any
 type StringOrNumber any
 
+type Bool_expr interface {
+	__Bool_expr()
+}
+
+func (Where) __Bool_expr()   {}
+func (And) __Bool_expr()     {}
+func (Or) __Bool_expr()      {}
+func (Not) __Bool_expr()     {}
+func (Is_null) __Bool_expr() {}
+func (In_list) __Bool_expr() {}
+
 type Where struct {
 	Value_1      Expression
 	Compare_type string
 	Value_2      Expression
 }
 
+type Is_null struct {
+	Value   Expression
+	Negated bool
+}
+
+type In_list struct {
+	Value   Expression
+	List    []Expression
+	Negated bool
+}
+
+type And struct {
+	Left  Bool_expr
+	Right Bool_expr
+}
+
+type Or struct {
+	Left  Bool_expr
+	Right Bool_expr
+}
+
+type Not struct {
+	Expr Bool_expr
+}
+
+// Operator is one of + - * /
+type Arithmetic struct {
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+// a call of a scalar function, Call is what the function does with the values of the Args
+type Func_call struct {
+	Name string
+	Call func(args []any) any
+	Args []Expression
+}
+
+type Case struct {
+	Whens []Case_when
+	Else  Expression //nil when there is no ELSE
+}
+
+type Case_when struct {
+	Condition Bool_expr
+	Then      Expression
+}
+
 type ColValuePair struct {
 	Col   string
-	Value StringOrNumber
+	Value StringOrNumber //nil when the rows where Col is null are wanted
+}
+
+type Access_kind string
+
+const (
+	Full_table   Access_kind = "FULL TABLE"
+	Hash_channel Access_kind = "HASH CHANNEL"
+	Range_index  Access_kind = "RANGE INDEX"
+)
+
+// how a select gets the rows of its table, the planner picks the one that is estimated to give the fewest rows
+type Access_path struct {
+	Kind            Access_kind
+	Cols_and_values []ColValuePair             //of a Hash_channel, the first columns of the index and their values
+	Range           unwrap.Option[Index_range] //of a Range_index
+	Estimated_rows  float64
+}
+
+// the rows of an ordered index with a value between the bounds, a missing bound leaves that side of the range open
+type Index_range struct {
+	Col  string
+	Low  unwrap.Option[Index_bound]
+	High unwrap.Option[Index_bound]
+}
+
+type Index_bound struct {
+	Value     StringOrNumber //never nil, as comparing with null is never true
+	Inclusive bool
+}
+
+type Order_by_col struct {
+	Col_index int //index into the selects row schema (the row after it was mapped)
+	Desc      bool
+}
+
+type Aggregate_call struct {
+	Func string
+	Arg  Expression //nil for COUNT(*)
+}
+
+// when a select has aggregates its rows are folded into one group row per distinct GROUP BY value (or into a single group row when there is no GROUP BY),
+// a group row is the GROUP BY values followed by the results of Aggregates, and the selected values and HAVING are evaluated against it instead of the table's rows
+type Aggregation struct {
+	Group_by   []Expression //evaluated on the table's rows
+	Aggregates []Aggregate_call
+	Having     []Bool_expr //all have to pass for the group to be shown
+}
+
+// the joined table's rows are appended to the rows so far (the FROM table's rows followed by the rows of the previous joins) whose Left_key matches the joined row's Right_key
+type Join struct {
+	Kind       string //INNER, LEFT, RIGHT or FULL
+	Table_name string
+	Cte        unwrap.Option[*Cte] //joined instead of the table
+	Left_key   []Expression        //evaluated on the rows so far
+	Right_key  []int               //indexes into the joined table's rows
+}
+
+// an IN (SELECT ...) or EXISTS in WHERE, a row passes when Select (which doesn't depend on the row) has a row whose values match the row's Left_key (or when it has none, for an Anti join),
+// a correlated subquery is turned into this by moving its "inner_col == outer_col" conditions into the keys.
+// for IN the first value of the keys is the value being looked for, and the rest are the correlated columns
+type Semi_join struct {
+	Anti       bool
+	Is_in      bool
+	Left_key   []Expression //evaluated on the rows of the select (after the joins)
+	Select     Select       //its rows are the keys to match
+	Row_schema rowType.RowSchema
 }
 
 type Select struct {
 	Table_name                string
-	Col_and_value_to_index_by ColValuePair //could be empty, in which case we will take from the table directly
-	Wheres_byte_code          []Where
+	Cte                       unwrap.Option[*Cte] //read from instead of the table
+	Joins                     []Join
+	Semi_joins                []Semi_join //all have to pass, after Wheres_byte_code
+	Access_path               Access_path //how the rows of the table are gotten, not used when reading from a Cte
+	Wheres_byte_code          []Bool_expr //all have to pass
 	Selected_values_byte_code []Expression
-	Group_by_col_index        unwrap.Option[int] // -1 if not set, otherwise the column index to group by
+	Key_col_index             int             //the selected column the rows are keyed by (in json and the paths of the EventEmitterTree), the primary key of the table when its selected
+	Join_key_values           []Expression    //the key column of each table of a join, put after the selected values so the rows are keyed by all of them, empty without joins
+	Group_by_col_indexes      []int           //the selected columns the rows are grouped by (into extra path segments), only when not aggregated
+	Set_operations            []Set_operation //combined with the rows of this select (after its DISTINCT), before the ORDER BY
+	Distinct_col_indexes      []int           //the selected columns only one row is let through for each combination of values of (DISTINCT), empty when there is no DISTINCT
+	Order_by                  []Order_by_col  //empty when the rows can come in any order
+	Limit                     unwrap.Option[int]
+	Offset                    int
+	Aggregation               unwrap.Option[Aggregation]
+	Is_scalar                 bool //a nested select that has a single aggregate value, its column holds that value instead of a list of rows
+}
+
+// a WITH query, the selects that read from it get the rows of Select (which has no parent, so it doesn't depend on where its read from)
+type Cte struct {
+	Name       string
+	Select     Select //for a recursive one, only the rows it starts with
+	Row_schema rowType.RowSchema
+	Recursion  unwrap.Option[Recursion]
+}
+
+// the part of a WITH RECURSIVE that reads from itself, Step selects from a table joined with the WITH query (in either order),
+// each of the WITH query's rows leads to Step's selected values for each row of the table that it joins with (and passes Step's WHERE)
+type Recursion struct {
+	All       bool
+	Step      Select
+	Cte_first bool //whether the WITH query is Step's FROM and the table is joined to it, instead of the other way around
+}
+
+type Set_operation struct {
+	Kind   string //UNION, INTERSECT or EXCEPT
+	All    bool
+	Select Select
 }
 
 type Runtime_value_relative_location struct {
@@ -21,9 +21,27 @@ func (this *Row_context) Get_value(relative_location byte_code.Runtime_value_rel
 	return current.Row[relative_location.Col_index]
 }
 
-func (this *Row_context) Track_value_if_is_relative_location(value any) any {
-	if relative_location, ok := value.(byte_code.Runtime_value_relative_location); ok {
-		return this.Get_value(relative_location)
+// the value of an expression for this row, a literal is its own value
+func (this *Row_context) Eval(value byte_code.Expression) any {
+	switch value := value.(type) {
+	case byte_code.Runtime_value_relative_location:
+		return this.Get_value(value)
+	case byte_code.Arithmetic:
+		return arithmetic(value.Operator, this.Eval(value.Left), this.Eval(value.Right))
+	case byte_code.Func_call:
+		args := make([]any, len(value.Args))
+		for i := range value.Args {
+			args[i] = this.Eval(value.Args[i])
+		}
+		return value.Call(args)
+	case byte_code.Case:
+		for _, when := range value.Whens {
+			if this.Eval_bool(when.Condition) == True {
+				return this.Eval(when.Then)
+			}
+		}
+		return this.Eval(value.Else)
+	default:
+		return value
 	}
-	return value
 }
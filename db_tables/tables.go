@@ -1,30 +1,123 @@
 package db_tables
 
 import (
+	"encoding/json"
 	"fmt"
+	"slices"
 	"sql-compiler/assert"
 	"sql-compiler/compiler/rowType"
-	"sql-compiler/display"
 	pubsub "sql-compiler/pub_sub"
 	"sql-compiler/utils"
+	"sync"
+	"time"
 )
 
 type Table struct {
-	Name    string
-	Columns []rowType.ColInfo
-	R_Table pubsub.R_Table
+	Name           string
+	Columns        []rowType.ColInfo
+	R_Table        *pubsub.R_Table //a pointer so the indexes (which point into it) stay valid when the table is copied into Tables
+	Primary_key    string          //empty when the table has none
+	Unique         [][]string      //the sets of columns whose values can only be in one row, the primary key is one of them
+	Auto_increment string          //the column that gets the next id of the sequence when a row is inserted with null in it, empty when there is none
+	sequence       *Sequence       //a pointer so the copies of the table hand out the same ids
 }
 
-func NewTable(name string, columns []rowType.ColInfo) Table {
-	return Table{
-		Name:    name,
-		Columns: columns,
-		R_Table: pubsub.New_R_Table(columns),
+// declared when making a table, as in NewTable("account", columns, Primary_key("id"), Auto_increment("id"))
+type Table_option func(*Table)
+
+// the rows are identified and keyed (in json, in the paths of the EventEmitterTree) by the column, its values have to be unique and not null
+func Primary_key(col_name string) Table_option {
+	return func(table *Table) {
+		col := table.must_get_col(col_name)
+		if table.Columns[col].Nullable {
+			panic(fmt.Sprintf("the primary key %s of table %s can not be nullable", col_name, table.Name))
+		}
+		table.Primary_key = col_name
+		table.Unique = append(table.Unique, []string{col_name})
+		table.R_Table.Key_col = col
+	}
+}
+
+// no two rows can have the same values in all of the columns, a row with a null in one of them doesn't clash with any
+func Unique(col_names ...string) Table_option {
+	return func(table *Table) {
+		for _, col_name := range col_names {
+			table.must_get_col(col_name)
+		}
+		table.Unique = append(table.Unique, col_names)
+	}
+}
+
+// inserting a row with null in the int column gives it the next id of the table's sequence
+func Auto_increment(col_name string) Table_option {
+	return func(table *Table) {
+		if table.Columns[table.must_get_col(col_name)].Type != rowType.Int {
+			panic(fmt.Sprintf("the auto increment column %s of table %s must be an int", col_name, table.Name))
+		}
+		table.Auto_increment = col_name
+	}
+}
+
+func NewTable(name string, columns []rowType.ColInfo, options ...Table_option) Table {
+	r_table := pubsub.New_R_Table(columns)
+	table := Table{
+		Name:     name,
+		Columns:  columns,
+		R_Table:  &r_table,
+		Unique:   [][]string{},
+		sequence: &Sequence{},
+	}
+	for _, option := range options {
+		option(&table)
+	}
+	for _, col_names := range table.Unique {
+		table.Composite_index_on(col_names...).Unique = true
 	}
+	return table
+}
+
+// hands out increasing ids, it can be used from several goroutines at once
+type Sequence struct {
+	mutex sync.Mutex
+	next  int
+}
+
+func (this *Sequence) Next() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	id := this.next
+	this.next++
+	return id
+}
+
+// an id that was given explicitly is never handed out again
+func (this *Sequence) advance_past(id int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.next = max(this.next, id+1)
 }
 
+// the next id of the table's sequence, ids are never reused (even when their row is deleted)
 func (this *Table) Next_row_id() int {
-	return len(this.R_Table.Rows)
+	return this.sequence.Next()
+}
+
+// the int column whose explicitly inserted ids the sequence skips: the auto increment column, otherwise the primary key, otherwise the id column (-1 when there is none)
+func (this Table) sequence_col() int {
+	for _, col_name := range []string{this.Auto_increment, this.Primary_key, "id"} {
+		if col := this.Get_col_index(col_name); col != -1 && this.Columns[col].Type == rowType.Int {
+			return col
+		}
+	}
+	return -1
+}
+
+func (this Table) must_get_col(col_name string) int {
+	col := this.Get_col_index(col_name)
+	if col == -1 {
+		panic("col " + col_name + " not found in table " + this.Name)
+	}
+	return col
 }
 
 func (this *Table) HasCol(col_name string) bool {
@@ -36,35 +129,78 @@ func (this *Table) HasCol(col_name string) bool {
 	return false
 }
 
+// whether there is an index on only this column
 func (this *Table) HasIndex(col_name string) bool {
 	for i := range this.R_Table.Indexes {
-		if this.R_Table.Indexes[i].Col_indexing_on == this.Get_col_index(col_name) {
+		if slices.Equal(this.R_Table.Indexes[i].Cols_indexing_on, []int{this.Get_col_index(col_name)}) {
 			return true
 		}
 	}
 	return false
 }
 
-func (this *Table) Index_on(col_name string) *pubsub.Index {
+// the first index whose first columns are these columns (in this order), nil when there is none
+func (this *Table) Index_starting_with(col_names ...string) *pubsub.Index {
+	for i := range this.R_Table.Indexes {
+		index := &this.R_Table.Indexes[i]
+		if len(index.Cols_indexing_on) >= len(col_names) && slices.Equal(index.Cols_indexing_on[:len(col_names)], this.col_indexes(col_names)) {
+			return index
+		}
+	}
+	return nil
+}
+
+func (this *Table) col_indexes(col_names []string) []int {
+	res := []int{}
+	for _, col_name := range col_names {
+		res = append(res, this.Get_col_index(col_name))
+	}
+	return res
+}
+
+// the index is a Hash index unless pubsub.Ordered is passed, which also makes an existing Hash index on the column Ordered
+func (this *Table) Index_on(col_name string, kind ...pubsub.Index_kind) *pubsub.Index {
+	index := this.Composite_index_on(col_name)
+	if slices.Contains(kind, pubsub.Ordered) {
+		index.Make_ordered()
+	}
+	return index
+}
+
+// an index on several columns, which can also be read with only the values of its first columns
+func (this *Table) Composite_index_on(col_names ...string) *pubsub.Index {
 	for i := range this.R_Table.Indexes {
-		if this.R_Table.Indexes[i].Col_indexing_on == this.Get_col_index(col_name) {
+		if slices.Equal(this.R_Table.Indexes[i].Cols_indexing_on, this.col_indexes(col_names)) {
 			return &this.R_Table.Indexes[i]
 		}
 	}
-	display.DisplayStruct(this)
-	this.R_Table.Indexes = append(this.R_Table.Indexes, pubsub.NewIndex(this.Get_col_index(col_name), &this.R_Table))
-	display.DisplayStruct(this)
+	this.R_Table.Indexes = append(this.R_Table.Indexes, pubsub.NewIndex(this.col_indexes(col_names), this.R_Table))
 	return &this.R_Table.Indexes[len(this.R_Table.Indexes)-1]
 }
 
-func (this *Table) Insert(row rowType.RowType) {
+// returns a *pubsub.Unique_violation (and leaves the table as it was) when the row has the same values as another row in a unique set of columns
+func (this *Table) Insert(row rowType.RowType) error {
 	assert.AssertEq(len(row), len(this.Columns), fmt.Sprintf("rows in table %s must have %d columns and you passed a row that has %d columns", this.Name, len(this.Columns), len(row)))
+	if col := this.sequence_col(); col != -1 {
+		if row[col] == nil && this.Auto_increment != "" {
+			row = slices.Clone(row)
+			row[col] = this.sequence.Next()
+		} else if id, ok := row[col].(int); ok {
+			this.sequence.advance_past(id)
+		}
+	}
 	validate_col_types(this, &row)
-	this.R_Table.Add(row)
+	return this.R_Table.Add(row)
 }
 
 func validate_col_types(this *Table, row *rowType.RowType) {
 	for i, col := range this.Columns {
+		if (*row)[i] == nil {
+			if !col.Nullable {
+				panic(fmt.Sprintf("col %s of table %s is not nullable and you passed in null", col.Name, this.Name))
+			}
+			continue
+		}
 		switch col.Type {
 		case rowType.String:
 			if _, ok := (*row)[i].(string); !ok {
@@ -78,15 +214,32 @@ func validate_col_types(this *Table, row *rowType.RowType) {
 			if _, ok := (*row)[i].(bool); !ok {
 				panic(fmt.Sprintf("col %s of table %s's type is bool and you passed in a %T", col.Name, this.Name, (*row)[i]))
 			}
+		case rowType.Float:
+			if _, ok := (*row)[i].(float64); !ok {
+				panic(fmt.Sprintf("col %s of table %s's type is float and you passed in a %T", col.Name, this.Name, (*row)[i]))
+			}
+		case rowType.Timestamp:
+			if _, ok := (*row)[i].(time.Time); !ok {
+				panic(fmt.Sprintf("col %s of table %s's type is timestamp and you passed in a %T", col.Name, this.Name, (*row)[i]))
+			}
+		case rowType.Json:
+			document, ok := (*row)[i].(json.RawMessage)
+			if !ok {
+				panic(fmt.Sprintf("col %s of table %s's type is json and you passed in a %T", col.Name, this.Name, (*row)[i]))
+			}
+			if !json.Valid(document) {
+				panic(fmt.Sprintf("col %s of table %s's type is json and you passed in invalid json %s", col.Name, this.Name, document))
+			}
 		default:
 			panic("unhandled")
 		}
 	}
 }
 
+// the index on only this column
 func (this Table) Get_index(col_name string) *pubsub.Index {
 	for i := range this.R_Table.Indexes {
-		if this.R_Table.Indexes[i].Col_indexing_on == this.Get_col_index(col_name) {
+		if slices.Equal(this.R_Table.Indexes[i].Cols_indexing_on, []int{this.Get_col_index(col_name)}) {
 			return &this.R_Table.Indexes[i]
 		}
 	}
@@ -103,7 +256,7 @@ func (this Table) Get_col_index(col_name string) int {
 }
 
 var Tables = tablesNewKeyValueArrayWith(30, NewTable("person", rowType.RowSchema{{Name: "name", Type: rowType.String}, {Name: "email", Type: rowType.String}, {Name: "age", Type: rowType.Int}, {Name: "state", Type: rowType.String}, {Name: "id", Type: rowType.Int}, {Name: "profile_picture", Type: rowType.String}}),
-	NewTable("todo", []rowType.ColInfo{{Name: "title", Type: rowType.String}, {Name: "description", Type: rowType.String}, {Name: "done", Type: rowType.Bool}, {Name: "person_id", Type: rowType.Int}, {Name: "is_public", Type: rowType.Bool}, {Name: "id", Type: rowType.Int}}),
+	NewTable("todo", []rowType.ColInfo{{Name: "title", Type: rowType.String}, {Name: "description", Type: rowType.String, Nullable: true}, {Name: "done", Type: rowType.Bool}, {Name: "person_id", Type: rowType.Int}, {Name: "is_public", Type: rowType.Bool}, {Name: "id", Type: rowType.Int}}),
 	NewTable("tag", []rowType.ColInfo{{Name: "name", Type: rowType.String}, {Name: "id", Type: rowType.Int}}),
 	NewTable("todo_tag", []rowType.ColInfo{{Name: "todo_id", Type: rowType.Int}, {Name: "tag_id", Type: rowType.Int}}),
 )
@@ -111,6 +264,7 @@ var Tables = tablesNewKeyValueArrayWith(30, NewTable("person", rowType.RowSchema
 func init() {
 	Tables.Get("todo").Index_on("person_id")
 	Tables.Get("todo").Index_on("id")
+	Tables.Get("todo").Composite_index_on("person_id", "done")
 	Tables.Get("tag").Index_on("id")
 	Tables.Get("tag").Index_on("name")
 	Tables.Get("todo_tag").Index_on("todo_id")
@@ -12,9 +12,11 @@
 package event_emitter_tree
 
 import (
+	"encoding/json"
 	"sql-compiler/compiler/rowType"
 	pubsub "sql-compiler/pub_sub"
-	"sql-compiler/utils"
+	"strings"
+	"time"
 )
 
 const path_separator = "/"
@@ -33,10 +35,32 @@ type SyncMessage struct {
 	Data      string
 	Path      string
 	Timestamp int64
+	Position  *int //only set for rows of an ordered query (ORDER BY/LIMIT/OFFSET), its where the row now is in the list of its siblings
 }
 
 type EventEmitterTree struct {
-	On_message func(SyncMessage)
+	On_message    func(SyncMessage)
+	subscriptions map[string][]func() //by the path the observable is synced to, what unsubscribes from it
+}
+
+func (receiver *EventEmitterTree) subscribe(obs pubsub.ObservableI, path string, subscriber pubsub.Subscriber) {
+	if receiver.subscriptions == nil {
+		receiver.subscriptions = map[string][]func(){}
+	}
+	obs.Add_sub(subscriber)
+	receiver.subscriptions[path] = append(receiver.subscriptions[path], func() { obs.Remove_sub(subscriber) })
+}
+
+// the nested selects of a row that was removed (or replaced by an update, whose new row has nested selects of its own) can't change what's under its path anymore
+func (receiver *EventEmitterTree) unsubscribe_under(row_path string) {
+	for path, unsubscribes := range receiver.subscriptions {
+		if strings.HasPrefix(path, row_path+path_separator) {
+			for _, unsubscribe := range unsubscribes {
+				unsubscribe()
+			}
+			delete(receiver.subscriptions, path)
+		}
+	}
 }
 
 func (receiver *EventEmitterTree) SyncFromObservable(obs pubsub.ObservableI, path string) {
@@ -44,51 +68,77 @@ func (receiver *EventEmitterTree) SyncFromObservable(obs pubsub.ObservableI, pat
 		receiver.SyncFromGroupByWithPathing(gb, path)
 		return
 	}
-	obs.Add_sub(&pubsub.CustomSubscriber{
+	receiver.subscribe(obs, path, &pubsub.CustomSubscriber{
 		OnAddFunc: func(item rowType.RowType) {
-			primary_key := utils.String_or_num_to_string(item[0])
-			receiver.On_message(SyncMessage{Type: SyncTypeAdd, Data: pubsub.RowTypeToJson(&item, obs.GetRowSchema()), Path: path + path_separator + primary_key})
+			primary_key := pubsub.Row_key(obs, item)
+			receiver.On_message(SyncMessage{Type: SyncTypeAdd, Data: pubsub.RowTypeToJson(&item, obs.GetRowSchema()), Path: path + path_separator + primary_key, Position: position_of(obs, item)})
 			receiver.syncFromObservable_row(item, path+path_separator+primary_key, obs.GetRowSchema())
 		},
 		OnRemoveFunc: func(item rowType.RowType) {
-			primary_key := utils.String_or_num_to_string(item[0])
+			primary_key := pubsub.Row_key(obs, item)
+			receiver.unsubscribe_under(path + path_separator + primary_key)
 			receiver.On_message(SyncMessage{Type: SyncTypeRemove, Data: pubsub.RowTypeToJson(&item, obs.GetRowSchema()), Path: path + path_separator + primary_key})
 		},
 		OnUpdateFunc: func(oldItem, newItem rowType.RowType) {
-			primary_key := oldItem[0].(string)
-			receiver.On_message(SyncMessage{Type: SyncTypeUpdate, Data: pubsub.RowTypeToJson(&newItem, obs.GetRowSchema()), Path: path + path_separator + primary_key})
+			old_key, new_key := pubsub.Row_key(obs, oldItem), pubsub.Row_key(obs, newItem)
+			receiver.unsubscribe_under(path + path_separator + old_key)
+			if old_key != new_key {
+				//the row is stored under another key now (like a row of a LEFT JOIN that found a match for its padded side)
+				receiver.On_message(SyncMessage{Type: SyncTypeRemove, Data: pubsub.RowTypeToJson(&oldItem, obs.GetRowSchema()), Path: path + path_separator + old_key})
+				receiver.On_message(SyncMessage{Type: SyncTypeAdd, Data: pubsub.RowTypeToJson(&newItem, obs.GetRowSchema()), Path: path + path_separator + new_key, Position: position_of(obs, newItem)})
+			} else {
+				receiver.On_message(SyncMessage{Type: SyncTypeUpdate, Data: pubsub.RowTypeToJson(&newItem, obs.GetRowSchema()), Path: path + path_separator + new_key, Position: position_of(obs, newItem)})
+			}
+			receiver.syncFromObservable_row(newItem, path+path_separator+new_key, obs.GetRowSchema())
 		},
 	})
 	for row := range obs.Pull {
-		receiver.syncFromObservable_row(row, path, obs.GetRowSchema())
+		receiver.syncFromObservable_row(row, path+path_separator+pubsub.Row_key(obs, row), obs.GetRowSchema())
 	}
 
 }
 
+// rows of an ordered observable come with where they belong in the list, so whoever is receiving the messages doesn't have to sort them again
+func position_of(obs pubsub.ObservableI, row rowType.RowType) *int {
+	ordered, ok := obs.(*pubsub.OrderBy)
+	if !ok {
+		return nil
+	}
+	position := ordered.Position_of(row)
+	return &position
+}
+
 // this is more advanced, first start with @syncFromObservable_row and understand that, once you do and understand the idea of what were doing with the GroupBy class and what were trying to do to make a using a group by way more efficient then just doing subqueries then proceed to read this method
 func (receiver *EventEmitterTree) SyncFromGroupByWithPathing(obs *pubsub.GroupBy, path string) {
-	obs.Add_sub(&pubsub.CustomSubscriber{
+	row_path := func(item rowType.RowType) string {
+		return path + path_separator + obs.Get_rows_group_path(&item) + path_separator + pubsub.Row_key(obs, item)
+	}
+	receiver.subscribe(obs, path, &pubsub.CustomSubscriber{
 		OnAddFunc: func(item rowType.RowType) {
-			primary_key := utils.String_or_num_to_string(item[0])
-			item_path := path + path_separator + obs.Get_rows_group_value(&item) + path_separator + primary_key
+			item_path := row_path(item)
 			receiver.On_message(SyncMessage{Type: SyncTypeAdd, Data: pubsub.RowTypeToJson(&item, obs.GetRowSchema()), Path: item_path})
 			receiver.syncFromObservable_row(item, item_path, obs.GetRowSchema())
 		},
 		OnRemoveFunc: func(item rowType.RowType) {
-			primary_key := utils.String_or_num_to_string(item[0])
-			item_path := path + path_separator + obs.Get_rows_group_value(&item) + path_separator + primary_key
-			receiver.On_message(SyncMessage{Type: SyncTypeRemove, Data: pubsub.RowTypeToJson(&item, obs.GetRowSchema()), Path: item_path})
+			receiver.unsubscribe_under(row_path(item))
+			receiver.On_message(SyncMessage{Type: SyncTypeRemove, Data: pubsub.RowTypeToJson(&item, obs.GetRowSchema()), Path: row_path(item)})
 		},
 		OnUpdateFunc: func(oldItem, newItem rowType.RowType) {
-			panic("todo: still working on this method")
-			primary_key := utils.String_or_num_to_string(oldItem[0])
-			item_path := path + path_separator + obs.Get_rows_group_value(&oldItem) + path_separator + primary_key
-			receiver.On_message(SyncMessage{Type: SyncTypeUpdate, Data: pubsub.RowTypeToJson(&newItem, obs.GetRowSchema()), Path: item_path})
+			old_path, new_path := row_path(oldItem), row_path(newItem)
+			receiver.unsubscribe_under(old_path)
+			if old_path == new_path {
+				receiver.On_message(SyncMessage{Type: SyncTypeUpdate, Data: pubsub.RowTypeToJson(&newItem, obs.GetRowSchema()), Path: new_path})
+				receiver.syncFromObservable_row(newItem, new_path, obs.GetRowSchema())
+				return
+			}
+			//the row moved to another group
+			receiver.On_message(SyncMessage{Type: SyncTypeRemove, Data: pubsub.RowTypeToJson(&oldItem, obs.GetRowSchema()), Path: old_path})
+			receiver.On_message(SyncMessage{Type: SyncTypeAdd, Data: pubsub.RowTypeToJson(&newItem, obs.GetRowSchema()), Path: new_path})
+			receiver.syncFromObservable_row(newItem, new_path, obs.GetRowSchema())
 		},
 	})
 	for row := range obs.Pull {
-
-		receiver.syncFromObservable_row(row, path+path_separator+obs.Get_rows_group_value(&row), obs.GetRowSchema())
+		receiver.syncFromObservable_row(row, row_path(row), obs.GetRowSchema())
 	}
 
 }
@@ -96,10 +146,16 @@ func (receiver *EventEmitterTree) SyncFromGroupByWithPathing(obs *pubsub.GroupBy
 func (receiver *EventEmitterTree) syncFromObservable_row(row rowType.RowType, path string, row_schema rowType.RowSchema) {
 	for i, col := range row {
 		switch col := col.(type) {
-		case string, int, bool:
+		case nil, string, int, float64, bool, time.Time, json.RawMessage:
 		case pubsub.ObservableI:
 			switch col := col.(type) {
-			case *pubsub.Mapper:
+			case *pubsub.Aggregate:
+				if !row_schema[i].Type.Is_nested_select() {
+					receiver.syncScalar(col, path+path_separator+row_schema[i].Name, row_schema[i].Type)
+					continue
+				}
+				receiver.SyncFromObservable(col, path+path_separator+row_schema[i].Name)
+			case *pubsub.Mapper, *pubsub.OrderBy:
 				receiver.SyncFromObservable(col, path+path_separator+row_schema[i].Name)
 			case *pubsub.GroupBy:
 				receiver.SyncFromGroupByWithPathing(col, path+path_separator+row_schema[i].Name)
@@ -111,3 +167,19 @@ func (receiver *EventEmitterTree) syncFromObservable_row(row rowType.RowType, pa
 		}
 	}
 }
+
+// a scalar subquery (like a correlated COUNT(*)) is a plain value of its parent row, so when it changes the value at its path is updated instead of a row under it
+func (receiver *EventEmitterTree) syncScalar(obs *pubsub.Aggregate, path string, type_ rowType.DataType) {
+	//the value only goes away (becomes null) when a HAVING stops passing
+	receiver.subscribe(obs, path, &pubsub.CustomSubscriber{
+		OnAddFunc: func(item rowType.RowType) {
+			receiver.On_message(SyncMessage{Type: SyncTypeUpdate, Data: pubsub.ValueToJson(item[0], type_), Path: path})
+		},
+		OnRemoveFunc: func(item rowType.RowType) {
+			receiver.On_message(SyncMessage{Type: SyncTypeUpdate, Data: "null", Path: path})
+		},
+		OnUpdateFunc: func(oldItem, newItem rowType.RowType) {
+			receiver.On_message(SyncMessage{Type: SyncTypeUpdate, Data: pubsub.ValueToJson(newItem[0], type_), Path: path})
+		},
+	})
+}
@@ -9,7 +9,8 @@ import (
 )
 
 type LocalLiveDB struct {
-	Data map[string]any
+	Data  map[string]any
+	Order map[string][]string //for ordered queries, the keys under a path in the order that they should be shown (built from the messages Position)
 }
 
 func (db *LocalLiveDB) HandleUpdate(update eventEmitterTree.SyncMessage) error {
@@ -62,6 +63,7 @@ func (db *LocalLiveDB) handleAdd(update eventEmitterTree.SyncMessage) error {
 
 	lastKey := parts[len(parts)-1]
 	current[lastKey] = data
+	db.placeKey(update)
 
 	return nil
 }
@@ -92,6 +94,7 @@ func (db *LocalLiveDB) handleRemove(update eventEmitterTree.SyncMessage) error {
 
 	lastKey := parts[len(parts)-1]
 	delete(current, lastKey)
+	db.unplaceKey(update.Path)
 
 	return nil
 }
@@ -127,6 +130,7 @@ func (db *LocalLiveDB) handleUpdateData(update eventEmitterTree.SyncMessage) err
 
 	lastKey := parts[len(parts)-1]
 	current[lastKey] = data
+	db.placeKey(update)
 
 	return nil
 }
@@ -143,6 +147,37 @@ func (db *LocalLiveDB) handleLoad(update eventEmitterTree.SyncMessage) error {
 	return nil
 }
 
+// moves (or inserts) the key the update is for to the position it was sent with, in the order of its parent path
+func (db *LocalLiveDB) placeKey(update eventEmitterTree.SyncMessage) {
+	if update.Position == nil {
+		return
+	}
+	db.unplaceKey(update.Path)
+	parentPath, key := splitLastPathPart(update.Path)
+	if db.Order == nil {
+		db.Order = make(map[string][]string)
+	}
+	keys := db.Order[parentPath]
+	position := min(*update.Position, len(keys))
+	db.Order[parentPath] = append(keys[:position], append([]string{key}, keys[position:]...)...)
+}
+
+func (db *LocalLiveDB) unplaceKey(path string) {
+	parentPath, key := splitLastPathPart(path)
+	keys := db.Order[parentPath]
+	for i := range keys {
+		if keys[i] == key {
+			db.Order[parentPath] = append(keys[:i], keys[i+1:]...)
+			return
+		}
+	}
+}
+
+func splitLastPathPart(path string) (string, string) {
+	lastSeparator := strings.LastIndex(path, "/")
+	return path[:lastSeparator], path[lastSeparator+1:]
+}
+
 func (db *LocalLiveDB) copyData(data map[string]any) map[string]any {
 	result := make(map[string]any)
 	for k, v := range data {
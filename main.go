@@ -51,7 +51,7 @@ func main() {
 		AllowWebSockets:  true,
 	}))
 
-	db_tables.Tables.Get("person").Index_on("age")
+	db_tables.Tables.Get("person").Index_on("age", pubsub.Ordered)
 
 	src := `SELECT person.name, person.email, person.age, person.id, person.profile_picture FROM person WHERE person.age >= 3 `
 
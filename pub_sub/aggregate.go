@@ -0,0 +1,394 @@
+// computes aggregate functions (COUNT, SUM, AVG, MIN, MAX and the registered ones) over the rows it gets, folding them into one row per group (the rows that have the same GROUP BY values).
+// without a GROUP BY every row is in the same group, and that group always has a row (like sql, even when there are no rows to aggregate)
+// whenever a row comes in or goes away the aggregates of its group are adjusted (instead of recomputed) and if the group's row changed its published as an update,
+// a group is added when it gets its first row (and passes HAVING) and removed when it loses its last row (or stops passing HAVING)
+package pubsub
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sql-compiler/compiler/rowType"
+	"sql-compiler/utils"
+	"strings"
+)
+
+// the state of an aggregate function for one group, a row's value is added when the row comes into the group and retracted when it leaves (an update retracts the old value and adds the new one),
+// so the result can be kept up to date instead of being recomputed from all the rows. the result is one of the values a column can hold (like an int, a string or a json.RawMessage)
+type Aggregator interface {
+	Add(value any)
+	Retract(value any)
+	Result() any
+}
+
+// the built in aggregators skip nulls (nil) themselves, like in sql
+var aggregate_functions = map[string]func() Aggregator{
+	"COUNT": func() Aggregator { return &count_aggregator{} },
+	"SUM":   func() Aggregator { return &sum_aggregator{} },
+	"AVG":   func() Aggregator { return &avg_aggregator{} },
+	"MIN":   func() Aggregator { return &min_max_aggregator{} },
+	"MAX":   func() Aggregator { return &min_max_aggregator{is_max: true} },
+}
+
+// makes an aggregate function available to Aggregate, new_aggregator makes the Aggregator of each group.
+// nulls never reach the registered aggregators (like they are skipped by the built in ones)
+func Register_aggregate(name string, new_aggregator func() Aggregator) {
+	name = strings.ToUpper(name)
+	if _, exists := aggregate_functions[name]; exists {
+		panic("there is already an aggregate function called " + name)
+	}
+	aggregate_functions[name] = func() Aggregator { return null_skipping_aggregator{new_aggregator()} }
+}
+
+type null_skipping_aggregator struct {
+	Aggregator
+}
+
+func (this null_skipping_aggregator) Add(value any) {
+	if value != nil {
+		this.Aggregator.Add(value)
+	}
+}
+func (this null_skipping_aggregator) Retract(value any) {
+	if value != nil {
+		this.Aggregator.Retract(value)
+	}
+}
+
+type Aggregate_input struct {
+	Func string
+	Arg  func(rowType.RowType) any //nil for COUNT(*), in which case every row is counted
+}
+
+type aggregate_group struct {
+	key_values    rowType.RowType
+	path_key      string
+	row_count     int
+	aggregators   []Aggregator
+	published_row rowType.RowType //nil while the group is not shown
+	replaced_row  rowType.RowType //the row the group had before, only while its removal or update is being published
+}
+
+type Aggregate struct {
+	Observable
+	subscribed_to ObservableI
+	group_by      func(row rowType.RowType) rowType.RowType //nil when all the rows are in one group
+	inputs        []Aggregate_input
+	having        func(group_row rowType.RowType) bool            //nil when every group is shown
+	project       func(group_row rowType.RowType) rowType.RowType //turns a group row (the group by values followed by the results of the aggregates, in the order of the inputs) into the row that gets published
+	row_schema    rowType.RowSchema
+	groups        map[string]*aggregate_group
+	group_order   []string //so groups are pulled in the order they were created
+	//the published rows don't have to contain the group by values, so the group whose row is being published or pulled is kept for Key_of
+	keying *aggregate_group
+}
+
+func NewAggregate(source ObservableI, group_by func(rowType.RowType) rowType.RowType, inputs []Aggregate_input, having func(rowType.RowType) bool, project func(rowType.RowType) rowType.RowType, row_schema rowType.RowSchema) *Aggregate {
+	a := &Aggregate{
+		Observable: Observable{
+			Subscribers: []Subscriber{},
+		},
+		group_by:   group_by,
+		inputs:     inputs,
+		having:     having,
+		project:    project,
+		row_schema: row_schema,
+		groups:     map[string]*aggregate_group{},
+	}
+	for _, input := range inputs {
+		if _, ok := aggregate_functions[strings.ToUpper(input.Func)]; !ok {
+			panic("unknown aggregate function " + input.Func)
+		}
+	}
+	if group_by == nil {
+		a.refresh(a.get_or_create_group(rowType.RowType{}))
+	}
+	for row := range source.Pull {
+		a.On_add(row)
+	}
+	Link(source, a)
+	return a
+}
+
+func (this *Aggregate) group_key_values(row rowType.RowType) rowType.RowType {
+	if this.group_by == nil {
+		return rowType.RowType{}
+	}
+	return this.group_by(row)
+}
+
+func (this *Aggregate) get_or_create_group(key_values rowType.RowType) *aggregate_group {
+	key := Encode_key(key_values)
+	if group, ok := this.groups[key]; ok {
+		return group
+	}
+	group := &aggregate_group{key_values: key_values, path_key: "0"}
+	if this.group_by != nil {
+		group.path_key = Path_key(key_values)
+	}
+	for _, input := range this.inputs {
+		group.aggregators = append(group.aggregators, aggregate_functions[strings.ToUpper(input.Func)]())
+	}
+	this.groups[key] = group
+	this.group_order = append(this.group_order, key)
+	return group
+}
+
+func (this *Aggregate) input_value(input Aggregate_input, row rowType.RowType) any {
+	if input.Arg == nil {
+		return true
+	}
+	return input.Arg(row)
+}
+
+func (this *Aggregate) add_row(group *aggregate_group, row rowType.RowType) {
+	group.row_count++
+	for i, input := range this.inputs {
+		group.aggregators[i].Add(this.input_value(input, row))
+	}
+}
+
+func (this *Aggregate) retract_row(group *aggregate_group, row rowType.RowType) {
+	group.row_count--
+	for i, input := range this.inputs {
+		group.aggregators[i].Retract(this.input_value(input, row))
+	}
+}
+
+func (this *Aggregate) group_row(group *aggregate_group) rowType.RowType {
+	res := slices.Clone(group.key_values)
+	for i := range group.aggregators {
+		res = append(res, group.aggregators[i].Result())
+	}
+	return res
+}
+
+// works out whether the group should be shown and what its row is now, and publishes the difference from what was published before
+func (this *Aggregate) refresh(group *aggregate_group) {
+	var new_row rowType.RowType
+	group_row := this.group_row(group)
+	is_empty := this.group_by != nil && group.row_count == 0
+	if !is_empty && (this.having == nil || this.having(group_row)) {
+		new_row = this.project(group_row)
+	}
+	old_row := group.published_row
+	previous := this.keying
+	this.keying, group.replaced_row = group, old_row
+	switch {
+	case old_row == nil && new_row == nil:
+	case old_row == nil:
+		group.published_row = new_row
+		this.Publish_Add(new_row)
+	case new_row == nil:
+		group.published_row = nil
+		this.Publish_remove(old_row)
+	case !same_row(old_row, new_row):
+		group.published_row = new_row
+		this.Publish_Update(old_row, new_row)
+	}
+	this.keying, group.replaced_row = previous, nil
+	if is_empty {
+		key := Encode_key(group.key_values)
+		delete(this.groups, key)
+		this.group_order = slices.DeleteFunc(this.group_order, func(k string) bool { return k == key })
+	}
+}
+
+// the value of the only column of the only group, for when the aggregate is used as a scalar subquery
+func (this *Aggregate) Scalar_value() any {
+	for row := range this.Pull {
+		return row[0]
+	}
+	return nil
+}
+
+// a group's row is stored under its group by values (see Path_key), or under 0 when everything is in one group, even though its columns keep changing.
+// two groups can have the same row, so the group that is being published or pulled goes first
+func (this *Aggregate) Key_of(row rowType.RowType) string {
+	has_row := func(group *aggregate_group) bool {
+		return (group.published_row != nil && same_row(group.published_row, row)) || (group.replaced_row != nil && same_row(group.replaced_row, row))
+	}
+	if this.keying != nil && has_row(this.keying) {
+		return this.keying.path_key
+	}
+	for _, key := range this.group_order {
+		if has_row(this.groups[key]) {
+			return this.groups[key].path_key
+		}
+	}
+	panic("the row was not published by this aggregate")
+}
+
+func (this *Aggregate) Set_subscribed_to(observable ObservableI) {
+	this.subscribed_to = observable
+}
+
+func (this *Aggregate) Pull(yield func(rowType.RowType) bool) {
+	for _, key := range this.group_order {
+		group := this.groups[key]
+		if group.published_row == nil {
+			continue
+		}
+		previous := this.keying
+		this.keying = group
+		keep_going := yield(group.published_row)
+		this.keying = previous
+		if !keep_going {
+			return
+		}
+	}
+}
+
+func (this *Aggregate) On_add(row rowType.RowType) {
+	group := this.get_or_create_group(this.group_key_values(row))
+	this.add_row(group, row)
+	this.refresh(group)
+}
+
+func (this *Aggregate) On_remove(row rowType.RowType) {
+	group := this.get_or_create_group(this.group_key_values(row))
+	this.retract_row(group, row)
+	this.refresh(group)
+}
+
+func (this *Aggregate) On_update(old_row rowType.RowType, new_row rowType.RowType) {
+	old_group := this.get_or_create_group(this.group_key_values(old_row))
+	new_group := this.get_or_create_group(this.group_key_values(new_row))
+	this.retract_row(old_group, old_row)
+	if old_group == new_group {
+		this.add_row(new_group, new_row)
+		this.refresh(new_group)
+		return
+	}
+	this.refresh(old_group)
+	this.add_row(new_group, new_row)
+	this.refresh(new_group)
+}
+
+func (this *Aggregate) GetRowSchema() rowType.RowSchema {
+	return this.row_schema
+}
+
+// ///
+
+type count_aggregator struct {
+	count int
+}
+
+func (this *count_aggregator) Add(value any) {
+	if value != nil {
+		this.count++
+	}
+}
+func (this *count_aggregator) Retract(value any) {
+	if value != nil {
+		this.count--
+	}
+}
+func (this *count_aggregator) Result() any {
+	return this.count
+}
+
+// stays an int as long as it only summed ints
+type sum_aggregator struct {
+	count     int
+	int_sum   int
+	float_sum float64
+	has_float bool
+}
+
+func (this *sum_aggregator) adjust(value any, sign int) {
+	switch value := value.(type) {
+	case nil:
+		return
+	case int:
+		this.int_sum += sign * value
+	case float64:
+		this.float_sum += float64(sign) * value
+		this.has_float = true
+	default:
+		panic(fmt.Sprintf("can not sum a %T", value))
+	}
+	this.count += sign
+}
+func (this *sum_aggregator) Add(value any) {
+	this.adjust(value, 1)
+}
+func (this *sum_aggregator) Retract(value any) {
+	this.adjust(value, -1)
+}
+func (this *sum_aggregator) Result() any {
+	if this.count == 0 {
+		return nil
+	}
+	if this.has_float {
+		return float64(this.int_sum) + this.float_sum
+	}
+	return this.int_sum
+}
+
+type avg_aggregator struct {
+	sum sum_aggregator
+}
+
+func (this *avg_aggregator) Add(value any) {
+	this.sum.Add(value)
+}
+func (this *avg_aggregator) Retract(value any) {
+	this.sum.Retract(value)
+}
+func (this *avg_aggregator) Result() any {
+	if this.sum.count == 0 {
+		return nil
+	}
+	return (float64(this.sum.int_sum) + this.sum.float_sum) / float64(this.sum.count)
+}
+
+// keeps every distinct value with how many times it was added (a multiset), so that when the current min/max is retracted the next one is known
+type min_max_aggregator struct {
+	is_max bool
+	values []any //sorted
+	counts []int
+}
+
+func (this *min_max_aggregator) find(value any) (int, bool) {
+	index := sort.Search(len(this.values), func(i int) bool {
+		return utils.CompareValues(this.values[i], value) >= 0
+	})
+	return index, index < len(this.values) && utils.CompareValues(this.values[index], value) == 0
+}
+func (this *min_max_aggregator) Add(value any) {
+	if value == nil {
+		return
+	}
+	index, found := this.find(value)
+	if found {
+		this.counts[index]++
+		return
+	}
+	this.values = slices.Insert(this.values, index, value)
+	this.counts = slices.Insert(this.counts, index, 1)
+}
+func (this *min_max_aggregator) Retract(value any) {
+	if value == nil {
+		return
+	}
+	index, found := this.find(value)
+	if !found {
+		panic(fmt.Sprintf("retracting %v which was never added", value))
+	}
+	this.counts[index]--
+	if this.counts[index] == 0 {
+		this.values = slices.Delete(this.values, index, index+1)
+		this.counts = slices.Delete(this.counts, index, index+1)
+	}
+}
+func (this *min_max_aggregator) Result() any {
+	if len(this.values) == 0 {
+		return nil
+	}
+	if this.is_max {
+		return this.values[len(this.values)-1]
+	}
+	return this.values[0]
+}
@@ -3,6 +3,7 @@ package pubsub
 import "sql-compiler/compiler/rowType"
 
 // CustomSubscriber is a flexible subscriber that allows custom callback functions
+// It is meant to be at the end of a chain (it has no rows of its own to Pull), an operator that publishes rows should implement ObservableI and Subscriber instead
 type CustomSubscriber struct {
 	Observable
 	subscribed_to       ObservableI
@@ -30,7 +31,7 @@ func NewCustomSubscriber(
 		OnDeleteWhereEqFunc: onDeleteWhereEq,
 	}
 }
-func (this *CustomSubscriber) set_subscribed_to(observable ObservableI) {
+func (this *CustomSubscriber) Set_subscribed_to(observable ObservableI) {
 	this.subscribed_to = observable
 }
 
@@ -38,8 +39,8 @@ func (this *CustomSubscriber) Add_sub(subscriber Subscriber) {
 	this.Subscribers = append(this.Subscribers, subscriber)
 }
 
-// on_Add is called when an item is added
-func (receiver *CustomSubscriber) on_Add(item rowType.RowType) {
+// On_add is called when an item is added
+func (receiver *CustomSubscriber) On_add(item rowType.RowType) {
 	if receiver.OnAddFunc != nil {
 		receiver.OnAddFunc(item)
 	} else {
@@ -55,8 +56,8 @@ func (receiver *CustomSubscriber) DeleteWhereEq(key string, value string) {
 	}
 }
 
-// on_remove is called when an item is removed
-func (receiver *CustomSubscriber) on_remove(item rowType.RowType) {
+// On_remove is called when an item is removed
+func (receiver *CustomSubscriber) On_remove(item rowType.RowType) {
 	if receiver.OnRemoveFunc != nil {
 		receiver.OnRemoveFunc(item)
 	} else {
@@ -64,8 +65,8 @@ func (receiver *CustomSubscriber) on_remove(item rowType.RowType) {
 	}
 }
 
-// on_update is called when an item is updated
-func (receiver *CustomSubscriber) on_update(oldItem, newItem rowType.RowType) {
+// On_update is called when an item is updated
+func (receiver *CustomSubscriber) On_update(oldItem, newItem rowType.RowType) {
 	if receiver.OnUpdateFunc != nil {
 		receiver.OnUpdateFunc(oldItem, newItem)
 	} else {
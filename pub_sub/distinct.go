@@ -0,0 +1,144 @@
+// only lets one row through for each combination of values of the distinct columns (all of them for a plain DISTINCT), the first one that came in.
+// every row is counted (a multiset), so a row is only removed when the last of its duplicates is, and when the published row of a DISTINCT ON goes away the next row with the same values takes its place (as an update)
+package pubsub
+
+import (
+	"slices"
+	"sql-compiler/compiler/rowType"
+)
+
+type distinct_group struct {
+	rows   []rowType.RowType //the different rows with the group's values, in the order they came in, the first one is the one that is published
+	counts []int             //how many times each of the rows was added
+}
+
+type Distinct struct {
+	Observable
+	subscribed_to ObservableI
+	col_indexes   []int
+	groups        map[string]*distinct_group
+	group_order   []string //so rows are pulled in the order they were first added
+}
+
+func NewDistinct(source ObservableI, col_indexes []int) *Distinct {
+	d := &Distinct{
+		Observable: Observable{
+			Subscribers: []Subscriber{},
+		},
+		col_indexes: col_indexes,
+		groups:      map[string]*distinct_group{},
+	}
+	for row := range source.Pull {
+		d.On_add(row)
+	}
+	Link(source, d)
+	return d
+}
+
+func (this *Distinct) key_values(row rowType.RowType) rowType.RowType {
+	key_values := rowType.RowType{}
+	for _, col_index := range this.col_indexes {
+		key_values = append(key_values, row[col_index])
+	}
+	return key_values
+}
+
+// adds the row to its group and returns the group's published row from before (nil if the group is new)
+func (this *Distinct) insert(row rowType.RowType) rowType.RowType {
+	key := Encode_key(this.key_values(row))
+	group, ok := this.groups[key]
+	if !ok {
+		group = &distinct_group{}
+		this.groups[key] = group
+		this.group_order = append(this.group_order, key)
+	}
+	var published rowType.RowType
+	if len(group.rows) > 0 {
+		published = group.rows[0]
+	}
+	index := slices.IndexFunc(group.rows, func(other rowType.RowType) bool { return same_row(other, row) })
+	if index == -1 {
+		group.rows = append(group.rows, row)
+		group.counts = append(group.counts, 1)
+	} else {
+		group.counts[index]++
+	}
+	return published
+}
+
+// removes the row from its group and returns the group's published row from before and after (nil when the group is gone)
+func (this *Distinct) delete(row rowType.RowType) (rowType.RowType, rowType.RowType) {
+	key := Encode_key(this.key_values(row))
+	group, ok := this.groups[key]
+	index := -1
+	if ok {
+		index = slices.IndexFunc(group.rows, func(other rowType.RowType) bool { return same_row(other, row) })
+	}
+	if index == -1 {
+		panic("removing a row that was never added to the distinct")
+	}
+	published := group.rows[0]
+	group.counts[index]--
+	if group.counts[index] == 0 {
+		group.rows = slices.Delete(group.rows, index, index+1)
+		group.counts = slices.Delete(group.counts, index, index+1)
+	}
+	if len(group.rows) == 0 {
+		delete(this.groups, key)
+		this.group_order = slices.DeleteFunc(this.group_order, func(k string) bool { return k == key })
+		return published, nil
+	}
+	return published, group.rows[0]
+}
+
+// a row is stored under the values of the distinct columns (joined into path segments), as rows that only differ in the other columns are the same row
+func (this *Distinct) Key_of(row rowType.RowType) string {
+	return Path_key(this.key_values(row))
+}
+
+func (this *Distinct) Set_subscribed_to(observable ObservableI) {
+	this.subscribed_to = observable
+}
+
+func (this *Distinct) Pull(yield func(rowType.RowType) bool) {
+	for _, key := range this.group_order {
+		if !yield(this.groups[key].rows[0]) {
+			return
+		}
+	}
+}
+
+func (this *Distinct) On_add(row rowType.RowType) {
+	if this.insert(row) == nil {
+		this.Publish_Add(row)
+	}
+}
+
+func (this *Distinct) On_remove(row rowType.RowType) {
+	old_published, new_published := this.delete(row)
+	switch {
+	case new_published == nil:
+		this.Publish_remove(old_published)
+	case !same_row(old_published, new_published):
+		this.Publish_Update(old_published, new_published)
+	}
+}
+
+// when the row stays in the same group only the published row can change, otherwise its like the old row was removed and the new one added
+func (this *Distinct) On_update(old_row rowType.RowType, new_row rowType.RowType) {
+	if Encode_key(this.key_values(old_row)) != Encode_key(this.key_values(new_row)) {
+		this.On_remove(old_row)
+		this.On_add(new_row)
+		return
+	}
+	//adding the new row first keeps the group from being emptied (and moved to the end) when the old row is its only one
+	old_published := this.insert(new_row)
+	_, new_published := this.delete(old_row)
+	if !same_row(old_published, new_published) {
+		this.Publish_Update(old_published, new_published)
+	}
+}
+
+func (this *Distinct) GetRowSchema() rowType.RowSchema {
+	return this.subscribed_to.GetRowSchema()
+}
@@ -11,7 +11,7 @@ type Filter struct {
 	predicate     func(RowType) bool
 }
 
-func (this *Filter) set_subscribed_to(observable ObservableI) {
+func (this *Filter) Set_subscribed_to(observable ObservableI) {
 	this.subscribed_to = observable
 }
 
@@ -25,19 +25,19 @@ func (this *Filter) Pull(yield func(RowType) bool) {
 	}
 }
 
-func (this *Filter) on_Add(row RowType) {
+func (this *Filter) On_add(row RowType) {
 	if this.predicate(row) {
 		this.Publish_Add(row)
 	}
 }
 
-func (this *Filter) on_remove(row RowType) {
+func (this *Filter) On_remove(row RowType) {
 	if this.predicate(row) {
 		this.Publish_remove(row)
 	}
 }
 
-func (this *Filter) on_update(old_row RowType, new_row RowType) {
+func (this *Filter) On_update(old_row RowType, new_row RowType) {
 	old_passed := this.predicate(old_row)
 	new_passed := this.predicate(new_row)
 	if old_passed && !new_passed {
@@ -52,3 +52,8 @@ func (this *Filter) on_update(old_row RowType, new_row RowType) {
 func (this *Filter) GetRowSchema() rowType.RowSchema {
 	return this.subscribed_to.GetRowSchema()
 }
+
+// a filter keeps the rows as they are, so they are keyed like in the source
+func (this *Filter) Key_of(row RowType) string {
+	return Row_key(this.subscribed_to, row)
+}
@@ -4,32 +4,26 @@ package pubsub
 import (
 	"sql-compiler/compiler/rowType"
 	"sql-compiler/debugutil"
-	"strconv"
 )
 
 type GroupBy struct {
 	Observable
-	subscribed_to            ObservableI
-	index_of_col_to_group_by int //generated at compile time, to be used on RowSchema
+	subscribed_to               ObservableI
+	indexes_of_cols_to_group_by []int //generated at compile time, to be used on RowSchema
 	//another (more efficient (less S.O.L.I.D.), less solid way to do this is to just have this classes fields be public and have the event emmiter tree create the path directly instead of creating an entire observable for each separate group) way to do this is to have a map of the different groups and then when a row is added, removed or updated, we can just update the relevant group
 	// different_groups map[string]Observable //this is so that the eventEmitterTree can create path based off how its grouped
 }
 
-func (this *GroupBy) Get_rows_group_value(row *rowType.RowType) string {
-	// debugutil.Print(row, "row")
-	// debugutil.Print(row, "is row, ")
-	debugutil.Print(this.index_of_col_to_group_by, "this.index_of_col_to_group_by")
-	rows_group_value := (*row)[this.index_of_col_to_group_by]
-	switch rows_group_value := rows_group_value.(type) {
-	case string:
-		return rows_group_value
-	case int:
-		return strconv.Itoa(rows_group_value)
-	default:
-		panic("unexpected type")
+// the path segments of the group the row is in, one segment per column that is grouped by
+func (this *GroupBy) Get_rows_group_path(row *rowType.RowType) string {
+	debugutil.Print(this.indexes_of_cols_to_group_by, "this.indexes_of_cols_to_group_by")
+	group_values := rowType.RowType{}
+	for _, index := range this.indexes_of_cols_to_group_by {
+		group_values = append(group_values, (*row)[index])
 	}
+	return Path_segments(group_values)
 }
-func (this *GroupBy) set_subscribed_to(observable ObservableI) {
+func (this *GroupBy) Set_subscribed_to(observable ObservableI) {
 	this.subscribed_to = observable
 }
 
@@ -40,15 +34,15 @@ func (this *GroupBy) Pull(yield func(rowType.RowType) bool) {
 		}
 	}
 }
-func (this *GroupBy) on_Add(row rowType.RowType) {
+func (this *GroupBy) On_add(row rowType.RowType) {
 	this.Publish_Add(row) //no point trying to combine the logic for the actual group because at the end of the day (according to the current architecture) its up to the receiver to make sure that it stores the grabbed data in a extra path'd way
 }
 
-func (this *GroupBy) on_remove(row rowType.RowType) {
+func (this *GroupBy) On_remove(row rowType.RowType) {
 	this.Publish_remove(row)
 }
 
-func (this *GroupBy) on_update(old_row rowType.RowType, new_row rowType.RowType) {
+func (this *GroupBy) On_update(old_row rowType.RowType, new_row rowType.RowType) {
 	this.Publish_Update(old_row, new_row)
 }
 
@@ -59,6 +53,12 @@ func (this *GroupBy) String() string {
 	}
 	return res + "]"
 }
+
+// grouping keeps the rows as they are, so they are keyed like in the source
+func (this *GroupBy) Key_of(row rowType.RowType) string {
+	return Row_key(this.subscribed_to, row)
+}
+
 func (this *GroupBy) GetRowSchema() rowType.RowSchema {
 	return this.subscribed_to.GetRowSchema()
 }
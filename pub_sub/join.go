@@ -0,0 +1,236 @@
+// joins the rows of two observables whose keys are equal (a hash join), every output row is the left row's columns followed by the right row's columns.
+// both sides keep their rows in buckets by key, so when a row comes in on one side it's combined with the rows in the other side's bucket for the same key.
+// outer joins also output the rows of their outer side(s) that have no match, padded with nulls where the other side's columns would be,
+// so when the first match for a key comes in the padded rows for that key are removed (and they come back when the last match goes away)
+package pubsub
+
+import (
+	"slices"
+	"sql-compiler/compiler/rowType"
+)
+
+type Join_kind int
+
+const (
+	Inner_join Join_kind = iota
+	Left_join
+	Right_join
+	Full_join
+)
+
+type join_side struct {
+	source  ObservableI
+	key     func(rowType.RowType) rowType.RowType
+	buckets map[string][]rowType.RowType //by the encoded key
+}
+
+// a key with a null in it doesn't match anything (not even another null, like in sql), so its row never goes into a bucket
+func (this *join_side) bucket_key(row rowType.RowType) (string, bool) {
+	key := this.key(row)
+	if slices.Contains(key, nil) {
+		return "", false
+	}
+	return Encode_key(key), true
+}
+
+func (this *join_side) add(row rowType.RowType) {
+	if key, ok := this.bucket_key(row); ok {
+		this.buckets[key] = append(this.buckets[key], row)
+	}
+}
+
+func (this *join_side) remove(row rowType.RowType) {
+	key, ok := this.bucket_key(row)
+	if !ok {
+		return
+	}
+	index := slices.IndexFunc(this.buckets[key], func(other rowType.RowType) bool { return same_row(other, row) })
+	if index == -1 {
+		panic("removing a row that was never added to the join")
+	}
+	this.buckets[key] = slices.Delete(this.buckets[key], index, index+1)
+	if len(this.buckets[key]) == 0 {
+		delete(this.buckets, key)
+	}
+}
+
+// the rows of this side that a row of the other side matches
+func (this *join_side) matches(other_side *join_side, row rowType.RowType) []rowType.RowType {
+	key, ok := other_side.bucket_key(row)
+	if !ok {
+		return nil
+	}
+	return this.buckets[key]
+}
+
+func (this *join_side) width() int {
+	return len(this.source.GetRowSchema())
+}
+
+type Join struct {
+	Observable
+	kind       Join_kind
+	left       join_side
+	right      join_side
+	row_schema rowType.RowSchema
+}
+
+func NewJoin(kind Join_kind, left ObservableI, right ObservableI, left_key func(rowType.RowType) rowType.RowType, right_key func(rowType.RowType) rowType.RowType) *Join {
+	j := &Join{
+		Observable: Observable{
+			Subscribers: []Subscriber{},
+		},
+		kind:       kind,
+		left:       join_side{source: left, key: left_key, buckets: map[string][]rowType.RowType{}},
+		right:      join_side{source: right, key: right_key, buckets: map[string][]rowType.RowType{}},
+		row_schema: combineSchemas(left.GetRowSchema(), right.GetRowSchema()),
+	}
+	for row := range left.Pull {
+		j.left.add(row)
+	}
+	for row := range right.Pull {
+		j.right.add(row)
+	}
+	Link(left, &CustomSubscriber{
+		OnAddFunc:    func(row rowType.RowType) { j.on_side_add(&j.left, &j.right, row) },
+		OnRemoveFunc: func(row rowType.RowType) { j.on_side_remove(&j.left, &j.right, row) },
+		OnUpdateFunc: func(old_row, new_row rowType.RowType) { j.on_side_update(&j.left, &j.right, old_row, new_row) },
+	})
+	Link(right, &CustomSubscriber{
+		OnAddFunc:    func(row rowType.RowType) { j.on_side_add(&j.right, &j.left, row) },
+		OnRemoveFunc: func(row rowType.RowType) { j.on_side_remove(&j.right, &j.left, row) },
+		OnUpdateFunc: func(old_row, new_row rowType.RowType) { j.on_side_update(&j.right, &j.left, old_row, new_row) },
+	})
+	return j
+}
+
+// joins on a single column of each side, full_join = the rows of both sides are kept even when they have no match
+func NewFullOuterJoin(source_one ObservableI, source_two ObservableI, source_one_on int, source_two_on int) *Join {
+	return NewJoin(Full_join, source_one, source_two, col_key(source_one_on), col_key(source_two_on))
+}
+
+func col_key(col_index int) func(rowType.RowType) rowType.RowType {
+	return func(row rowType.RowType) rowType.RowType {
+		return rowType.RowType{row[col_index]}
+	}
+}
+
+func combineSchemas(schema1, schema2 rowType.RowSchema) rowType.RowSchema {
+	result := make(rowType.RowSchema, len(schema1)+len(schema2))
+	copy(result, schema1)
+	copy(result[len(schema1):], schema2)
+	return result
+}
+
+// whether the rows of the side that have no match are output (padded with nulls)
+func (this *Join) keeps_unmatched(side *join_side) bool {
+	if side == &this.left {
+		return this.kind == Left_join || this.kind == Full_join
+	}
+	return this.kind == Right_join || this.kind == Full_join
+}
+
+// the row of side combined with a row of the other side, the left row always goes first
+func (this *Join) combine_rows(side *join_side, row rowType.RowType, other_row rowType.RowType) rowType.RowType {
+	if side == &this.right {
+		row, other_row = other_row, row
+	}
+	return append(slices.Clone(row), other_row...)
+}
+
+func (this *Join) padded(side *join_side, other *join_side, row rowType.RowType) rowType.RowType {
+	return this.combine_rows(side, row, make(rowType.RowType, other.width()))
+}
+
+// the left rows (each with its matches, or padded if it has none and is kept), followed by the kept right rows that have no match
+func (this *Join) Pull(yield func(rowType.RowType) bool) {
+	for left_row := range this.left.source.Pull {
+		matches := this.right.matches(&this.left, left_row)
+		if len(matches) == 0 && this.keeps_unmatched(&this.left) {
+			if !yield(this.padded(&this.left, &this.right, left_row)) {
+				return
+			}
+		}
+		for _, right_row := range matches {
+			if !yield(this.combine_rows(&this.left, left_row, right_row)) {
+				return
+			}
+		}
+	}
+	if !this.keeps_unmatched(&this.right) {
+		return
+	}
+	for right_row := range this.right.source.Pull {
+		if len(this.left.matches(&this.right, right_row)) == 0 {
+			if !yield(this.padded(&this.right, &this.left, right_row)) {
+				return
+			}
+		}
+	}
+}
+
+func (this *Join) on_side_add(side *join_side, other *join_side, row rowType.RowType) {
+	matches := other.matches(side, row)
+	if len(matches) == 0 {
+		side.add(row)
+		if this.keeps_unmatched(side) {
+			this.Publish_Add(this.padded(side, other, row))
+		}
+		return
+	}
+	//the rows it matched were unmatched until now
+	first_match := len(side.matches(other, matches[0])) == 0
+	side.add(row)
+	for _, other_row := range matches {
+		if first_match && this.keeps_unmatched(other) {
+			this.Publish_remove(this.padded(other, side, other_row))
+		}
+		this.Publish_Add(this.combine_rows(side, row, other_row))
+	}
+}
+
+func (this *Join) on_side_remove(side *join_side, other *join_side, row rowType.RowType) {
+	side.remove(row)
+	matches := other.matches(side, row)
+	if len(matches) == 0 {
+		if this.keeps_unmatched(side) {
+			this.Publish_remove(this.padded(side, other, row))
+		}
+		return
+	}
+	//the rows it matched are unmatched now
+	was_last_match := len(side.matches(other, matches[0])) == 0
+	for _, other_row := range matches {
+		this.Publish_remove(this.combine_rows(side, row, other_row))
+		if was_last_match && this.keeps_unmatched(other) {
+			this.Publish_Add(this.padded(other, side, other_row))
+		}
+	}
+}
+
+// when the key changes the row leaves its old matches and joins its new ones, otherwise each of its joined rows is updated
+func (this *Join) on_side_update(side *join_side, other *join_side, old_row rowType.RowType, new_row rowType.RowType) {
+	old_key, old_ok := side.bucket_key(old_row)
+	new_key, new_ok := side.bucket_key(new_row)
+	if old_key != new_key || old_ok != new_ok {
+		this.on_side_remove(side, other, old_row)
+		this.on_side_add(side, other, new_row)
+		return
+	}
+	side.remove(old_row)
+	side.add(new_row)
+	matches := other.matches(side, new_row)
+	if len(matches) == 0 {
+		if this.keeps_unmatched(side) {
+			this.Publish_Update(this.padded(side, other, old_row), this.padded(side, other, new_row))
+		}
+		return
+	}
+	for _, other_row := range matches {
+		this.Publish_Update(this.combine_rows(side, old_row, other_row), this.combine_rows(side, new_row, other_row))
+	}
+}
+
+func (this *Join) GetRowSchema() rowType.RowSchema {
+	return this.row_schema
+}
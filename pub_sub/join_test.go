@@ -1,6 +1,8 @@
 package pubsub
 
 import (
+	"fmt"
+	"slices"
 	"sql-compiler/assert"
 	"sql-compiler/compiler/rowType"
 	"sql-compiler/unwrap"
@@ -25,7 +27,7 @@ func TestFullOuterJoin(t *testing.T) {
 	}
 
 	j := NewFullOuterJoin(&people, &todos, 0, 2)
-	j.To_display(unwrap.Some(j.GetRowSchema()))
+	To_display(j, unwrap.Some(j.GetRowSchema()))
 
 	people.Add(rowType.RowType{"1", "bob", "email", "22"})
 	todos.Add(rowType.RowType{"clean room", "true", "1"})
@@ -35,13 +37,94 @@ func TestFullOuterJoin(t *testing.T) {
 	for m := range j.Pull {
 		results = append(results, m)
 	}
-	assert.TAssertEq(t, len(results), 2)
+	//bob with both todos and jan padded with nulls, as jan has no todos
+	assert.TAssertEq(t, len(results), 3)
 	//
 	results = []rowType.RowType{}
 	people.Add(rowType.RowType{"1", "danny", "email", "22"})
 	for m := range j.Pull {
 		results = append(results, m)
 	}
-	assert.TAssertEq(t, len(results), 4)
+	assert.TAssertEq(t, len(results), 5)
 
 }
+
+// keeps what the subscribers were told (as a multiset of rows) and checks it against what Pull gives after every change
+func TestJoinKinds(t *testing.T) {
+	for _, kind := range []Join_kind{Inner_join, Left_join, Right_join, Full_join} {
+		people_schema := rowType.RowSchema{{Name: "id", Type: rowType.Int}, {Name: "name", Type: rowType.String}}
+		todos_schema := rowType.RowSchema{{Name: "title", Type: rowType.String}, {Name: "person_id", Type: rowType.Int}}
+		people := New_R_Table(people_schema)
+		todos := New_R_Table(todos_schema)
+		people.Add(rowType.RowType{1, "bob"})
+		todos.Add(rowType.RowType{"no one's", 3})
+
+		j := NewJoin(kind, &people, &todos, col_key(0), col_key(1))
+		seen := map[string]int{}
+		j.Add_sub(NewCustomSubscriber(
+			func(row rowType.RowType) { seen[fmt.Sprint(row)]++ },
+			func(row rowType.RowType) { seen[fmt.Sprint(row)]-- },
+			func(old_row, new_row rowType.RowType) { seen[fmt.Sprint(old_row)]--; seen[fmt.Sprint(new_row)]++ },
+			nil,
+		))
+		for row := range j.Pull {
+			seen[fmt.Sprint(row)]++
+		}
+		pulled := func() []string {
+			res := []string{}
+			for row := range j.Pull {
+				res = append(res, fmt.Sprint(row))
+			}
+			slices.Sort(res)
+			return res
+		}
+		check := func(expected ...string) {
+			t.Helper()
+			slices.Sort(expected)
+			told := []string{}
+			for row, count := range seen {
+				assert.TAssert(t, count == 0 || count == 1, fmt.Sprintf("join %d told its subscribers about %s %d times", kind, row, count))
+				if count == 1 {
+					told = append(told, row)
+				}
+			}
+			slices.Sort(told)
+			assert.TAssert(t, slices.Equal(pulled(), expected), fmt.Sprintf("join %d pulled %v instead of %v", kind, pulled(), expected))
+			assert.TAssert(t, slices.Equal(told, expected), fmt.Sprintf("join %d told %v instead of %v", kind, told, expected))
+		}
+		keep_left := kind == Left_join || kind == Full_join
+		keep_right := kind == Right_join || kind == Full_join
+		expect := func(matched []string, unmatched_left []string, unmatched_right []string) {
+			t.Helper()
+			expected := slices.Clone(matched)
+			if keep_left {
+				expected = append(expected, unmatched_left...)
+			}
+			if keep_right {
+				expected = append(expected, unmatched_right...)
+			}
+			check(expected...)
+		}
+
+		expect(nil, []string{"[1 bob <nil> <nil>]"}, []string{"[<nil> <nil> no one's 3]"})
+
+		todos.Add(rowType.RowType{"bob's", 1})
+		expect([]string{"[1 bob bob's 1]"}, nil, []string{"[<nil> <nil> no one's 3]"})
+
+		todos.Add(rowType.RowType{"also bob's", 1})
+		expect([]string{"[1 bob bob's 1]", "[1 bob also bob's 1]"}, nil, []string{"[<nil> <nil> no one's 3]"})
+
+		//the key changes, so the todo leaves bob and finds no one
+		todos.Update_where_eq(todos_schema, "title", "bob's", rowType.RowType{"bob's", 2})
+		expect([]string{"[1 bob also bob's 1]"}, nil, []string{"[<nil> <nil> no one's 3]", "[<nil> <nil> bob's 2]"})
+
+		people.Add(rowType.RowType{3, "jan"})
+		expect([]string{"[1 bob also bob's 1]", "[3 jan no one's 3]"}, nil, []string{"[<nil> <nil> bob's 2]"})
+
+		todos.Remove_where_eq(todos_schema, "title", "also bob's")
+		expect([]string{"[3 jan no one's 3]"}, []string{"[1 bob <nil> <nil>]"}, []string{"[<nil> <nil> bob's 2]"})
+
+		people.Update_where_eq(people_schema, "name", "bob", rowType.RowType{1, "bobby"})
+		expect([]string{"[3 jan no one's 3]"}, []string{"[1 bobby <nil> <nil>]"}, []string{"[<nil> <nil> bob's 2]"})
+	}
+}
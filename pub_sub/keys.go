@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sql-compiler/compiler/rowType"
+	"sql-compiler/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// turns a list of values into a string that is only equal to another list's if all the values (and their types) are equal, so that it can be used as a map key.
+// each value is written with a tag for its type and the length of its text, so 1 and "1" don't collide and neither do ("a/b") and ("a", "b")
+func Encode_key(values rowType.RowType) string {
+	var res strings.Builder
+	for _, value := range values {
+		var tag, text string
+		switch value := value.(type) {
+		case nil:
+			tag = "n"
+		case string:
+			tag, text = "s", value
+		case int:
+			tag, text = "i", strconv.Itoa(value)
+		case float64:
+			tag, text = "f", strconv.FormatFloat(value, 'g', -1, 64)
+		case bool:
+			tag, text = "b", strconv.FormatBool(value)
+		case time.Time:
+			tag, text = "t", utils.String_or_num_to_string(value)
+		case json.RawMessage:
+			tag, text = "j", string(value)
+		default:
+			panic(fmt.Sprintf("can not use a %T as a key", value))
+		}
+		res.WriteString(tag + strconv.Itoa(len(text)) + ":" + text)
+	}
+	return res.String()
+}
+
+// the key of values that are compared with == (like the keys of a join), an int is the same key as the float with the same value, as they are equal when compared
+func equality_key(values rowType.RowType) string {
+	values = slices.Clone(values)
+	for i := range values {
+		if n, is_int := values[i].(int); is_int {
+			values[i] = float64(n)
+		}
+	}
+	return Encode_key(values)
+}
+
+// the readable version of a key made of several values (like the values of a multi column GROUP BY), as a single path segment so the row isn't nested when its path is split.
+// the values are separated by "," and have "%", "," and "/" escaped like in a url, so ("a,b") and ("a", "b") don't collide
+func Path_key(values rowType.RowType) string {
+	return escaped_join(values, ",")
+}
+
+// each value is its own path segment, for the extra path segments a GROUP BY without aggregates puts its rows under
+func Path_segments(values rowType.RowType) string {
+	return escaped_join(values, "/")
+}
+
+var path_escaper = strings.NewReplacer("%", "%25", ",", "%2C", "/", "%2F")
+
+func escaped_join(values rowType.RowType, separator string) string {
+	parts := make([]string, len(values))
+	for i := range values {
+		parts[i] = path_escaper.Replace(utils.String_or_num_to_string(values[i]))
+	}
+	return strings.Join(parts, separator)
+}
@@ -3,16 +3,19 @@ package pubsub
 import (
 	"sql-compiler/compiler/rowType"
 	"sql-compiler/unwrap"
+	"sql-compiler/utils"
 )
 
 type Mapper struct {
 	Observable
-	transformer   func(rowType.RowType) rowType.RowType
-	subscribed_to ObservableI
-	RowSchema     unwrap.Option[rowType.RowSchema] //created when compiling the select, bases it off the tables (that were selecting from) schema and only places ones for the values that are actually being selected
+	transformer    func(rowType.RowType) rowType.RowType
+	subscribed_to  ObservableI
+	RowSchema      unwrap.Option[rowType.RowSchema] //created when compiling the select, bases it off the tables (that were selecting from) schema and only places ones for the values that are actually being selected
+	Key_col        int                              //the column the rows are keyed by, the one the primary key of the table is selected into (the first column when it isn't selected)
+	Join_keys_from int                              //the rows of a join have the key of each of its tables after the selected values (from this column on), they are keyed by all of them. 0 when there are none
 }
 
-func (this *Mapper) set_subscribed_to(observable ObservableI) {
+func (this *Mapper) Set_subscribed_to(observable ObservableI) {
 	this.subscribed_to = observable
 }
 
@@ -23,15 +26,15 @@ func (this *Mapper) Pull(yield func(rowType.RowType) bool) {
 		}
 	}
 }
-func (this *Mapper) on_Add(row rowType.RowType) {
+func (this *Mapper) On_add(row rowType.RowType) {
 	this.Publish_Add(this.transformer(row))
 }
 
-func (this *Mapper) on_remove(row rowType.RowType) {
+func (this *Mapper) On_remove(row rowType.RowType) {
 	this.Publish_remove(this.transformer(row))
 }
 
-func (this *Mapper) on_update(old_row rowType.RowType, new_row rowType.RowType) {
+func (this *Mapper) On_update(old_row rowType.RowType, new_row rowType.RowType) {
 	this.Publish_Update(this.transformer(old_row), this.transformer(new_row))
 }
 
@@ -42,6 +45,13 @@ func (this *Mapper) String() string {
 	}
 	return res + "]"
 }
+func (this *Mapper) Key_of(row rowType.RowType) string {
+	if this.Join_keys_from > 0 {
+		return Path_key(row[this.Join_keys_from:])
+	}
+	return utils.String_or_num_to_string(row[this.Key_col])
+}
+
 func (this *Mapper) GetRowSchema() rowType.RowSchema {
 	return this.RowSchema.Unwrap()
 }
@@ -0,0 +1,216 @@
+// keeps every row it gets sorted, but only lets the rows that fall in the window (after skipping `offset` rows and then taking up to `limit` rows) through,
+// so when a row is added somewhere before the end of the window the row that got pushed out of the window is removed and when a row leaves the window the next one in line is added
+package pubsub
+
+import (
+	"slices"
+	"sort"
+	"sql-compiler/compiler/rowType"
+	"sql-compiler/unwrap"
+	"sql-compiler/utils"
+)
+
+type Sort_key struct {
+	Col_index int
+	Desc      bool
+}
+
+type OrderBy struct {
+	Observable
+	subscribed_to ObservableI
+	sort_keys     []Sort_key
+	offset        int
+	limit         unwrap.Option[int]
+	rows          []rowType.RowType //every row we got, sorted (not only the ones in the window)
+}
+
+func NewOrderBy(source ObservableI, sort_keys []Sort_key, offset int, limit unwrap.Option[int]) *OrderBy {
+	o := &OrderBy{
+		Observable: Observable{
+			Subscribers: []Subscriber{},
+		},
+		sort_keys: sort_keys,
+		offset:    offset,
+		limit:     limit,
+		rows:      []rowType.RowType{},
+	}
+	for row := range source.Pull {
+		o.insert(row)
+	}
+	Link(source, o)
+	return o
+}
+
+func (this *OrderBy) compare_rows(a rowType.RowType, b rowType.RowType) int {
+	for _, key := range this.sort_keys {
+		res := utils.CompareValues(a[key.Col_index], b[key.Col_index])
+		if key.Desc {
+			res = -res
+		}
+		if res != 0 {
+			return res
+		}
+	}
+	return 0
+}
+
+func (this *OrderBy) index_of(row rowType.RowType) int {
+	index := sort.Search(len(this.rows), func(i int) bool {
+		return this.compare_rows(this.rows[i], row) >= 0
+	})
+	for ; index < len(this.rows) && this.compare_rows(this.rows[index], row) == 0; index++ {
+		if same_row(this.rows[index], row) {
+			return index
+		}
+	}
+	return -1
+}
+
+// rows that are equal according to the sort keys stay in the order they came in, so a new row goes after all the rows that are equal to it
+func (this *OrderBy) insert(row rowType.RowType) int {
+	index := sort.Search(len(this.rows), func(i int) bool {
+		return this.compare_rows(this.rows[i], row) > 0
+	})
+	this.rows = slices.Insert(this.rows, index, row)
+	return index
+}
+
+func (this *OrderBy) delete(row rowType.RowType) int {
+	index := this.index_of(row)
+	if index == -1 {
+		panic("removing a row that was never added to the order by")
+	}
+	this.rows = append(this.rows[:index], this.rows[index+1:]...)
+	return index
+}
+
+func (this *OrderBy) window_end() int {
+	if this.limit.IsNone() {
+		return int(^uint(0) >> 1)
+	}
+	return this.offset + this.limit.Unwrap()
+}
+
+func (this *OrderBy) in_window(index int) bool {
+	return index != -1 && index >= this.offset && index < this.window_end()
+}
+
+// the range of the other rows (every row except the one that changed) that are in the window when the changed row is at changed_index (-1 if its not in the list at all)
+func (this *OrderBy) others_in_window(changed_index int, others_len int) (int, int) {
+	start, end := this.offset, this.window_end()
+	if changed_index != -1 && changed_index < start {
+		start--
+	}
+	if changed_index != -1 && changed_index < end {
+		end--
+	}
+	return min(start, others_len), min(end, others_len)
+}
+
+// publishes whatever has to change for the subscribers, given where the changed row was (old_index) and where it is now (new_index) in this.rows
+func (this *OrderBy) publish_window_change(old_row rowType.RowType, new_row rowType.RowType, old_index int, new_index int) {
+	others_len := len(this.rows)
+	if new_index != -1 {
+		others_len--
+	}
+	other := func(i int) rowType.RowType {
+		if new_index != -1 && i >= new_index {
+			return this.rows[i+1]
+		}
+		return this.rows[i]
+	}
+	old_start, old_end := this.others_in_window(old_index, others_len)
+	new_start, new_end := this.others_in_window(new_index, others_len)
+	was_in := func(i int) bool { return i >= old_start && i < old_end }
+	is_in := func(i int) bool { return i >= new_start && i < new_end }
+
+	//the window only shifts by at most one row on each side, so the rows that enter or leave it are at its edges
+	edges := []int{}
+	for i := min(old_start, new_start); i < max(old_start, new_start); i++ {
+		edges = append(edges, i)
+	}
+	for i := max(min(old_end, new_end), max(old_start, new_start)); i < max(old_end, new_end); i++ {
+		edges = append(edges, i)
+	}
+
+	//removes go out first, and then adds in the order of where they end up, so someone who's inserting at the given positions ends up with the same order
+	if this.in_window(old_index) && !this.in_window(new_index) {
+		this.Publish_remove(old_row)
+	}
+	for _, i := range edges {
+		if was_in(i) && !is_in(i) {
+			this.Publish_remove(other(i))
+		}
+	}
+	type entering struct {
+		row      rowType.RowType
+		position int
+		is_moved bool
+	}
+	entered := []entering{}
+	if this.in_window(new_index) {
+		entered = append(entered, entering{row: new_row, position: new_index, is_moved: this.in_window(old_index)})
+	}
+	for _, i := range edges {
+		if is_in(i) && !was_in(i) {
+			position := i
+			if new_index != -1 && i >= new_index {
+				position++
+			}
+			entered = append(entered, entering{row: other(i), position: position})
+		}
+	}
+	sort.Slice(entered, func(i, j int) bool { return entered[i].position < entered[j].position })
+	for _, e := range entered {
+		if e.is_moved {
+			this.Publish_Update(old_row, e.row)
+		} else {
+			this.Publish_Add(e.row)
+		}
+	}
+}
+
+// where the row is in the window (what the subscribers see), -1 if its not in the window
+func (this *OrderBy) Position_of(row rowType.RowType) int {
+	index := this.index_of(row)
+	if !this.in_window(index) {
+		return -1
+	}
+	return index - this.offset
+}
+
+func (this *OrderBy) Key_of(row rowType.RowType) string {
+	return Row_key(this.subscribed_to, row)
+}
+
+func (this *OrderBy) Set_subscribed_to(observable ObservableI) {
+	this.subscribed_to = observable
+}
+
+func (this *OrderBy) Pull(yield func(rowType.RowType) bool) {
+	for i := this.offset; i < len(this.rows) && i < this.window_end(); i++ {
+		if !yield(this.rows[i]) {
+			return
+		}
+	}
+}
+
+func (this *OrderBy) On_add(row rowType.RowType) {
+	index := this.insert(row)
+	this.publish_window_change(nil, row, -1, index)
+}
+
+func (this *OrderBy) On_remove(row rowType.RowType) {
+	index := this.delete(row)
+	this.publish_window_change(row, nil, index, -1)
+}
+
+func (this *OrderBy) On_update(old_row rowType.RowType, new_row rowType.RowType) {
+	old_index := this.delete(old_row)
+	new_index := this.insert(new_row)
+	this.publish_window_change(old_row, new_row, old_index, new_index)
+}
+
+func (this *OrderBy) GetRowSchema() rowType.RowSchema {
+	return this.subscribed_to.GetRowSchema()
+}
@@ -14,11 +14,11 @@ type Printer struct {
 	RowSchema     unwrap.Option[rowType.RowSchema]
 }
 
-func (this *Printer) set_subscribed_to(observable ObservableI) {
+func (this *Printer) Set_subscribed_to(observable ObservableI) {
 	this.subscribed_to = observable
 }
 
-func (this *Printer) on_Add(row rowType.RowType) {
+func (this *Printer) On_add(row rowType.RowType) {
 	if this.RowSchema.IsSome() {
 		fmt.Printf("Added row %s\n", RowTypeToJson(&row, this.RowSchema.Unwrap()))
 	} else {
@@ -26,7 +26,7 @@ func (this *Printer) on_Add(row rowType.RowType) {
 	}
 }
 
-func (this *Printer) on_remove(row rowType.RowType) {
+func (this *Printer) On_remove(row rowType.RowType) {
 	if this.RowSchema.IsSome() {
 		fmt.Printf("removed row %s\n", RowTypeToJson(&row, this.RowSchema.Unwrap()))
 	} else {
@@ -34,7 +34,7 @@ func (this *Printer) on_remove(row rowType.RowType) {
 	}
 }
 
-func (this *Printer) on_update(old_row rowType.RowType, new_row rowType.RowType) {
+func (this *Printer) On_update(old_row rowType.RowType, new_row rowType.RowType) {
 	if this.RowSchema.IsSome() {
 		fmt.Printf("updated row from %s to %s\n", RowTypeToJson(&old_row, this.RowSchema.Unwrap()), RowTypeToJson(&new_row, this.RowSchema.Unwrap()))
 	} else {
@@ -1,9 +1,12 @@
 package pubsub
 
 import (
+	"bytes"
+	"encoding/json"
+	"slices"
 	"sql-compiler/compiler/rowType"
 	"sql-compiler/debugutil"
-	"sql-compiler/unwrap"
+	"sql-compiler/utils"
 )
 
 type Observable struct {
@@ -14,53 +17,90 @@ func (this *Observable) Add_sub(subscriber Subscriber) {
 	this.Subscribers = append(this.Subscribers, subscriber)
 }
 
+// the subscribers are copied so an observable that is publishing keeps going through the ones it had
+func (this *Observable) Remove_sub(subscriber Subscriber) {
+	this.Subscribers = slices.DeleteFunc(slices.Clone(this.Subscribers), func(other Subscriber) bool { return other == subscriber })
+}
+
 func (this *Observable) Publish_Add(row rowType.RowType) {
 	debugutil.Print(len(this.Subscribers), "len(this.Subscribers)")
 	for _, subscriber := range this.Subscribers {
 
-		subscriber.on_Add(row)
+		subscriber.On_add(row)
 	}
 }
 
 func (this *Observable) Publish_remove(row rowType.RowType) {
 	debugutil.Print(len(this.Subscribers), "len(this.Subscribers)")
 	for _, subscriber := range this.Subscribers {
-		subscriber.on_remove(row)
+		subscriber.On_remove(row)
 	}
 }
 
 func (this *Observable) Publish_Update(old_row rowType.RowType, new_row rowType.RowType) {
 	for _, subscriber := range this.Subscribers {
-		subscriber.on_update(old_row, new_row)
+		subscriber.On_update(old_row, new_row)
 	}
 }
 
 func Link(observable ObservableI, subscriber Subscriber) {
 	observable.Add_sub(subscriber)
-	subscriber.set_subscribed_to(observable)
+	subscriber.Set_subscribed_to(observable)
 }
 
+// anything rows can be pulled from and subscribed to, an operator (built in or written in another package) embeds Observable for the subscribing and publishing
+// and implements Pull and GetRowSchema itself, operators are put after each other with Chain
 type ObservableI interface {
 	Add_sub(subscriber Subscriber) //will get from Observable
+	Remove_sub(subscriber Subscriber)
 	///
 	Pull(yield func(rowType.RowType) bool)
 	Publish_Add(row rowType.RowType)
 	Publish_remove(row rowType.RowType)
 	Publish_Update(old_row rowType.RowType, new_row rowType.RowType)
-	interface {
-		Filter_on(predicate func(rowType.RowType) bool) ObservableI
-		Map_on(transformer func(rowType.RowType) rowType.RowType) ObservableI
-		GroupBy_on(col_index int) ObservableI
-		To_display(unwrap.Option[rowType.RowSchema]) *Printer
-	}
-	interface {
-		GetRowSchema() rowType.RowSchema
+	GetRowSchema() rowType.RowSchema
+}
+
+// an observable whose rows are not identified by their first column (like an Aggregate, whose only row keeps changing) tells what key a row is stored under
+type Keyed interface {
+	Key_of(row rowType.RowType) string
+}
+
+// the key a row is stored under (in a path of the EventEmitterTree, in json, ...), the first column unless the observable says otherwise
+func Row_key(obs ObservableI, row rowType.RowType) string {
+	if keyed, ok := obs.(Keyed); ok {
+		return keyed.Key_of(row)
 	}
+	return utils.String_or_num_to_string(row[0])
 }
+
+// gets told about every row its source adds, removes or updates (after Set_subscribed_to was called with the source by Link), an operator publishes what that changes about its own rows
 type Subscriber interface {
-	set_subscribed_to(observable ObservableI)
+	Set_subscribed_to(observable ObservableI)
 	///
-	on_Add(row rowType.RowType)
-	on_remove(row rowType.RowType)
-	on_update(old_row rowType.RowType, new_row rowType.RowType)
+	On_add(row rowType.RowType)
+	On_remove(row rowType.RowType)
+	On_update(old_row rowType.RowType, new_row rowType.RowType)
+}
+
+// rows coming out of a Mapper get a fresh observable for each nested select every time they're mapped, so those cells are skipped when checking if two rows are the same row
+func same_row(a rowType.RowType, b rowType.RowType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if _, is_nested := a[i].(ObservableI); is_nested {
+			continue
+		}
+		if a_json, is_json := a[i].(json.RawMessage); is_json { //slices can't be compared with !=
+			if b_json, is_json := b[i].(json.RawMessage); !is_json || !bytes.Equal(a_json, b_json) {
+				return false
+			}
+			continue
+		}
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
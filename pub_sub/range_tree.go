@@ -0,0 +1,104 @@
+package pubsub
+
+import (
+	"math/rand/v2"
+	"sql-compiler/unwrap"
+	"sql-compiler/utils"
+)
+
+// the Range_channels of an Ordered index in a tree sorted by their low bound, where each node knows the highest high bound below it,
+// so a changed row is only taken to the channels that cover its value instead of asking every one of them (the tree is kept balanced by random priorities)
+type range_node struct {
+	channel     *Range_channel
+	priority    uint64
+	max_high    unwrap.Option[Bound] //of the channels in this part of the tree, none when one of them has no high bound
+	left, right *range_node
+}
+
+// a missing low bound comes first, on the same value an inclusive bound comes before an exclusive one (as it lets more through)
+func compare_lows(a unwrap.Option[Bound], b unwrap.Option[Bound]) int {
+	if a.IsNone() || b.IsNone() {
+		return bool_to_int(b.IsNone()) - bool_to_int(a.IsNone())
+	}
+	if res := utils.CompareValues(a.Unwrap().Value, b.Unwrap().Value); res != 0 {
+		return res
+	}
+	return bool_to_int(b.Unwrap().Inclusive) - bool_to_int(a.Unwrap().Inclusive)
+}
+
+// a missing high bound comes last, on the same value an inclusive bound comes after an exclusive one
+func compare_highs(a unwrap.Option[Bound], b unwrap.Option[Bound]) int {
+	if a.IsNone() || b.IsNone() {
+		return bool_to_int(a.IsNone()) - bool_to_int(b.IsNone())
+	}
+	if res := utils.CompareValues(a.Unwrap().Value, b.Unwrap().Value); res != 0 {
+		return res
+	}
+	return bool_to_int(a.Unwrap().Inclusive) - bool_to_int(b.Unwrap().Inclusive)
+}
+
+func bool_to_int(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func (this *range_node) update_max_high() {
+	this.max_high = this.channel.High
+	for _, child := range []*range_node{this.left, this.right} {
+		if child != nil && compare_highs(child.max_high, this.max_high) > 0 {
+			this.max_high = child.max_high
+		}
+	}
+}
+
+func insert_range(node *range_node, channel *Range_channel) *range_node {
+	if node == nil {
+		return &range_node{channel: channel, priority: rand.Uint64(), max_high: channel.High}
+	}
+	if compare_lows(channel.Low, node.channel.Low) < 0 {
+		node.left = insert_range(node.left, channel)
+		if node.left.priority > node.priority {
+			node = rotate_right(node)
+		}
+	} else {
+		node.right = insert_range(node.right, channel)
+		if node.right.priority > node.priority {
+			node = rotate_left(node)
+		}
+	}
+	node.update_max_high()
+	return node
+}
+
+func rotate_right(node *range_node) *range_node {
+	left := node.left
+	node.left, left.right = left.right, node
+	node.update_max_high()
+	left.update_max_high()
+	return left
+}
+
+func rotate_left(node *range_node) *range_node {
+	right := node.right
+	node.right, right.left = right.left, node
+	node.update_max_high()
+	right.update_max_high()
+	return right
+}
+
+// every channel that covers the value, the parts of the tree whose highest high bound is below it or whose low bounds are all above it are skipped
+func (this *range_node) covering(value any, visit func(*Range_channel)) {
+	if this == nil || !(&Range_channel{High: this.max_high}).below_high(value) {
+		return
+	}
+	this.left.covering(value, visit)
+	if !this.channel.above_low(value) {
+		return
+	}
+	if this.channel.below_high(value) {
+		visit(this.channel)
+	}
+	this.right.covering(value, visit)
+}
@@ -0,0 +1,382 @@
+// the rows of a WITH RECURSIVE query: the rows of anchor, and for each of its rows (and each row that comes from one, and so on) the rows step makes of it and each edge with the same key.
+// which row comes from which is kept as a graph, so when an anchor or an edge changes only the rows that can be reached from the rows it changes are worked out again:
+// first which of them are there (the ones an anchor or a row outside of them leads to, and what can be reached from those), and then (for UNION ALL) how many times
+package pubsub
+
+import (
+	"maps"
+	"slices"
+	"sql-compiler/compiler/rowType"
+)
+
+type Recursive_union struct {
+	Observable
+	all          bool
+	edge_key     func(edge rowType.RowType) rowType.RowType
+	row_key      func(row rowType.RowType) rowType.RowType //matched with the edge_key of the edges to step to the next rows
+	step         func(edge rowType.RowType, row rowType.RowType) (rowType.RowType, bool)
+	row_schema   rowType.RowSchema
+	anchors      map[string]int               //how many anchor rows each row has
+	edges        map[string][]rowType.RowType //by their edge_key
+	rows         map[string]rowType.RowType   //the rows that are there
+	copies       map[string]int               //how many times each row is there (at most once without ALL)
+	row_order    []string                     //so rows are pulled in the order they were first added
+	rows_by_key  map[string][]string          //the rows that are there by their row_key
+	derived_from map[string]map[string]int    //for each row, the rows it comes from and through how many edges
+	unpublished  []recursive_union_change     //what the change of a table being published did so far, by row
+}
+
+func NewRecursive_union(all bool, anchor ObservableI, edges ObservableI, edge_key func(rowType.RowType) rowType.RowType, row_key func(rowType.RowType) rowType.RowType, step func(edge rowType.RowType, row rowType.RowType) (rowType.RowType, bool), row_schema rowType.RowSchema) *Recursive_union {
+	r := &Recursive_union{
+		Observable: Observable{
+			Subscribers: []Subscriber{},
+		},
+		all:          all,
+		edge_key:     edge_key,
+		row_key:      row_key,
+		step:         step,
+		row_schema:   row_schema,
+		anchors:      map[string]int{},
+		edges:        map[string][]rowType.RowType{},
+		rows:         map[string]rowType.RowType{},
+		copies:       map[string]int{},
+		rows_by_key:  map[string][]string{},
+		derived_from: map[string]map[string]int{},
+	}
+	//the edges are indexed before the anchor rows are followed through them
+	for edge := range edges.Pull {
+		r.change_edge(edge, 1, map[string]rowType.RowType{})
+	}
+	seeds := map[string]rowType.RowType{}
+	for row := range anchor.Pull {
+		r.change_anchor(row, 1, seeds)
+	}
+	r.recompute(seeds)
+	Link(anchor, &CustomSubscriber{
+		OnAddFunc: func(row rowType.RowType) {
+			r.on_change(func(seeds map[string]rowType.RowType) { r.change_anchor(row, 1, seeds) })
+		},
+		OnRemoveFunc: func(row rowType.RowType) {
+			r.on_change(func(seeds map[string]rowType.RowType) { r.change_anchor(row, -1, seeds) })
+		},
+		OnUpdateFunc: func(old_row, new_row rowType.RowType) {
+			r.on_change(func(seeds map[string]rowType.RowType) {
+				r.change_anchor(old_row, -1, seeds)
+				r.change_anchor(new_row, 1, seeds)
+			})
+		},
+	})
+	Link(edges, &CustomSubscriber{
+		OnAddFunc: func(edge rowType.RowType) {
+			r.on_change(func(seeds map[string]rowType.RowType) { r.change_edge(edge, 1, seeds) })
+		},
+		OnRemoveFunc: func(edge rowType.RowType) {
+			r.on_change(func(seeds map[string]rowType.RowType) { r.change_edge(edge, -1, seeds) })
+		},
+		OnUpdateFunc: func(old_edge, new_edge rowType.RowType) {
+			r.on_change(func(seeds map[string]rowType.RowType) {
+				r.change_edge(old_edge, -1, seeds)
+				r.change_edge(new_edge, 1, seeds)
+			})
+		},
+	})
+	return r
+}
+
+// an int in a float column (from the anchor, whose column was an int) is the same row as the float
+func (this *Recursive_union) key(row rowType.RowType) string {
+	key_values := slices.Clone(row)
+	for i := range key_values {
+		if n, is_int := key_values[i].(int); is_int && this.row_schema[i].Type == rowType.Float {
+			key_values[i] = float64(n)
+		}
+	}
+	return Encode_key(key_values)
+}
+
+// the rows that come from the row through each of the edges with its key
+func (this *Recursive_union) next_rows(row rowType.RowType) []rowType.RowType {
+	row_key := this.row_key(row)
+	if slices.Contains(row_key, nil) {
+		return nil
+	}
+	res := []rowType.RowType{}
+	for _, edge := range this.edges[equality_key(row_key)] {
+		if next, ok := this.step(edge, row); ok {
+			res = append(res, next)
+		}
+	}
+	return res
+}
+
+func (this *Recursive_union) change_anchor(row rowType.RowType, amount int, seeds map[string]rowType.RowType) {
+	key := this.key(row)
+	this.anchors[key] += amount
+	if this.anchors[key] < 0 {
+		panic("removing a row that was never added to the recursive union")
+	}
+	if this.anchors[key] == 0 {
+		delete(this.anchors, key)
+	}
+	seeds[key] = row
+}
+
+// the rows the edge leads to from the rows that are there are the ones that can change
+func (this *Recursive_union) change_edge(edge rowType.RowType, amount int, seeds map[string]rowType.RowType) {
+	edge_key := equality_key(this.edge_key(edge))
+	if amount > 0 {
+		this.edges[edge_key] = append(this.edges[edge_key], edge)
+	} else {
+		edges := this.edges[edge_key]
+		index := slices.IndexFunc(edges, func(other rowType.RowType) bool { return same_row(other, edge) })
+		if index == -1 {
+			panic("removing an edge that was never added to the recursive union")
+		}
+		this.edges[edge_key] = slices.Delete(edges, index, index+1)
+		if len(this.edges[edge_key]) == 0 {
+			delete(this.edges, edge_key)
+		}
+	}
+	for _, from := range this.rows_by_key[edge_key] {
+		next, ok := this.step(edge, this.rows[from])
+		if !ok {
+			continue
+		}
+		next_key := this.key(next)
+		this.add_derivation(next_key, from, amount)
+		seeds[next_key] = next
+	}
+}
+
+func (this *Recursive_union) add_derivation(key string, from string, amount int) {
+	if this.derived_from[key] == nil {
+		this.derived_from[key] = map[string]int{}
+	}
+	this.derived_from[key][from] += amount
+	if this.derived_from[key][from] == 0 {
+		delete(this.derived_from[key], from)
+	}
+	if len(this.derived_from[key]) == 0 {
+		delete(this.derived_from, key)
+	}
+}
+
+// the anchor and the edges can be the rows of the same table, so a row that changes can change both (like a reply that becomes a root),
+// what that does to the rows is only published once the change reached both, so a row with the same key that goes away and shows up is an update
+func (this *Recursive_union) on_change(change func(seeds map[string]rowType.RowType)) {
+	seeds := map[string]rowType.RowType{}
+	change(seeds)
+	scheduled := len(this.unpublished) > 0
+	for _, change := range this.recompute(seeds) {
+		index := slices.IndexFunc(this.unpublished, func(other recursive_union_change) bool { return other.key == change.key })
+		if index == -1 {
+			this.unpublished = append(this.unpublished, change)
+			continue
+		}
+		this.unpublished[index].new_copies = change.new_copies
+	}
+	if !scheduled && len(this.unpublished) > 0 {
+		After_table_change(this.publish_unpublished)
+	}
+}
+
+func (this *Recursive_union) publish_unpublished() {
+	changes := slices.DeleteFunc(this.unpublished, func(change recursive_union_change) bool { return change.old_copies == change.new_copies })
+	this.unpublished = nil
+	this.publish(changes)
+}
+
+type recursive_union_change struct {
+	key                    string
+	row                    rowType.RowType
+	old_copies, new_copies int
+}
+
+// works out the rows that can be reached from the seeds again, and returns how many copies of each of them there were and are
+func (this *Recursive_union) recompute(seeds map[string]rowType.RowType) []recursive_union_change {
+	region := map[string]rowType.RowType{}
+	region_order := []string{}
+	queue := []rowType.RowType{}
+	for _, key := range slices.Sorted(maps.Keys(seeds)) {
+		queue = append(queue, seeds[key])
+	}
+	for len(queue) > 0 {
+		row := queue[0]
+		queue = queue[1:]
+		key := this.key(row)
+		if _, seen := region[key]; seen {
+			continue
+		}
+		region[key] = row
+		region_order = append(region_order, key)
+		queue = append(queue, this.next_rows(row)...)
+	}
+
+	//what the rows in the region come from is worked out again, what they come from outside of it stays
+	for _, key := range region_order {
+		for from := range this.derived_from[key] {
+			if _, in_region := region[from]; in_region {
+				delete(this.derived_from[key], from)
+			}
+		}
+		if len(this.derived_from[key]) == 0 {
+			delete(this.derived_from, key)
+		}
+	}
+	there := map[string]bool{}
+	queue_keys := []string{}
+	for _, key := range region_order {
+		if this.anchors[key] > 0 || len(this.derived_from[key]) > 0 {
+			queue_keys = append(queue_keys, key)
+		}
+	}
+	for len(queue_keys) > 0 {
+		key := queue_keys[0]
+		queue_keys = queue_keys[1:]
+		if there[key] {
+			continue
+		}
+		there[key] = true
+		for _, next := range this.next_rows(region[key]) {
+			next_key := this.key(next)
+			this.add_derivation(next_key, key, 1)
+			queue_keys = append(queue_keys, next_key)
+		}
+	}
+
+	new_copies := map[string]int{}
+	if this.all {
+		new_copies = this.count_derivations(region_order, there)
+	} else {
+		for key := range there {
+			new_copies[key] = 1
+		}
+	}
+	changes := []recursive_union_change{}
+	for _, key := range region_order {
+		old, new := this.copies[key], new_copies[key]
+		if old == new {
+			continue
+		}
+		changes = append(changes, recursive_union_change{key: key, row: region[key], old_copies: old, new_copies: new})
+		this.set_copies(key, region[key], new)
+	}
+	return changes
+}
+
+// with ALL a row is there once for each way of getting to it, so each row is there as many times as it has anchors plus the times each row it comes from is there (for each edge it comes from it through)
+func (this *Recursive_union) count_derivations(region_order []string, there map[string]bool) map[string]int {
+	counts := map[string]int{}
+	waiting_on := map[string]int{} //how many of the rows it comes from in the region aren't counted yet
+	next := map[string][]string{}
+	for _, key := range region_order {
+		if !there[key] {
+			continue
+		}
+		counts[key] = this.anchors[key]
+		for from, edges := range this.derived_from[key] {
+			if there[from] {
+				waiting_on[key]++
+				next[from] = append(next[from], key)
+			} else {
+				counts[key] += edges * this.copies[from]
+			}
+		}
+	}
+	ready := []string{}
+	for _, key := range region_order {
+		if there[key] && waiting_on[key] == 0 {
+			ready = append(ready, key)
+		}
+	}
+	counted := 0
+	for len(ready) > 0 {
+		key := ready[0]
+		ready = ready[1:]
+		counted++
+		for _, next_key := range next[key] {
+			counts[next_key] += this.derived_from[next_key][key] * counts[key]
+			waiting_on[next_key]--
+			if waiting_on[next_key] == 0 {
+				ready = append(ready, next_key)
+			}
+		}
+	}
+	if counted != len(there) {
+		panic("the rows of a WITH RECURSIVE with UNION ALL lead back to themselves, so there would be endlessly many of them (use UNION instead)")
+	}
+	return counts
+}
+
+func (this *Recursive_union) set_copies(key string, row rowType.RowType, copies int) {
+	_, was_there := this.rows[key]
+	if copies == 0 {
+		delete(this.rows, key)
+		delete(this.copies, key)
+		this.row_order = slices.DeleteFunc(this.row_order, func(k string) bool { return k == key })
+		if row_key := this.row_key(row); !slices.Contains(row_key, nil) {
+			by_key := equality_key(row_key)
+			this.rows_by_key[by_key] = slices.DeleteFunc(this.rows_by_key[by_key], func(k string) bool { return k == key })
+			if len(this.rows_by_key[by_key]) == 0 {
+				delete(this.rows_by_key, by_key)
+			}
+		}
+		return
+	}
+	this.copies[key] = copies
+	if was_there {
+		return
+	}
+	this.rows[key] = row
+	this.row_order = append(this.row_order, key)
+	if row_key := this.row_key(row); !slices.Contains(row_key, nil) {
+		by_key := equality_key(row_key)
+		this.rows_by_key[by_key] = append(this.rows_by_key[by_key], key)
+	}
+}
+
+// a row that goes away and one with the same key that shows up (like when an edge is moved, and what the rows it leads to hold changes) are published as an update
+func (this *Recursive_union) publish(changes []recursive_union_change) {
+	removed := map[string][]rowType.RowType{}
+	for _, change := range changes {
+		for i := change.new_copies; i < change.old_copies; i++ {
+			path_key := Row_key(this, change.row)
+			removed[path_key] = append(removed[path_key], change.row)
+		}
+	}
+	added := []rowType.RowType{}
+	for _, change := range changes {
+		for i := change.old_copies; i < change.new_copies; i++ {
+			path_key := Row_key(this, change.row)
+			if len(removed[path_key]) > 0 {
+				this.Publish_Update(removed[path_key][0], change.row)
+				removed[path_key] = removed[path_key][1:]
+				continue
+			}
+			added = append(added, change.row)
+		}
+	}
+	for _, change := range changes {
+		for _, row := range removed[Row_key(this, change.row)] {
+			this.Publish_remove(row)
+		}
+		delete(removed, Row_key(this, change.row))
+	}
+	for _, row := range added {
+		this.Publish_Add(row)
+	}
+}
+
+func (this *Recursive_union) Pull(yield func(rowType.RowType) bool) {
+	for _, key := range this.row_order {
+		for range this.copies[key] {
+			if !yield(this.rows[key]) {
+				return
+			}
+		}
+	}
+}
+
+func (this *Recursive_union) GetRowSchema() rowType.RowSchema {
+	return this.row_schema
+}
@@ -6,25 +6,19 @@ import (
 	"sql-compiler/assert"
 	. "sql-compiler/compiler/rowType"
 	"sql-compiler/utils"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// the values a row has after the columns of the row schema (like the keys of a join) aren't part of what it holds, so they aren't written
 func RowTypeToJson(row *RowType, row_schema RowSchema) string {
 	res := "{"
-	for i, col := range *row {
+	values := (*row)[:min(len(*row), len(row_schema))]
+	for i, col := range values {
 		res += "\"" + row_schema[i].Name + "\":"
-		switch row_schema[i].Type {
-		case String:
-			res += fmt.Sprintf("\"%s\"", col.(string))
-		case Int:
-			res += fmt.Sprintf("%d", col.(int))
-		case Bool:
-			res += fmt.Sprintf("%t", col.(bool))
-		default:
-			childs_row_schema := NestedSelectsRowSchema[row_schema[i].Type]
-			res += ObserverToJson(col.(ObservableI), childs_row_schema)
-		}
-		if i != len(*row)-1 {
+		res += ValueToJson(col, row_schema[i].Type)
+		if i != len(values)-1 {
 			res += ","
 		}
 	}
@@ -32,11 +26,42 @@ func RowTypeToJson(row *RowType, row_schema RowSchema) string {
 	return res
 }
 
+func ValueToJson(value any, type_ DataType) string {
+	if scalar, ok := value.(*Aggregate); ok && !type_.Is_nested_select() { //a scalar subquery, whose column holds the aggregate's value and not its rows
+		value = scalar.Scalar_value()
+	}
+	if value == nil {
+		return "null"
+	}
+	switch type_ {
+	case String:
+		return fmt.Sprintf("\"%s\"", value.(string))
+	case Int:
+		return fmt.Sprintf("%d", value.(int))
+	case Float:
+		switch value := value.(type) {
+		case int:
+			return fmt.Sprintf("%d", value)
+		default:
+			return strconv.FormatFloat(value.(float64), 'f', -1, 64)
+		}
+	case Bool:
+		return fmt.Sprintf("%t", value.(bool))
+	case Timestamp:
+		return "\"" + utils.String_or_num_to_string(value.(time.Time)) + "\""
+	case Json:
+		return string(value.(json.RawMessage))
+	default:
+		childs_row_schema := NestedSelectsRowSchema[type_]
+		return ObserverToJson(value.(ObservableI), childs_row_schema)
+	}
+}
+
 func ObserverToJson(col ObservableI, row_schema RowSchema) string {
 	res := "{"
 	has_at_least_one := false
 	for row := range col.Pull {
-		primary_key := utils.String_or_num_to_string(row[0])
+		primary_key := Row_key(col, row)
 		res += "\"" + primary_key + "\":"
 		res += RowTypeToJson(&row, row_schema) + ","
 		has_at_least_one = true
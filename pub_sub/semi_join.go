@@ -0,0 +1,202 @@
+// lets a left row through when the right side has a row with the same key (a semi join, for IN (SELECT ...) and EXISTS) or when it has none (an anti join, for NOT IN and NOT EXISTS).
+// the right rows are only counted per key, and the left rows are kept by their key so that when the count of a key changes the left rows whose result that changes can be added or removed.
+// for IN the first value of the key is the value being looked for and the rest of it is the correlation (the values of the outer columns the subquery was correlated on, if any),
+// a null being looked for or a null among the looked in values makes IN unknown (and so is NOT IN), unless there is nothing to look in
+package pubsub
+
+import (
+	"slices"
+	"sql-compiler/compiler/rowType"
+)
+
+type Semi_join struct {
+	Observable
+	left        ObservableI
+	anti        bool
+	is_in       bool
+	left_key    func(rowType.RowType) rowType.RowType
+	right_key   func(rowType.RowType) rowType.RowType
+	matches     map[string]int               //how many right rows have each key
+	group_rows  map[string]int               //for IN, how many right rows have each correlation
+	group_nulls map[string]int               //for IN, how many right rows with each correlation have a null as their value
+	left_rows   map[string][]rowType.RowType //the left rows by their correlation (the ones with a null in it never pass, and can't start to)
+}
+
+func NewSemi_join(anti bool, is_in bool, left ObservableI, right ObservableI, left_key func(rowType.RowType) rowType.RowType, right_key func(rowType.RowType) rowType.RowType) *Semi_join {
+	s := &Semi_join{
+		Observable: Observable{
+			Subscribers: []Subscriber{},
+		},
+		left:        left,
+		anti:        anti,
+		is_in:       is_in,
+		left_key:    left_key,
+		right_key:   right_key,
+		matches:     map[string]int{},
+		group_rows:  map[string]int{},
+		group_nulls: map[string]int{},
+		left_rows:   map[string][]rowType.RowType{},
+	}
+	//the right side is counted first so the left rows are let through or not straight away
+	for row := range right.Pull {
+		s.count_right(row, 1)
+	}
+	for row := range left.Pull {
+		s.insert_left(row)
+	}
+	Link(left, &CustomSubscriber{
+		OnAddFunc:    s.on_left_add,
+		OnRemoveFunc: s.on_left_remove,
+		OnUpdateFunc: s.on_left_update,
+	})
+	Link(right, &CustomSubscriber{
+		OnAddFunc:    func(row rowType.RowType) { s.on_right_change(row, 1) },
+		OnRemoveFunc: func(row rowType.RowType) { s.on_right_change(row, -1) },
+		OnUpdateFunc: func(old_row, new_row rowType.RowType) {
+			s.on_right_change(old_row, -1)
+			s.on_right_change(new_row, 1)
+		},
+	})
+	return s
+}
+
+func (this *Semi_join) correlation(key rowType.RowType) rowType.RowType {
+	if this.is_in {
+		return key[1:]
+	}
+	return key
+}
+
+func (this *Semi_join) passes(key rowType.RowType) bool {
+	correlation := this.correlation(key)
+	if slices.Contains(correlation, nil) {
+		//nothing is equal to null, so the subquery has no rows for it
+		return this.anti
+	}
+	if !this.is_in {
+		return (this.matches[equality_key(key)] > 0) != this.anti
+	}
+	group_key := equality_key(correlation)
+	switch {
+	case this.group_rows[group_key] == 0:
+		return this.anti
+	case key[0] == nil:
+		return false
+	case this.matches[equality_key(key)] > 0:
+		return !this.anti
+	case this.group_nulls[group_key] > 0:
+		return false
+	default:
+		return this.anti
+	}
+}
+
+func (this *Semi_join) count_right(row rowType.RowType, amount int) {
+	key := this.right_key(row)
+	correlation := this.correlation(key)
+	if slices.Contains(correlation, nil) {
+		return
+	}
+	if this.is_in {
+		this.group_rows[equality_key(correlation)] += amount
+		if key[0] == nil {
+			this.group_nulls[equality_key(correlation)] += amount
+			return
+		}
+	}
+	this.matches[equality_key(key)] += amount
+}
+
+func (this *Semi_join) insert_left(row rowType.RowType) {
+	correlation := this.correlation(this.left_key(row))
+	if slices.Contains(correlation, nil) {
+		return
+	}
+	group_key := equality_key(correlation)
+	this.left_rows[group_key] = append(this.left_rows[group_key], row)
+}
+
+func (this *Semi_join) delete_left(row rowType.RowType) {
+	correlation := this.correlation(this.left_key(row))
+	if slices.Contains(correlation, nil) {
+		return
+	}
+	group_key := equality_key(correlation)
+	rows := this.left_rows[group_key]
+	index := slices.IndexFunc(rows, func(other rowType.RowType) bool { return same_row(other, row) })
+	if index == -1 {
+		panic("removing a row that was never added to the semi join")
+	}
+	this.left_rows[group_key] = slices.Delete(rows, index, index+1)
+	if len(this.left_rows[group_key]) == 0 {
+		delete(this.left_rows, group_key)
+	}
+}
+
+// only the left rows with the same correlation as the right row can be affected by it
+func (this *Semi_join) on_right_change(row rowType.RowType, amount int) {
+	correlation := this.correlation(this.right_key(row))
+	if slices.Contains(correlation, nil) {
+		return
+	}
+	affected := this.left_rows[equality_key(correlation)]
+	passed := make([]bool, len(affected))
+	for i, left_row := range affected {
+		passed[i] = this.passes(this.left_key(left_row))
+	}
+	this.count_right(row, amount)
+	for i, left_row := range affected {
+		switch passes := this.passes(this.left_key(left_row)); {
+		case passes && !passed[i]:
+			this.Publish_Add(left_row)
+		case !passes && passed[i]:
+			this.Publish_remove(left_row)
+		}
+	}
+}
+
+func (this *Semi_join) on_left_add(row rowType.RowType) {
+	this.insert_left(row)
+	if this.passes(this.left_key(row)) {
+		this.Publish_Add(row)
+	}
+}
+
+func (this *Semi_join) on_left_remove(row rowType.RowType) {
+	this.delete_left(row)
+	if this.passes(this.left_key(row)) {
+		this.Publish_remove(row)
+	}
+}
+
+func (this *Semi_join) on_left_update(old_row rowType.RowType, new_row rowType.RowType) {
+	this.delete_left(old_row)
+	this.insert_left(new_row)
+	old_passed := this.passes(this.left_key(old_row))
+	new_passed := this.passes(this.left_key(new_row))
+	if old_passed && !new_passed {
+		this.Publish_remove(old_row)
+	} else if !old_passed && new_passed {
+		this.Publish_Add(new_row)
+	} else if old_passed && new_passed {
+		this.Publish_Update(old_row, new_row)
+	}
+}
+
+func (this *Semi_join) Key_of(row rowType.RowType) string {
+	return Row_key(this.left, row)
+}
+
+func (this *Semi_join) Pull(yield func(rowType.RowType) bool) {
+	for row := range this.left.Pull {
+		if this.passes(this.left_key(row)) {
+			if !yield(row) {
+				return
+			}
+		}
+	}
+}
+
+func (this *Semi_join) GetRowSchema() rowType.RowSchema {
+	return this.left.GetRowSchema()
+}
@@ -0,0 +1,198 @@
+// combines the rows of two observables (UNION, INTERSECT, EXCEPT, with or without ALL), rows are the same when all their values are.
+// how many times each row was added by each side is counted, and how many copies of it are shown follows from those two counts,
+// so whenever one of them changes the difference to what was shown before is published
+package pubsub
+
+import (
+	"slices"
+	"sql-compiler/compiler/rowType"
+)
+
+type Set_operation_kind string
+
+const (
+	Union     Set_operation_kind = "UNION"
+	Intersect Set_operation_kind = "INTERSECT"
+	Except    Set_operation_kind = "EXCEPT"
+)
+
+// how many copies of a row are shown when the left side has it left times and the right side right times
+var set_operation_copies = map[Set_operation_kind]func(left int, right int, all bool) int{
+	Union: func(left int, right int, all bool) int {
+		if all {
+			return left + right
+		}
+		return min(left+right, 1)
+	},
+	Intersect: func(left int, right int, all bool) int {
+		if all {
+			return min(left, right)
+		}
+		return min(left, right, 1)
+	},
+	Except: func(left int, right int, all bool) int {
+		if all {
+			return max(left-right, 0)
+		}
+		if right > 0 {
+			return 0
+		}
+		return min(left, 1)
+	},
+}
+
+type set_operation_entry struct {
+	row         rowType.RowType //the row as it was first added (by either side)
+	path_key    string          //what the row is stored under, from the side that added it
+	left, right int
+}
+
+type Set_operation struct {
+	Observable
+	left, right ObservableI
+	copies      func(left int, right int) int
+	row_schema  rowType.RowSchema
+	entries     map[string]*set_operation_entry
+	entry_order []string //so rows are pulled in the order they were first added
+}
+
+func NewSet_operation(kind Set_operation_kind, all bool, left ObservableI, right ObservableI, row_schema rowType.RowSchema) *Set_operation {
+	copies, ok := set_operation_copies[kind]
+	if !ok {
+		panic("unknown set operation " + string(kind))
+	}
+	s := &Set_operation{
+		Observable: Observable{
+			Subscribers: []Subscriber{},
+		},
+		left:       left,
+		right:      right,
+		copies:     func(left int, right int) int { return copies(left, right, all) },
+		row_schema: row_schema,
+		entries:    map[string]*set_operation_entry{},
+	}
+	//how many times each row is already on either side
+	for row := range left.Pull {
+		s.adjust(row, true, 1)
+	}
+	for row := range right.Pull {
+		s.adjust(row, false, 1)
+	}
+	Link(left, &CustomSubscriber{
+		OnAddFunc:    func(row rowType.RowType) { s.on_side_add(row, true) },
+		OnRemoveFunc: func(row rowType.RowType) { s.on_side_remove(row, true) },
+		OnUpdateFunc: func(old_row, new_row rowType.RowType) { s.on_side_update(old_row, new_row, true) },
+	})
+	Link(right, &CustomSubscriber{
+		OnAddFunc:    func(row rowType.RowType) { s.on_side_add(row, false) },
+		OnRemoveFunc: func(row rowType.RowType) { s.on_side_remove(row, false) },
+		OnUpdateFunc: func(old_row, new_row rowType.RowType) { s.on_side_update(old_row, new_row, false) },
+	})
+	return s
+}
+
+// an int in a float column (from a select whose column was an int) is the same as the float
+func (this *Set_operation) key(row rowType.RowType) string {
+	key_values := slices.Clone(row)
+	for i := range key_values {
+		if n, is_int := key_values[i].(int); is_int && this.row_schema[i].Type == rowType.Float {
+			key_values[i] = float64(n)
+		}
+	}
+	return Encode_key(key_values)
+}
+
+// changes how many times the side has the row by amount and returns the entry with how many copies were shown before and are shown now
+func (this *Set_operation) adjust(row rowType.RowType, is_left bool, amount int) (*set_operation_entry, int, int) {
+	key := this.key(row)
+	entry, ok := this.entries[key]
+	if !ok {
+		source := this.right
+		if is_left {
+			source = this.left
+		}
+		entry = &set_operation_entry{row: row, path_key: Row_key(source, row)}
+		this.entries[key] = entry
+		this.entry_order = append(this.entry_order, key)
+	}
+	old_copies := this.copies(entry.left, entry.right)
+	if is_left {
+		entry.left += amount
+	} else {
+		entry.right += amount
+	}
+	if entry.left < 0 || entry.right < 0 {
+		panic("removing a row that was never added to the set operation")
+	}
+	return entry, old_copies, this.copies(entry.left, entry.right)
+}
+
+// the entry is only forgotten after its last copy was published as removed, so its key can still be looked up by the subscribers
+func (this *Set_operation) forget_if_unused(entry *set_operation_entry) {
+	if entry.left != 0 || entry.right != 0 {
+		return
+	}
+	key := this.key(entry.row)
+	delete(this.entries, key)
+	this.entry_order = slices.DeleteFunc(this.entry_order, func(k string) bool { return k == key })
+}
+
+func (this *Set_operation) publish_copies(entry *set_operation_entry, old_copies int, new_copies int) {
+	for i := old_copies; i < new_copies; i++ {
+		this.Publish_Add(entry.row)
+	}
+	for i := new_copies; i < old_copies; i++ {
+		this.Publish_remove(entry.row)
+	}
+}
+
+func (this *Set_operation) on_side_add(row rowType.RowType, is_left bool) {
+	entry, old_copies, new_copies := this.adjust(row, is_left, 1)
+	this.publish_copies(entry, old_copies, new_copies)
+}
+
+func (this *Set_operation) on_side_remove(row rowType.RowType, is_left bool) {
+	entry, old_copies, new_copies := this.adjust(row, is_left, -1)
+	this.publish_copies(entry, old_copies, new_copies)
+	this.forget_if_unused(entry)
+}
+
+// when a copy of the old row goes away and a copy of the new row shows up it is published as an update of one into the other
+func (this *Set_operation) on_side_update(old_row rowType.RowType, new_row rowType.RowType, is_left bool) {
+	if same_row(old_row, new_row) {
+		return
+	}
+	old_entry, old_copies_before, old_copies_after := this.adjust(old_row, is_left, -1)
+	new_entry, new_copies_before, new_copies_after := this.adjust(new_row, is_left, 1)
+	if old_copies_after < old_copies_before && new_copies_after > new_copies_before {
+		this.Publish_Update(old_entry.row, new_entry.row)
+		old_copies_before--
+		new_copies_before++
+	}
+	this.publish_copies(old_entry, old_copies_before, old_copies_after)
+	this.publish_copies(new_entry, new_copies_before, new_copies_after)
+	this.forget_if_unused(old_entry)
+}
+
+func (this *Set_operation) Key_of(row rowType.RowType) string {
+	entry, ok := this.entries[this.key(row)]
+	if !ok {
+		panic("the row was not published by this set operation")
+	}
+	return entry.path_key
+}
+
+func (this *Set_operation) Pull(yield func(rowType.RowType) bool) {
+	for _, key := range this.entry_order {
+		entry := this.entries[key]
+		for range this.copies(entry.left, entry.right) {
+			if !yield(entry.row) {
+				return
+			}
+		}
+	}
+}
+
+func (this *Set_operation) GetRowSchema() rowType.RowSchema {
+	return this.row_schema
+}
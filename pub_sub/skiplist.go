@@ -0,0 +1,102 @@
+package pubsub
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"sql-compiler/utils"
+)
+
+const skiplist_levels = 32
+
+// the entries of an Ordered index sorted by value (rows with the same value in the order of the table),
+// each level skips over about twice as many entries as the one below it, so adding, removing and finding where a range starts take O(log n)
+type skiplist struct {
+	head   skip_node
+	length int
+}
+
+type skip_node struct {
+	entry ordered_entry
+	next  []*skip_node
+	width []int //how many entries next[level] is ahead of this node, so a position is known without walking the entries before it
+}
+
+func new_skiplist() *skiplist {
+	return &skiplist{head: skip_node{next: make([]*skip_node, skiplist_levels), width: make([]int, skiplist_levels)}}
+}
+
+func compare_entries(a ordered_entry, b ordered_entry) int {
+	if res := utils.CompareValues(a.value, b.value); res != 0 {
+		return res
+	}
+	return cmp.Compare(a.row_index, b.row_index)
+}
+
+// the last node of each level for which before is true and how many entries come up to and including it, before has to be true for a first part of the entries only
+func (this *skiplist) seek(before func(ordered_entry) bool) ([]*skip_node, []int) {
+	nodes, positions := make([]*skip_node, skiplist_levels), make([]int, skiplist_levels)
+	node, position := &this.head, 0
+	for level := skiplist_levels - 1; level >= 0; level-- {
+		for node.next[level] != nil && before(node.next[level].entry) {
+			position += node.width[level]
+			node = node.next[level]
+		}
+		nodes[level], positions[level] = node, position
+	}
+	return nodes, positions
+}
+
+// how many entries before is true for
+func (this *skiplist) count_before(before func(ordered_entry) bool) int {
+	_, positions := this.seek(before)
+	return positions[0]
+}
+
+// the entries starting with the first one before is false for
+func (this *skiplist) from(before func(ordered_entry) bool) func(yield func(ordered_entry) bool) {
+	return func(yield func(ordered_entry) bool) {
+		nodes, _ := this.seek(before)
+		for node := nodes[0].next[0]; node != nil; node = node.next[0] {
+			if !yield(node.entry) {
+				return
+			}
+		}
+	}
+}
+
+func (this *skiplist) insert(entry ordered_entry) {
+	nodes, positions := this.seek(func(other ordered_entry) bool { return compare_entries(other, entry) < 0 })
+	levels := 1
+	for levels < skiplist_levels && rand.IntN(2) == 0 {
+		levels++
+	}
+	node := &skip_node{entry: entry, next: make([]*skip_node, levels), width: make([]int, levels)}
+	for level := range skiplist_levels {
+		if level >= levels {
+			nodes[level].width[level]++
+			continue
+		}
+		skipped := positions[0] - positions[level]
+		node.next[level], nodes[level].next[level] = nodes[level].next[level], node
+		node.width[level] = nodes[level].width[level] - skipped
+		nodes[level].width[level] = skipped + 1
+	}
+	this.length++
+}
+
+func (this *skiplist) remove(entry ordered_entry) {
+	nodes, _ := this.seek(func(other ordered_entry) bool { return compare_entries(other, entry) < 0 })
+	node := nodes[0].next[0]
+	if node == nil || compare_entries(node.entry, entry) != 0 {
+		return
+	}
+	for level := range skiplist_levels {
+		if level < len(node.next) {
+			nodes[level].width[level] += node.width[level] - 1
+			nodes[level].next[level] = node.next[level]
+		} else {
+			nodes[level].width[level]--
+		}
+	}
+	this.length--
+}
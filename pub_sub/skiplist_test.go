@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sql-compiler/unwrap"
+	"testing"
+)
+
+func TestSkiplistStaysSorted(t *testing.T) {
+	list := new_skiplist()
+	expected := []ordered_entry{}
+	random := rand.New(rand.NewPCG(1, 2))
+	for range 2000 {
+		entry := ordered_entry{value: random.IntN(50), row_index: random.IntN(40)}
+		at, found := slices.BinarySearchFunc(expected, entry, compare_entries)
+		if found {
+			list.remove(entry)
+			expected = slices.Delete(expected, at, at+1)
+		} else {
+			list.insert(entry)
+			expected = slices.Insert(expected, at, entry)
+		}
+	}
+	actual := slices.Collect(list.from(func(ordered_entry) bool { return false }))
+	if !slices.Equal(expected, actual) || list.length != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, actual)
+	}
+	//the positions come from the widths, so they are checked against counting
+	for value := range 52 {
+		below := list.count_before(func(entry ordered_entry) bool { return entry.value.(int) < value })
+		counted := 0
+		for _, entry := range expected {
+			if entry.value.(int) < value {
+				counted++
+			}
+		}
+		if below != counted {
+			t.Fatalf("%d entries are below %d but got %d", counted, value, below)
+		}
+	}
+}
+
+func TestRangeTreeFindsCoveringChannels(t *testing.T) {
+	random := rand.New(rand.NewPCG(3, 4))
+	bound := func() unwrap.Option[Bound] {
+		if random.IntN(5) == 0 {
+			return unwrap.None[Bound]()
+		}
+		return unwrap.Some(Bound{Value: random.IntN(100), Inclusive: random.IntN(2) == 0})
+	}
+	var tree *range_node
+	channels := []*Range_channel{}
+	for range 300 {
+		channel := &Range_channel{Low: bound(), High: bound()}
+		channels = append(channels, channel)
+		tree = insert_range(tree, channel)
+	}
+	for value := -1; value <= 100; value++ {
+		found := map[*Range_channel]bool{}
+		tree.covering(value, func(channel *Range_channel) {
+			if found[channel] {
+				t.Fatalf("a channel covering %d was found twice", value)
+			}
+			found[channel] = true
+		})
+		for _, channel := range channels {
+			if channel.Covers(value) != found[channel] {
+				t.Fatalf("the channel from %v to %v covers %d: %v, but the tree says %v", channel.Low, channel.High, value, channel.Covers(value), found[channel])
+			}
+		}
+	}
+}
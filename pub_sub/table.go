@@ -2,17 +2,22 @@ package pubsub
 
 import (
 	"fmt"
+	"slices"
 	"sql-compiler/compiler/rowType"
 	"sql-compiler/debugutil"
+	"sql-compiler/unwrap"
 	"sql-compiler/utils"
+	"strings"
 )
 
 type R_Table struct {
 	Observable
 	Rows       []rowType.RowType
 	is_deleted []bool //use index to find out if the row at that index is deleted
+	row_count  int    //the rows that are not deleted
 	Indexes    []Index
 	rowSchema  []rowType.ColInfo
+	Key_col    int //the column the rows are keyed by, the primary key of the table (the first column when it has none)
 }
 
 func New_R_Table(row_schema rowType.RowSchema) R_Table {
@@ -27,6 +32,33 @@ func New_R_Table(row_schema rowType.RowSchema) R_Table {
 	}
 }
 
+// how many changes of tables are being published (publishing one can change another table), and what was put off until the outermost one reached every subscriber
+var table_changes_publishing int
+var after_table_changes []func()
+
+// calls publish once the change of a table that is being published reached every subscriber (right away when no table is publishing),
+// for an operator that gets the same change of a table through more than one of its sources and publishes what the change as a whole does
+func After_table_change(publish func()) {
+	if table_changes_publishing == 0 {
+		publish()
+		return
+	}
+	after_table_changes = append(after_table_changes, publish)
+}
+
+func publish_table_change(publish func()) {
+	table_changes_publishing++
+	defer func() {
+		table_changes_publishing--
+		for table_changes_publishing == 0 && len(after_table_changes) > 0 {
+			next := after_table_changes[0]
+			after_table_changes = after_table_changes[1:]
+			next()
+		}
+	}()
+	publish()
+}
+
 func (this *R_Table) Pull(yield func(rowType.RowType) bool) {
 	for i, row := range this.Rows {
 		if !this.is_deleted[i] {
@@ -37,20 +69,25 @@ func (this *R_Table) Pull(yield func(rowType.RowType) bool) {
 	}
 }
 
-func (this *R_Table) Add(row rowType.RowType) {
+// returns a *Unique_violation (and leaves the table as it was) when the row has the values of another row in the columns of a unique index
+func (this *R_Table) Add(row rowType.RowType) error {
+	if err := this.check_unique(row, -1); err != nil {
+		return err
+	}
 	this.Rows = append(this.Rows, row)
 	this.is_deleted = append(this.is_deleted, false)
-	///
-	for i := range this.Indexes {
-		channel_value := utils.String_or_num_to_string(row[this.Indexes[i].Col_indexing_on])
-		if _, ok := this.Indexes[i].Channels[channel_value]; !ok {
-			this.Indexes[i].Channels[channel_value] = NewChannel(this)
+	this.row_count++
+	publish_table_change(func() {
+		for i := range this.Indexes {
+			for prefix_len, channel := range this.Indexes[i].channels_of(row) {
+				this.Indexes[i].add_to_channel(prefix_len, channel, len(this.Rows)-1)
+				channel.Publish_Add(row)
+			}
+			this.Indexes[i].add_ordered(len(this.Rows)-1, row)
 		}
-		this.Indexes[i].Channels[channel_value].row_indexes = append(this.Indexes[i].Channels[channel_value].row_indexes, len(this.Rows)-1)
-		this.Indexes[i].Channels[channel_value].Publish_Add(row)
-	}
-	///
-	this.Publish_Add(row)
+		this.Publish_Add(row)
+	})
+	return nil
 }
 
 // this is more for testing purposes because when integrating with the actual database (receiving and reacting to update events wel'e be updating by id)
@@ -61,22 +98,31 @@ func (this *R_Table) Remove_where_eq(row_schema rowType.RowSchema, field string,
 		panic(fmt.Sprintf("not found %v %v %v", row_schema, field, value))
 	}
 	this.is_deleted[array_index] = true
+	this.row_count--
 	debugutil.Print(this.Rows[array_index], "this.Rows[array_index]")
-	this.Publish_remove(this.Rows[array_index])
+	row := this.Rows[array_index]
+	publish_table_change(func() {
+		for i := range this.Indexes {
+			for prefix_len, channel := range this.Indexes[i].channels_of(row) {
+				this.Indexes[i].remove_from_channel(prefix_len, channel, array_index)
+				channel.Publish_remove(row)
+			}
+			this.Indexes[i].remove_ordered(array_index, row)
+		}
+		this.Publish_remove(row)
+	})
 }
 
 // this is more for testing purposes because when integrating with the actual database (receiving and reacting to update events wel'e be updating by id)
-func (this *R_Table) Update_where_eq(row_schema rowType.RowSchema, field string, value any, new_row rowType.RowType) {
+func (this *R_Table) Update_where_eq(row_schema rowType.RowSchema, field string, value any, new_row rowType.RowType) error {
 	array_index := this.Find_row_index(row_schema, field, value)
 	if array_index == -1 {
 		panic("not found")
 	}
-	old_row := this.Rows[array_index]
-	this.Rows[array_index] = new_row
-	this.Publish_Update(old_row, new_row)
+	return this.replace_row(array_index, new_row)
 }
 
-func (this *R_Table) Update_field_where_eq(row_schema rowType.RowSchema, field string, value any, col_to_update_index int, new_value any) {
+func (this *R_Table) Update_field_where_eq(row_schema rowType.RowSchema, field string, value any, col_to_update_index int, new_value any) error {
 	array_index := this.Find_row_index(row_schema, field, value)
 	if array_index == -1 {
 		panic("not found")
@@ -85,19 +131,100 @@ func (this *R_Table) Update_field_where_eq(row_schema rowType.RowSchema, field s
 	new_row := make(rowType.RowType, len(old_row))
 	copy(new_row, old_row)
 	new_row[col_to_update_index] = new_value
+	return this.replace_row(array_index, new_row)
+}
+
+// a row whose indexed value changed moves to the channel of its new value, which is a remove for the subscribers of the old channel and an add for the ones of the new
+func (this *R_Table) replace_row(array_index int, new_row rowType.RowType) error {
+	if err := this.check_unique(new_row, array_index); err != nil {
+		return err
+	}
+	old_row := this.Rows[array_index]
 	this.Rows[array_index] = new_row
-	this.Publish_Update(old_row, new_row)
+	publish_table_change(func() {
+		for i := range this.Indexes {
+			this.Indexes[i].replace_ordered(array_index, old_row, new_row)
+			new_channels := this.Indexes[i].channels_of(new_row)
+			for prefix_len, old_channel := range this.Indexes[i].channels_of(old_row) {
+				new_channel := new_channels[prefix_len]
+				if old_channel == new_channel {
+					old_channel.Publish_Update(old_row, new_row)
+					continue
+				}
+				this.Indexes[i].remove_from_channel(prefix_len, old_channel, array_index)
+				old_channel.Publish_remove(old_row)
+				this.Indexes[i].add_to_channel(prefix_len, new_channel, array_index)
+				new_channel.Publish_Add(new_row)
+			}
+		}
+		this.Publish_Update(old_row, new_row)
+	})
+	return nil
+}
+
+// a *Unique_violation when a row other than the one at row_index (-1 for a new row) has the values of the row in the columns of a unique index
+func (this *R_Table) check_unique(row rowType.RowType, row_index int) error {
+	for i := range this.Indexes {
+		index := &this.Indexes[i]
+		if !index.Unique {
+			continue
+		}
+		values := rowType.RowType{}
+		for _, col := range index.Cols_indexing_on {
+			values = append(values, row[col])
+		}
+		//like in sql a null is not equal to anything, so rows with nulls never clash
+		if slices.Contains(values, nil) {
+			continue
+		}
+		channel, ok := index.Channels[equality_key(values)]
+		if !ok {
+			continue
+		}
+		for _, other := range channel.row_indexes {
+			if other != row_index {
+				cols := []string{}
+				for _, col := range index.Cols_indexing_on {
+					cols = append(cols, this.rowSchema[col].Name)
+				}
+				return &Unique_violation{Cols: cols, Values: values}
+			}
+		}
+	}
+	return nil
+}
+
+// the error a row is rejected with when it has the same values as another row in the columns of a unique index
+type Unique_violation struct {
+	Cols   []string
+	Values rowType.RowType
+}
+
+func (this *Unique_violation) Error() string {
+	values := []string{}
+	for _, value := range this.Values {
+		values = append(values, fmt.Sprint(value))
+	}
+	return fmt.Sprintf("duplicate key: (%s)=(%s) already exists", strings.Join(this.Cols, ", "), strings.Join(values, ", "))
+}
+
+func (this *R_Table) Key_of(row rowType.RowType) string {
+	return utils.String_or_num_to_string(row[this.Key_col])
 }
 
 func (this *R_Table) Find_row_index(row_schema rowType.RowSchema, field string, value any) int {
 
 	// look through the rows using the indexes
 	for i := range this.Indexes {
-		if this.Indexes[i].Col_indexing_on == row_schema.Find_field_index(field) {
-			if channel, ok := this.Indexes[i].Channels[utils.String_or_num_to_string(value)]; ok {
-				// assert.AssertEq(len(channel.row_indexes), 1)
+		if value == nil {
+			break
+		}
+		if this.Indexes[i].Cols_indexing_on[0] == row_schema.Find_field_index(field) {
+			//a channel only has the rows that are not deleted, it can be left empty once they all are
+			if channel, ok := this.Indexes[i].Channels[equality_key(rowType.RowType{value})]; ok && len(channel.row_indexes) > 0 {
 				return channel.row_indexes[0]
 			}
+			return -1
 		}
 
 	}
@@ -116,23 +243,267 @@ func (this *R_Table) Find_row_index(row_schema rowType.RowSchema, field string,
 
 // ///
 
+type Index_kind int
+
+const (
+	Hash    Index_kind = iota //only finds the rows with a value
+	Ordered                   //also finds the rows with a value in a range (for <, <=, >, >=, BETWEEN and LIKE 'prefix%')
+)
+
+// a composite index (on more than one column) has a channel for the values of each prefix of its columns, so it can also be read with only its first columns
 type Index struct {
-	Col_indexing_on int
-	Channels        map[string]*Channel
-	table           *R_Table
+	Cols_indexing_on []int
+	Channels         map[string]*Channel //by the equality_key of the values, so a null has a channel of its own that can't be mistaken for any value
+	table            *R_Table
+	Kind             Index_kind
+	sorted           *skiplist                 //only of an Ordered index, the rows that are not null in the column sorted by it
+	Range_channels   map[string]*Range_channel //only of an Ordered index, one for each range that is read from
+	range_tree       *range_node               //the Range_channels again, to find the ones a row is in
+	distinct_values  []int                     //for each prefix of the columns, how many of its channels have rows
+	Unique           bool                      //no two rows can have the same values in all of the columns, checked by the table before a row is added or updated
+}
+
+type ordered_entry struct {
+	value     any
+	row_index int
+}
+
+// the channel of the rows that have the values in the first len(values) columns of the index
+func (this *Index) Channel_of(values rowType.RowType) *Channel {
+	if len(values) == 0 || len(values) > len(this.Cols_indexing_on) {
+		panic(fmt.Sprintf("an index on %d columns can not be read with %d values", len(this.Cols_indexing_on), len(values)))
+	}
+	key := equality_key(values)
+	if _, ok := this.Channels[key]; !ok {
+		this.Channels[key] = NewChannel(this.table)
+	}
+	return this.Channels[key]
+}
+
+// how many rows Channel_of(values) has, without making the channel
+func (this *Index) Channel_size(values rowType.RowType) int {
+	channel, ok := this.Channels[equality_key(values)]
+	if !ok {
+		return 0
+	}
+	return len(channel.row_indexes)
+}
+
+// the channel the row is in for each prefix of the columns, shortest first
+func (this *Index) channels_of(row rowType.RowType) []*Channel {
+	values := rowType.RowType{}
+	channels := []*Channel{}
+	for _, col := range this.Cols_indexing_on {
+		values = append(values, row[col])
+		channels = append(channels, this.Channel_of(values))
+	}
+	return channels
+}
+
+func (this *Index) add_to_channel(prefix_len int, channel *Channel, row_index int) {
+	channel.add_row_index(row_index)
+	if len(channel.row_indexes) == 1 {
+		this.distinct_values[prefix_len]++
+	}
+}
+
+func (this *Index) remove_from_channel(prefix_len int, channel *Channel, row_index int) {
+	channel.remove_row_index(row_index)
+	if len(channel.row_indexes) == 0 {
+		this.distinct_values[prefix_len]--
+	}
+}
+
+// what the planner knows about an index to guess how many rows reading from it gives
+type Index_stats struct {
+	Rows            int   //of the table, not counting the deleted ones
+	Distinct_values []int //for each prefix of the columns (shortest first), how many different values the rows have in them
+}
+
+func (this *Index) Stats() Index_stats {
+	return Index_stats{Rows: this.table.row_count, Distinct_values: slices.Clone(this.distinct_values)}
+}
+
+// the rows of a channel of the first prefix_len columns, on average
+func (this Index_stats) Average_channel_size(prefix_len int) float64 {
+	if this.Distinct_values[prefix_len-1] == 0 {
+		return 0
+	}
+	return float64(this.Rows) / float64(this.Distinct_values[prefix_len-1])
+}
+
+// how many rows a Range_channel with the bounds would have, without making one
+func (this *Index) Count_in_range(low unwrap.Option[Bound], high unwrap.Option[Bound]) int {
+	if this.Kind != Ordered {
+		panic("only an ordered index can be read in ranges")
+	}
+	bounds := Range_channel{Low: low, High: high}
+	start := this.sorted.count_before(func(entry ordered_entry) bool { return !bounds.above_low(entry.value) })
+	end := this.sorted.count_before(func(entry ordered_entry) bool { return bounds.below_high(entry.value) })
+	return max(end-start, 0)
+}
+
+// makes a Hash index Ordered, sorting the rows the table already has
+func (this *Index) Make_ordered() {
+	if this.Kind == Ordered {
+		return
+	}
+	if len(this.Cols_indexing_on) != 1 {
+		panic("only an index on a single column can be ordered")
+	}
+	this.Kind = Ordered
+	this.sorted = new_skiplist()
+	this.Range_channels = map[string]*Range_channel{}
+	for row_index, row := range this.table.Rows {
+		if !this.table.is_deleted[row_index] {
+			this.add_ordered(row_index, row)
+		}
+	}
+}
+
+// null is not in any range, as comparing with it is never true
+func (this *Index) add_ordered(row_index int, row rowType.RowType) {
+	value := row[this.Cols_indexing_on[0]]
+	if this.Kind != Ordered || value == nil {
+		return
+	}
+	this.sorted.insert(ordered_entry{value, row_index})
+	this.range_tree.covering(value, func(channel *Range_channel) { channel.Publish_Add(row) })
 }
 
-func (this *Index) Get_or_create_channel(row rowType.RowType) *Channel {
-	if _, ok := this.Channels[row[this.Col_indexing_on].(string)]; !ok {
-		this.Channels[row[this.Col_indexing_on].(string)] = NewChannel(this.table)
+func (this *Index) remove_ordered(row_index int, row rowType.RowType) {
+	value := row[this.Cols_indexing_on[0]]
+	if this.Kind != Ordered || value == nil {
+		return
 	}
-	return this.Channels[row[this.Col_indexing_on].(string)]
+	this.sorted.remove(ordered_entry{value, row_index})
+	this.range_tree.covering(value, func(channel *Range_channel) { channel.Publish_remove(row) })
 }
-func (this *Index) Get_or_create_channel_not_with_row(value string) *Channel {
-	if _, ok := this.Channels[value]; !ok {
-		this.Channels[value] = NewChannel(this.table)
+
+// only the ranges the row was in or is now in hear of the change, a range it stayed in gets an update
+func (this *Index) replace_ordered(row_index int, old_row rowType.RowType, new_row rowType.RowType) {
+	if this.Kind != Ordered {
+		return
+	}
+	old_value, new_value := old_row[this.Cols_indexing_on[0]], new_row[this.Cols_indexing_on[0]]
+	if old_value != nil {
+		this.sorted.remove(ordered_entry{old_value, row_index})
+	}
+	if new_value != nil {
+		this.sorted.insert(ordered_entry{new_value, row_index})
+	}
+	was_in, is_in := this.ranges_covering(old_value), this.ranges_covering(new_value)
+	for channel := range was_in {
+		if is_in[channel] {
+			channel.Publish_Update(old_row, new_row)
+		} else {
+			channel.Publish_remove(old_row)
+		}
+	}
+	for channel := range is_in {
+		if !was_in[channel] {
+			channel.Publish_Add(new_row)
+		}
+	}
+}
+
+// null is not in any range
+func (this *Index) ranges_covering(value any) map[*Range_channel]bool {
+	res := map[*Range_channel]bool{}
+	if value != nil {
+		this.range_tree.covering(value, func(channel *Range_channel) { res[channel] = true })
+	}
+	return res
+}
+
+// the channel of the rows whose value is within the bounds, a missing bound leaves that side of the range open
+func (this *Index) Range_channel(low unwrap.Option[Bound], high unwrap.Option[Bound]) *Range_channel {
+	if this.Kind != Ordered {
+		panic("only an ordered index can be read in ranges")
+	}
+	bound_key := func(bound unwrap.Option[Bound]) rowType.RowType {
+		if bound.IsNone() {
+			return rowType.RowType{false, nil, false}
+		}
+		return rowType.RowType{true, bound.Unwrap().Value, bound.Unwrap().Inclusive}
+	}
+	key := Encode_key(append(bound_key(low), bound_key(high)...))
+	if _, ok := this.Range_channels[key]; !ok {
+		this.Range_channels[key] = &Range_channel{
+			Observable: Observable{
+				Subscribers: []Subscriber{},
+			},
+			Low:             low,
+			High:            high,
+			col_indexing_on: this.Cols_indexing_on[0],
+			table:           this.table,
+		}
+		this.range_tree = insert_range(this.range_tree, this.Range_channels[key])
+	}
+	return this.Range_channels[key]
+}
+
+type Bound struct {
+	Value     any
+	Inclusive bool
+}
+
+type Range_channel struct {
+	Observable
+	Low             unwrap.Option[Bound]
+	High            unwrap.Option[Bound]
+	col_indexing_on int      //the index is found again through it when pulling, as the table's indexes can be moved when another one is added
+	table           *R_Table //same as the table of Channel
+}
+
+func (this *Range_channel) above_low(value any) bool {
+	if this.Low.IsNone() {
+		return true
 	}
-	return this.Channels[value]
+	res := utils.CompareValues(value, this.Low.Unwrap().Value)
+	return res > 0 || (res == 0 && this.Low.Unwrap().Inclusive)
+}
+
+func (this *Range_channel) below_high(value any) bool {
+	if this.High.IsNone() {
+		return true
+	}
+	res := utils.CompareValues(value, this.High.Unwrap().Value)
+	return res < 0 || (res == 0 && this.High.Unwrap().Inclusive)
+}
+
+func (this *Range_channel) Covers(value any) bool {
+	return this.above_low(value) && this.below_high(value)
+}
+
+// the rows are pulled in the order of the column, starting from the first one above the low bound
+func (this *Range_channel) Pull(yield func(rowType.RowType) bool) {
+	index := this.table.ordered_index_on(this.col_indexing_on)
+	for entry := range index.sorted.from(func(entry ordered_entry) bool { return !this.above_low(entry.value) }) {
+		if !this.below_high(entry.value) {
+			return
+		}
+		if !yield(this.table.Rows[entry.row_index]) {
+			return
+		}
+	}
+}
+
+func (this *Range_channel) GetRowSchema() rowType.RowSchema {
+	return this.table.rowSchema
+}
+
+func (this *Range_channel) Key_of(row rowType.RowType) string {
+	return this.table.Key_of(row)
+}
+
+func (this *R_Table) ordered_index_on(col_index int) *Index {
+	for i := range this.Indexes {
+		if this.Indexes[i].Kind == Ordered && this.Indexes[i].Cols_indexing_on[0] == col_index {
+			return &this.Indexes[i]
+		}
+	}
+	panic(fmt.Sprintf("there is no ordered index on column %d", col_index))
 }
 
 func NewChannel(table *R_Table) *Channel {
@@ -145,12 +516,22 @@ func NewChannel(table *R_Table) *Channel {
 	}
 }
 
-func NewIndex(col_indexing_on int, table *R_Table) Index {
-	return Index{
-		Col_indexing_on: col_indexing_on,
-		Channels:        map[string]*Channel{},
-		table:           table,
+// the rows the table already has are put in the channels
+func NewIndex(cols_indexing_on []int, table *R_Table) Index {
+	index := Index{
+		Cols_indexing_on: cols_indexing_on,
+		Channels:         map[string]*Channel{},
+		table:            table,
+		distinct_values:  make([]int, len(cols_indexing_on)),
 	}
+	for row_index, row := range table.Rows {
+		if !table.is_deleted[row_index] {
+			for prefix_len, channel := range index.channels_of(row) {
+				index.add_to_channel(prefix_len, channel, row_index)
+			}
+		}
+	}
+	return index
 }
 
 type Channel struct {
@@ -159,6 +540,18 @@ type Channel struct {
 	table *R_Table //i want to remove the need to have this field by not using a generic pull, but rather use a pull method that takes in a reference to the table
 }
 
+// the row indexes are kept in the order of the table, so pulling a channel gives its rows in the order they were added
+func (this *Channel) add_row_index(row_index int) {
+	at, _ := slices.BinarySearch(this.row_indexes, row_index)
+	this.row_indexes = slices.Insert(this.row_indexes, at, row_index)
+}
+
+func (this *Channel) remove_row_index(row_index int) {
+	if at, found := slices.BinarySearch(this.row_indexes, row_index); found {
+		this.row_indexes = slices.Delete(this.row_indexes, at, at+1)
+	}
+}
+
 func (this *Channel) Pull(yield func(rowType.RowType) bool) {
 	for _, row_index := range this.row_indexes {
 		if !yield(this.table.Rows[row_index]) {
@@ -174,3 +567,11 @@ func (this *R_Table) GetRowSchema() rowType.RowSchema {
 func (this *Channel) GetRowSchema() rowType.RowSchema {
 	return this.table.rowSchema
 }
+
+func (this *Channel) Key_of(row rowType.RowType) string {
+	return this.table.Key_of(row)
+}
+
+func (this *R_Table) Row_count() int {
+	return this.row_count
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"slices"
+	"sql-compiler/assert"
+	"sql-compiler/compiler/rowType"
+	pubsub "sql-compiler/pub_sub"
+	"testing"
+)
+
+func TestAggregateRetractions(t *testing.T) {
+	row_schema := rowType.RowSchema{
+		rowType.ColInfo{Type: rowType.String, Name: "name"},
+		rowType.ColInfo{Type: rowType.Int, Name: "score"},
+	}
+	scores := pubsub.New_R_Table(row_schema)
+	scores.Add(rowType.RowType{"a", 10})
+	scores.Add(rowType.RowType{"b", 30})
+	scores.Add(rowType.RowType{"c", 30})
+
+	score := func(row rowType.RowType) any { return row[1] }
+	aggregate := pubsub.NewAggregate(&scores, nil, []pubsub.Aggregate_input{
+		{Func: "COUNT"},
+		{Func: "SUM", Arg: score},
+		{Func: "AVG", Arg: score},
+		{Func: "MIN", Arg: score},
+		{Func: "MAX", Arg: score},
+	}, nil, func(aggregates rowType.RowType) rowType.RowType { return aggregates }, rowType.RowSchema{
+		{Name: "count", Type: rowType.Int},
+		{Name: "sum", Type: rowType.Int},
+		{Name: "avg", Type: rowType.Float},
+		{Name: "min", Type: rowType.Int},
+		{Name: "max", Type: rowType.Int},
+	})
+	updates := 0
+	aggregate.Add_sub(pubsub.NewCustomSubscriber(nil, nil, func(old_row, new_row rowType.RowType) { updates++ }, nil))
+	current := func() rowType.RowType {
+		for row := range aggregate.Pull {
+			return row
+		}
+		panic("an aggregate always has a row")
+	}
+	assert.TAssert(t, slices.Equal(current(), rowType.RowType{3, 70, 70.0 / 3, 10, 30}))
+
+	//one of the two maxes goes away, so the max stays
+	scores.Remove_where_eq(row_schema, "name", "b")
+	assert.TAssert(t, slices.Equal(current(), rowType.RowType{2, 40, 20.0, 10, 30}))
+
+	scores.Remove_where_eq(row_schema, "name", "c")
+	assert.TAssert(t, slices.Equal(current(), rowType.RowType{1, 10, 10.0, 10, 10}))
+
+	scores.Update_where_eq(row_schema, "name", "a", rowType.RowType{"a", 5})
+	assert.TAssert(t, slices.Equal(current(), rowType.RowType{1, 5, 5.0, 5, 5}))
+
+	scores.Remove_where_eq(row_schema, "name", "a")
+	assert.TAssert(t, slices.Equal(current(), rowType.RowType{0, nil, nil, nil, nil}))
+	assert.TAssertEq(t, updates, 4)
+}
+
+func TestAggregateGroupsWithHaving(t *testing.T) {
+	row_schema := rowType.RowSchema{
+		rowType.ColInfo{Type: rowType.String, Name: "name"},
+		rowType.ColInfo{Type: rowType.Int, Name: "age"},
+	}
+	people := pubsub.New_R_Table(row_schema)
+	people.Add(rowType.RowType{"a", 20})
+	people.Add(rowType.RowType{"b", 20})
+	people.Add(rowType.RowType{"c", 30})
+
+	//SELECT COUNT(*), age FROM people GROUP BY age HAVING COUNT(*) >= 2
+	ages := pubsub.NewAggregate(&people, func(row rowType.RowType) rowType.RowType { return rowType.RowType{row[1]} },
+		[]pubsub.Aggregate_input{{Func: "COUNT"}},
+		func(group_row rowType.RowType) bool { return group_row[1].(int) >= 2 },
+		func(group_row rowType.RowType) rowType.RowType { return rowType.RowType{group_row[1], group_row[0]} },
+		rowType.RowSchema{{Name: "count", Type: rowType.Int}, {Name: "age", Type: rowType.Int}},
+	)
+	events := []string{}
+	ages.Add_sub(pubsub.NewCustomSubscriber(
+		func(row rowType.RowType) { events = append(events, "add "+pubsub.Row_key(ages, row)) },
+		func(row rowType.RowType) { events = append(events, "remove "+pubsub.Row_key(ages, row)) },
+		func(old_row, new_row rowType.RowType) {
+			events = append(events, "update "+pubsub.Row_key(ages, old_row)+" to "+pubsub.Row_key(ages, new_row))
+		},
+		nil,
+	))
+	groups := func() []rowType.RowType {
+		res := []rowType.RowType{}
+		for row := range ages.Pull {
+			res = append(res, row)
+		}
+		return res
+	}
+	assert.TAssertEq(t, len(groups()), 1)
+	assert.TAssert(t, slices.Equal(groups()[0], rowType.RowType{2, 20}))
+
+	people.Add(rowType.RowType{"d", 30})
+	people.Add(rowType.RowType{"e", 20})
+	//both groups have the same count now, but each is still stored under its own age
+	people.Remove_where_eq(row_schema, "name", "e")
+	assert.TAssert(t, slices.Equal(events, []string{"add 30", "update 20 to 20", "update 20 to 20"}))
+
+	events = []string{}
+	people.Update_where_eq(row_schema, "name", "c", rowType.RowType{"c", 20})
+	assert.TAssert(t, slices.Equal(events, []string{"remove 30", "update 20 to 20"}))
+	assert.TAssertEq(t, len(groups()), 1)
+	assert.TAssert(t, slices.Equal(groups()[0], rowType.RowType{3, 20}))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"sql-compiler/assert"
+	"sql-compiler/compiler/rowType"
+	pubsub "sql-compiler/pub_sub"
+	"sql-compiler/utils"
+	"testing"
+)
+
+// an operator written outside of pub_sub, it only lets through the first row with each value of a column (and the next one once that row is removed)
+type dedupe struct {
+	pubsub.Observable
+	subscribed_to pubsub.ObservableI
+	col_index     int
+	rows          map[string][]rowType.RowType //all the rows with the value, the first one is the one that is published
+}
+
+func (this *dedupe) Set_subscribed_to(observable pubsub.ObservableI) {
+	this.subscribed_to = observable
+	for row := range observable.Pull {
+		this.insert(row)
+	}
+}
+
+func (this *dedupe) insert(row rowType.RowType) bool {
+	key := utils.String_or_num_to_string(row[this.col_index])
+	this.rows[key] = append(this.rows[key], row)
+	return len(this.rows[key]) == 1
+}
+
+func (this *dedupe) Pull(yield func(rowType.RowType) bool) {
+	for row := range this.subscribed_to.Pull {
+		if &this.rows[utils.String_or_num_to_string(row[this.col_index])][0][0] == &row[0] {
+			if !yield(row) {
+				return
+			}
+		}
+	}
+}
+
+func (this *dedupe) On_add(row rowType.RowType) {
+	if this.insert(row) {
+		this.Publish_Add(row)
+	}
+}
+
+func (this *dedupe) On_remove(row rowType.RowType) {
+	key := utils.String_or_num_to_string(row[this.col_index])
+	rows := this.rows[key]
+	for i := range rows {
+		if &rows[i][0] != &row[0] {
+			continue
+		}
+		this.rows[key] = append(rows[:i:i], rows[i+1:]...)
+		if i != 0 {
+			return
+		}
+		this.Publish_remove(row)
+		if len(this.rows[key]) > 0 {
+			this.Publish_Add(this.rows[key][0])
+		} else {
+			delete(this.rows, key)
+		}
+		return
+	}
+}
+
+func (this *dedupe) On_update(old_row rowType.RowType, new_row rowType.RowType) {
+	this.On_remove(old_row)
+	this.On_add(new_row)
+}
+
+func (this *dedupe) GetRowSchema() rowType.RowSchema {
+	return this.subscribed_to.GetRowSchema()
+}
+
+func TestCustomOperator(t *testing.T) {
+	row_schema := rowType.RowSchema{
+		{Type: rowType.Int, Name: "id"},
+		{Type: rowType.String, Name: "title"},
+		{Type: rowType.Bool, Name: "done"},
+	}
+	todo_table := pubsub.New_R_Table(row_schema)
+	todo_table.Add(rowType.RowType{1, "clean", true})
+	todo_table.Add(rowType.RowType{2, "clean", true})
+	todo_table.Add(rowType.RowType{3, "cook", false})
+
+	deduped := pubsub.Chain(&todo_table, &dedupe{col_index: 1, rows: map[string][]rowType.RowType{}})
+	done := pubsub.Filter_on(deduped, func(row rowType.RowType) bool { return row[2].(bool) })
+	to_json := func() map[string]map[string]any {
+		var actual map[string]map[string]any
+		if err := json.Unmarshal([]byte(pubsub.ObserverToJson(done, row_schema)), &actual); err != nil {
+			t.Fatal(err)
+		}
+		return actual
+	}
+
+	actual := to_json()
+	assert.AssertEq(len(actual), 1)
+	assert.AssertEq(actual["1"]["title"], "clean")
+
+	todo_table.Add(rowType.RowType{4, "shop", true})
+	todo_table.Remove_where_eq(row_schema, "id", 1)
+	actual = to_json()
+	assert.AssertEq(len(actual), 2)
+	assert.AssertEq(actual["2"]["title"], "clean")
+	assert.AssertEq(actual["4"]["title"], "shop")
+}
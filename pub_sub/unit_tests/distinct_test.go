@@ -0,0 +1,57 @@
+package main
+
+import (
+	"slices"
+	"sql-compiler/assert"
+	"sql-compiler/compiler/rowType"
+	pubsub "sql-compiler/pub_sub"
+	"testing"
+)
+
+func TestDistinctCountsDuplicates(t *testing.T) {
+	row_schema := rowType.RowSchema{
+		rowType.ColInfo{Type: rowType.String, Name: "name"},
+		rowType.ColInfo{Type: rowType.String, Name: "state"},
+		rowType.ColInfo{Type: rowType.Int, Name: "age"},
+	}
+	people := pubsub.New_R_Table(row_schema)
+	people.Add(rowType.RowType{"a", "ny", 20})
+	people.Add(rowType.RowType{"b", "ny", 20})
+	people.Add(rowType.RowType{"c", "ny", 30})
+
+	//SELECT DISTINCT ON (state) state, age FROM people
+	projected := pubsub.Map_on(&people, func(row rowType.RowType) rowType.RowType { return rowType.RowType{row[1], row[2]} })
+	distinct := pubsub.NewDistinct(projected, []int{0})
+	events := []string{}
+	distinct.Add_sub(pubsub.NewCustomSubscriber(
+		func(row rowType.RowType) { events = append(events, "add "+pubsub.Row_key(distinct, row)) },
+		func(row rowType.RowType) { events = append(events, "remove "+pubsub.Row_key(distinct, row)) },
+		func(old_row, new_row rowType.RowType) {
+			events = append(events, "update "+pubsub.Row_key(distinct, old_row)+" to "+pubsub.Row_key(distinct, new_row))
+		},
+		nil,
+	))
+	rows := func() []rowType.RowType {
+		res := []rowType.RowType{}
+		for row := range distinct.Pull {
+			res = append(res, row)
+		}
+		return res
+	}
+	assert.TAssertEq(t, len(rows()), 1)
+	assert.TAssert(t, slices.Equal(rows()[0], rowType.RowType{"ny", 20}))
+
+	//"b" is a duplicate of "a" so the published row stays the same, it only changes once both are gone
+	people.Remove_where_eq(row_schema, "name", "a")
+	assert.TAssertEq(t, len(events), 0)
+	people.Update_where_eq(row_schema, "name", "b", rowType.RowType{"b", "ny", 40})
+	assert.TAssert(t, slices.Equal(events, []string{"update ny to ny"}))
+	assert.TAssert(t, slices.Equal(rows()[0], rowType.RowType{"ny", 30}))
+
+	events = []string{}
+	people.Update_where_eq(row_schema, "name", "c", rowType.RowType{"c", "ca", 30})
+	people.Remove_where_eq(row_schema, "name", "b")
+	assert.TAssert(t, slices.Equal(events, []string{"update ny to ny", "add ca", "remove ny"}))
+	assert.TAssertEq(t, len(rows()), 1)
+	assert.TAssert(t, slices.Equal(rows()[0], rowType.RowType{"ca", 30}))
+}
@@ -35,7 +35,7 @@ func TestThatFilterIsAlwaysInSync(t *testing.T) {
 	todo_table.Add(rowType.RowType{
 		"take out the trash", false, 1,
 	})
-	filter := todo_table.Filter_on(func(rt rowType.RowType) bool {
+	filter := pubsub.Filter_on(&todo_table, func(rt rowType.RowType) bool {
 		return rt[1].(bool) == true
 	})
 	json_string := pubsub.ObserverToJson(filter, row_schema)
@@ -8,6 +8,7 @@ import (
 	"sql-compiler/display"
 	event_emitter_tree "sql-compiler/eventEmitterTree"
 	"sql-compiler/local_live_db"
+	pubsub "sql-compiler/pub_sub"
 	"sql-compiler/unwrap"
 	"testing"
 )
@@ -18,7 +19,7 @@ func TestGroupBy(t *testing.T) {
 	sql := "select id, name, age from person where age >= 0 group by age "
 	obs := compiler_runtime.Query_to_observer(sql)
 
-	obs.To_display(unwrap.None[rowType.RowSchema]())
+	pubsub.To_display(obs, unwrap.None[rowType.RowSchema]())
 
 	// @ANTI_SOLID_PATTERN
 
@@ -31,11 +32,11 @@ func TestGroupBy(t *testing.T) {
 		},
 	}
 	event_emitter.SyncFromObservable(obs, "")
-	db_tables.Tables.Get("person").Insert(rowType.RowType{"shmuli", "email@gmail.com", 22, "state", db_tables.Tables.Get("person").Next_row_id()})
-	db_tables.Tables.Get("person").Insert(rowType.RowType{"ajay", "ajay@gmail.com", 30, "state", db_tables.Tables.Get("person").Next_row_id()})
-	db_tables.Tables.Get("person").Insert(rowType.RowType{"natalie", "natalie@gmail.com", 22, "state", db_tables.Tables.Get("person").Next_row_id()})
-	db_tables.Tables.Get("person").Insert(rowType.RowType{"ellen", "ellen@gmail.com", 30, "state", db_tables.Tables.Get("person").Next_row_id()})
-	db_tables.Tables.Get("person").Insert(rowType.RowType{"fred", "fred@gmail.com", 44, "state", db_tables.Tables.Get("person").Next_row_id()})
+	db_tables.Tables.Get("person").Insert(rowType.RowType{"shmuli", "email@gmail.com", 22, "state", db_tables.Tables.Get("person").Next_row_id(), "profile-picture"})
+	db_tables.Tables.Get("person").Insert(rowType.RowType{"ajay", "ajay@gmail.com", 30, "state", db_tables.Tables.Get("person").Next_row_id(), "profile-picture"})
+	db_tables.Tables.Get("person").Insert(rowType.RowType{"natalie", "natalie@gmail.com", 22, "state", db_tables.Tables.Get("person").Next_row_id(), "profile-picture"})
+	db_tables.Tables.Get("person").Insert(rowType.RowType{"ellen", "ellen@gmail.com", 30, "state", db_tables.Tables.Get("person").Next_row_id(), "profile-picture"})
+	db_tables.Tables.Get("person").Insert(rowType.RowType{"fred", "fred@gmail.com", 44, "state", db_tables.Tables.Get("person").Next_row_id(), "profile-picture"})
 	// Check that the top-level age groups exist in the local_live_db after inserts
 
 	_, has22 := live_db.Data["22"]
@@ -37,7 +37,7 @@ func TestThatMapperIsAlwaysInSync(t *testing.T) {
 	todo_table.Add(rowType.RowType{
 		"take out the trash", false, 1,
 	})
-	mapper := todo_table.Map_on(func(rt rowType.RowType) rowType.RowType {
+	mapper := pubsub.Map_on(&todo_table, func(rt rowType.RowType) rowType.RowType {
 		return rowType.RowType{rt[0].(string) + "!"}
 	})
 	json_string := pubsub.ObserverToJson(mapper, row_schema)
@@ -88,7 +88,7 @@ func TestThatMapperAgainstExpected(t *testing.T) {
 	todo_table.Add(rowType.RowType{
 		"take out the trash", false, 1,
 	})
-	mapper := todo_table.Map_on(func(rt rowType.RowType) rowType.RowType {
+	mapper := pubsub.Map_on(&todo_table, func(rt rowType.RowType) rowType.RowType {
 		return rowType.RowType{rt[0].(string) + "!"}
 	})
 	json_string := pubsub.ObserverToJson(mapper, row_schema)
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"sql-compiler/assert"
+	"sql-compiler/compiler/rowType"
+	event_emitter_tree "sql-compiler/eventEmitterTree"
+	"sql-compiler/local_live_db"
+	pubsub "sql-compiler/pub_sub"
+	"sql-compiler/unwrap"
+	"sql-compiler/utils"
+	"testing"
+)
+
+func TestOrderByWindow(t *testing.T) {
+	row_schema := rowType.RowSchema{
+		rowType.ColInfo{Type: rowType.String, Name: "name"},
+		rowType.ColInfo{Type: rowType.Int, Name: "score"},
+	}
+	scores := pubsub.New_R_Table(row_schema)
+	scores.Add(rowType.RowType{"a", 10})
+	scores.Add(rowType.RowType{"b", 30})
+	scores.Add(rowType.RowType{"c", 20})
+
+	top_two := pubsub.NewOrderBy(&scores, []pubsub.Sort_key{{Col_index: 1, Desc: true}}, 0, unwrap.Some(2))
+	events := []string{}
+	top_two.Add_sub(pubsub.NewCustomSubscriber(
+		func(row rowType.RowType) { events = append(events, "add "+row[0].(string)) },
+		func(row rowType.RowType) { events = append(events, "remove "+row[0].(string)) },
+		func(old_row, new_row rowType.RowType) { events = append(events, "update "+old_row[0].(string)) },
+		nil,
+	))
+	names := func() []string {
+		res := []string{}
+		for row := range top_two.Pull {
+			res = append(res, row[0].(string))
+		}
+		return res
+	}
+
+	assert.TAssert(t, utils.CompareSlices(names(), []string{"b", "c"}))
+
+	scores.Add(rowType.RowType{"d", 25})
+	assert.TAssert(t, utils.CompareSlices(names(), []string{"b", "d"}))
+	assert.TAssert(t, utils.CompareSlices(events, []string{"remove c", "add d"}))
+
+	events = []string{}
+	scores.Add(rowType.RowType{"e", 1})
+	assert.TAssertEq(t, len(events), 0, "a row that lands outside of the window should not be published")
+
+	scores.Remove_where_eq(row_schema, "name", "b")
+	assert.TAssert(t, utils.CompareSlices(names(), []string{"d", "c"}))
+	assert.TAssert(t, utils.CompareSlices(events, []string{"remove b", "add c"}))
+
+	events = []string{}
+	scores.Update_where_eq(row_schema, "name", "c", rowType.RowType{"c", 100})
+	assert.TAssert(t, utils.CompareSlices(names(), []string{"c", "d"}))
+	assert.TAssert(t, utils.CompareSlices(events, []string{"update c"}))
+	assert.TAssertEq(t, top_two.Position_of(rowType.RowType{"c", 100}), 0)
+}
+
+// applies random changes to a table and checks that a client that only places rows at the positions it gets sent ends up with the same list as sorting from scratch
+func TestOrderByPositionsKeepClientInSync(t *testing.T) {
+	row_schema := rowType.RowSchema{
+		rowType.ColInfo{Type: rowType.String, Name: "name"},
+		rowType.ColInfo{Type: rowType.Int, Name: "score"},
+	}
+	scores := pubsub.New_R_Table(row_schema)
+	ordered := pubsub.NewOrderBy(&scores, []pubsub.Sort_key{{Col_index: 1}}, 2, unwrap.Some(4))
+
+	live_db := local_live_db.LocalLiveDB{Data: make(map[string]any)}
+	event_emitter := event_emitter_tree.EventEmitterTree{
+		On_message: func(message event_emitter_tree.SyncMessage) {
+			live_db.HandleUpdate(message)
+		},
+	}
+	event_emitter.SyncFromObservable(ordered, "")
+
+	random := rand.New(rand.NewSource(7))
+	unused_scores := random.Perm(1000)
+	current := map[string]int{}
+	next_name := 0
+	for step := 0; step < 300; step++ {
+		names := []string{}
+		for name := range current {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		switch operation := random.Intn(3); {
+		case operation == 0 || len(names) == 0:
+			name := utils.String_or_num_to_string(next_name)
+			next_name++
+			current[name] = unused_scores[0]
+			scores.Add(rowType.RowType{name, unused_scores[0]})
+			unused_scores = unused_scores[1:]
+		case operation == 1:
+			name := names[random.Intn(len(names))]
+			delete(current, name)
+			scores.Remove_where_eq(row_schema, "name", name)
+		default:
+			name := names[random.Intn(len(names))]
+			current[name] = unused_scores[0]
+			scores.Update_where_eq(row_schema, "name", name, rowType.RowType{name, unused_scores[0]})
+			unused_scores = unused_scores[1:]
+		}
+
+		expected := []string{}
+		for name := range current {
+			expected = append(expected, name)
+		}
+		sort.Slice(expected, func(i, j int) bool { return current[expected[i]] < current[expected[j]] })
+		expected = expected[min(2, len(expected)):]
+		expected = expected[:min(4, len(expected))]
+
+		assert.TAssert(t, utils.CompareSlices(live_db.Order[""], expected), "the client's order drifted from the real order")
+		assert.TAssertEq(t, len(live_db.Data), len(expected))
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"slices"
+	"sql-compiler/assert"
+	"sql-compiler/compiler/rowType"
+	pubsub "sql-compiler/pub_sub"
+	"testing"
+)
+
+func TestRecursiveUnionCountsEveryPath(t *testing.T) {
+	node_schema := rowType.RowSchema{rowType.ColInfo{Type: rowType.Int, Name: "node"}}
+	edge_schema := rowType.RowSchema{rowType.ColInfo{Type: rowType.Int, Name: "to"}, rowType.ColInfo{Type: rowType.Int, Name: "from"}}
+	anchor := pubsub.New_R_Table(node_schema)
+	edges := pubsub.New_R_Table(edge_schema)
+	anchor.Add(rowType.RowType{1})
+	edges.Add(rowType.RowType{2, 1})
+	edges.Add(rowType.RowType{3, 1})
+	edges.Add(rowType.RowType{4, 2})
+	edges.Add(rowType.RowType{4, 3})
+
+	nodes := func(obs pubsub.ObservableI) []int {
+		res := []int{}
+		for row := range obs.Pull {
+			res = append(res, row[0].(int))
+		}
+		slices.Sort(res)
+		return res
+	}
+	edge_key := func(edge rowType.RowType) rowType.RowType { return rowType.RowType{edge[1]} }
+	row_key := func(row rowType.RowType) rowType.RowType { return row }
+	step := func(edge rowType.RowType, row rowType.RowType) (rowType.RowType, bool) {
+		return rowType.RowType{edge[0]}, true
+	}
+	all := pubsub.NewRecursive_union(true, &anchor, &edges, edge_key, row_key, step, node_schema)
+	distinct := pubsub.NewRecursive_union(false, &anchor, &edges, edge_key, row_key, step, node_schema)
+	//4 can be gotten to through 2 and through 3
+	assert.TAssert(t, slices.Equal(nodes(all), []int{1, 2, 3, 4, 4}))
+	assert.TAssert(t, slices.Equal(nodes(distinct), []int{1, 2, 3, 4}))
+
+	events := []string{}
+	all.Add_sub(pubsub.NewCustomSubscriber(
+		func(row rowType.RowType) { events = append(events, "add") },
+		func(row rowType.RowType) { events = append(events, "remove") },
+		func(old_row, new_row rowType.RowType) { events = append(events, "update") },
+		nil,
+	))
+	edges.Remove_where_eq(edge_schema, "from", 3)
+	assert.TAssert(t, slices.Equal(nodes(all), []int{1, 2, 3, 4}))
+	assert.TAssert(t, slices.Equal(nodes(distinct), []int{1, 2, 3, 4}))
+	assert.TAssert(t, slices.Equal(events, []string{"remove"}))
+
+	anchor.Add(rowType.RowType{2})
+	assert.TAssert(t, slices.Equal(nodes(all), []int{1, 2, 2, 3, 4, 4}))
+	assert.TAssert(t, slices.Equal(nodes(distinct), []int{1, 2, 3, 4}))
+	assert.TAssert(t, slices.Equal(events, []string{"remove", "add", "add"}))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"slices"
+	"sql-compiler/assert"
+	"sql-compiler/compiler/rowType"
+	pubsub "sql-compiler/pub_sub"
+	"testing"
+)
+
+func TestSemiJoinNulls(t *testing.T) {
+	row_schema := rowType.RowSchema{rowType.ColInfo{Type: rowType.Int, Name: "value", Nullable: true}}
+	left := pubsub.New_R_Table(row_schema)
+	right := pubsub.New_R_Table(row_schema)
+	left.Add(rowType.RowType{1})
+	left.Add(rowType.RowType{2})
+	left.Add(rowType.RowType{nil})
+
+	values := func(obs pubsub.ObservableI) []any {
+		res := []any{}
+		for row := range obs.Pull {
+			res = append(res, row[0])
+		}
+		return res
+	}
+	key := func(row rowType.RowType) rowType.RowType { return row }
+	in := pubsub.NewSemi_join(false, true, &left, &right, key, key)
+	not_in := pubsub.NewSemi_join(true, true, &left, &right, key, key)
+	//there is nothing to look in, so nothing is in it (not even null)
+	assert.TAssert(t, slices.Equal(values(in), []any{}))
+	assert.TAssert(t, slices.Equal(values(not_in), []any{1, 2, nil}))
+
+	events := []string{}
+	not_in.Add_sub(pubsub.NewCustomSubscriber(
+		func(row rowType.RowType) { events = append(events, "add") },
+		func(row rowType.RowType) { events = append(events, "remove") },
+		nil,
+		nil,
+	))
+	right.Add(rowType.RowType{1.0})
+	assert.TAssert(t, slices.Equal(values(in), []any{1}))
+	assert.TAssert(t, slices.Equal(values(not_in), []any{2}))
+	//a null among the values makes NOT IN unknown for anything that isn't in them
+	right.Add(rowType.RowType{nil})
+	assert.TAssert(t, slices.Equal(values(in), []any{1}))
+	assert.TAssert(t, slices.Equal(values(not_in), []any{}))
+	assert.TAssert(t, slices.Equal(events, []string{"remove", "remove", "remove"}))
+}
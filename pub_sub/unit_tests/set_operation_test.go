@@ -0,0 +1,53 @@
+package main
+
+import (
+	"slices"
+	"sql-compiler/assert"
+	"sql-compiler/compiler/rowType"
+	pubsub "sql-compiler/pub_sub"
+	"testing"
+)
+
+func TestSetOperationCopies(t *testing.T) {
+	row_schema := rowType.RowSchema{rowType.ColInfo{Type: rowType.String, Name: "name"}}
+	left := pubsub.New_R_Table(row_schema)
+	right := pubsub.New_R_Table(row_schema)
+	left.Add(rowType.RowType{"a"})
+	left.Add(rowType.RowType{"a"})
+	left.Add(rowType.RowType{"b"})
+	right.Add(rowType.RowType{"a"})
+
+	names := func(obs pubsub.ObservableI) []string {
+		res := []string{}
+		for row := range obs.Pull {
+			res = append(res, row[0].(string))
+		}
+		return res
+	}
+	union_all := pubsub.NewSet_operation(pubsub.Union, true, &left, &right, row_schema)
+	union := pubsub.NewSet_operation(pubsub.Union, false, &left, &right, row_schema)
+	intersect_all := pubsub.NewSet_operation(pubsub.Intersect, true, &left, &right, row_schema)
+	except_all := pubsub.NewSet_operation(pubsub.Except, true, &left, &right, row_schema)
+	except := pubsub.NewSet_operation(pubsub.Except, false, &left, &right, row_schema)
+	assert.TAssert(t, slices.Equal(names(union_all), []string{"a", "a", "a", "b"}))
+	assert.TAssert(t, slices.Equal(names(union), []string{"a", "b"}))
+	assert.TAssert(t, slices.Equal(names(intersect_all), []string{"a"}))
+	assert.TAssert(t, slices.Equal(names(except_all), []string{"a", "b"}))
+	assert.TAssert(t, slices.Equal(names(except), []string{"b"}))
+
+	events := []string{}
+	except.Add_sub(pubsub.NewCustomSubscriber(
+		func(row rowType.RowType) { events = append(events, "add "+row[0].(string)) },
+		func(row rowType.RowType) { events = append(events, "remove "+row[0].(string)) },
+		func(old_row, new_row rowType.RowType) {
+			events = append(events, "update "+old_row[0].(string)+" to "+new_row[0].(string))
+		},
+		nil,
+	))
+	right.Remove_where_eq(row_schema, "name", "a")
+	left.Update_where_eq(row_schema, "name", "b", rowType.RowType{"c"})
+	right.Add(rowType.RowType{"c"})
+	assert.TAssert(t, slices.Equal(events, []string{"add a", "update b to c", "remove c"}))
+	assert.TAssert(t, slices.Equal(names(except), []string{"a"}))
+	assert.TAssert(t, slices.Equal(names(union_all), []string{"a", "a", "c", "c"}))
+}
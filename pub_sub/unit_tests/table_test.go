@@ -2,9 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"slices"
 	"sql-compiler/compare"
 	"sql-compiler/compiler/rowType"
 	pubsub "sql-compiler/pub_sub"
+	"sql-compiler/unwrap"
 	"testing"
 )
 
@@ -164,3 +166,213 @@ func TestDoubleUpdateCancelOut(t *testing.T) {
 	}
 
 }
+
+func TestIndexMaintainedOnUpdateAndRemove(t *testing.T) {
+	row_schema := rowType.RowSchema{
+		{Type: rowType.String, Name: "title"},
+		{Type: rowType.Int, Name: "person_id"},
+	}
+	todo_table := pubsub.New_R_Table(row_schema)
+	todo_table.Indexes = append(todo_table.Indexes, pubsub.NewIndex([]int{1}, &todo_table))
+	index := &todo_table.Indexes[0]
+
+	todo_table.Add(rowType.RowType{"dishes", 1})
+	todo_table.Add(rowType.RowType{"laundry", 1})
+	todo_table.Add(rowType.RowType{"groceries", 2})
+
+	events := []string{}
+	subscribe := func(channel *pubsub.Channel, name string) {
+		channel.Add_sub(pubsub.NewCustomSubscriber(
+			func(row rowType.RowType) { events = append(events, name+" add "+row[0].(string)) },
+			func(row rowType.RowType) { events = append(events, name+" remove "+row[0].(string)) },
+			func(old_row, new_row rowType.RowType) {
+				events = append(events, name+" update "+old_row[0].(string)+" to "+new_row[0].(string))
+			},
+			nil,
+		))
+	}
+	subscribe(index.Channel_of(rowType.RowType{1}), "1")
+	subscribe(index.Channel_of(rowType.RowType{2}), "2")
+	titles := func(channel *pubsub.Channel) []string {
+		res := []string{}
+		for row := range channel.Pull {
+			res = append(res, row[0].(string))
+		}
+		return res
+	}
+
+	todo_table.Update_where_eq(row_schema, "title", "dishes", rowType.RowType{"clean dishes", 1})
+	todo_table.Update_field_where_eq(row_schema, "title", "laundry", 1, 2)
+	todo_table.Remove_where_eq(row_schema, "title", "groceries")
+
+	expected_events := []string{"1 update dishes to clean dishes", "1 remove laundry", "2 add laundry", "2 remove groceries"}
+	if !slices.Equal(events, expected_events) {
+		t.Fatalf("expected %v but got %v", expected_events, events)
+	}
+	if !slices.Equal(titles(index.Channel_of(rowType.RowType{1})), []string{"clean dishes"}) || !slices.Equal(titles(index.Channel_of(rowType.RowType{2})), []string{"laundry"}) {
+		t.Fatalf("expected the channels to only have the rows with their value but got %v and %v", titles(index.Channel_of(rowType.RowType{1})), titles(index.Channel_of(rowType.RowType{2})))
+	}
+
+	todo_table.Remove_where_eq(row_schema, "person_id", 1)
+	if found := todo_table.Find_row_index(row_schema, "person_id", 1); found != -1 {
+		t.Fatalf("expected the removed row not to be found through the index but got row %d", found)
+	}
+	if found := todo_table.Find_row_index(row_schema, "person_id", 2); found != 1 {
+		t.Fatalf("expected laundry (row 1) to be found through the index but got row %d", found)
+	}
+}
+
+func TestOrderedIndexRange(t *testing.T) {
+	row_schema := rowType.RowSchema{
+		{Type: rowType.String, Name: "name"},
+		{Type: rowType.Int, Name: "age"},
+	}
+	person_table := pubsub.New_R_Table(row_schema)
+	person_table.Add(rowType.RowType{"old", 70})
+	person_table.Add(rowType.RowType{"young", 10})
+	person_table.Indexes = append(person_table.Indexes, pubsub.NewIndex([]int{1}, &person_table))
+	index := &person_table.Indexes[0]
+	index.Make_ordered() //sorts the rows that are already in the table
+	person_table.Add(rowType.RowType{"adult", 30})
+	person_table.Add(rowType.RowType{"unknown", nil})
+
+	adults := index.Range_channel(unwrap.Some(pubsub.Bound{Value: 18, Inclusive: true}), unwrap.Some(pubsub.Bound{Value: 65}))
+	events := []string{}
+	adults.Add_sub(pubsub.NewCustomSubscriber(
+		func(row rowType.RowType) { events = append(events, "add "+row[0].(string)) },
+		func(row rowType.RowType) { events = append(events, "remove "+row[0].(string)) },
+		func(old_row, new_row rowType.RowType) { events = append(events, "update "+new_row[0].(string)) },
+		nil,
+	))
+	names := func(channel pubsub.ObservableI) []string {
+		res := []string{}
+		for row := range channel.Pull {
+			res = append(res, row[0].(string))
+		}
+		return res
+	}
+	if !slices.Equal(names(adults), []string{"adult"}) {
+		t.Fatalf("expected only adult but got %v", names(adults))
+	}
+	everyone := index.Range_channel(unwrap.None[pubsub.Bound](), unwrap.None[pubsub.Bound]())
+	if !slices.Equal(names(everyone), []string{"young", "adult", "old"}) {
+		t.Fatalf("expected the rows that are not null by age but got %v", names(everyone))
+	}
+
+	person_table.Update_field_where_eq(row_schema, "name", "young", 1, 20)
+	person_table.Update_field_where_eq(row_schema, "name", "adult", 1, 31)
+	person_table.Update_field_where_eq(row_schema, "name", "old", 1, 71)
+	person_table.Remove_where_eq(row_schema, "name", "adult")
+	expected_events := []string{"add young", "update adult", "remove adult"}
+	if !slices.Equal(events, expected_events) {
+		t.Fatalf("expected %v but got %v", expected_events, events)
+	}
+	if !slices.Equal(names(adults), []string{"young"}) {
+		t.Fatalf("expected only young but got %v", names(adults))
+	}
+}
+
+func TestCompositeIndexPrefixes(t *testing.T) {
+	row_schema := rowType.RowSchema{
+		{Type: rowType.String, Name: "owner"},
+		{Type: rowType.Int, Name: "priority", Nullable: true},
+		{Type: rowType.String, Name: "title"},
+	}
+	todo_table := pubsub.New_R_Table(row_schema)
+	todo_table.Indexes = append(todo_table.Indexes, pubsub.NewIndex([]int{0, 1}, &todo_table))
+	index := &todo_table.Indexes[0]
+	todo_table.Add(rowType.RowType{"ann", 1, "urgent"})
+	todo_table.Add(rowType.RowType{"ann", 2, "later"})
+	todo_table.Add(rowType.RowType{"ann", nil, "someday"})
+	todo_table.Add(rowType.RowType{"1", 1, "not ann"})
+
+	titles := func(channel *pubsub.Channel) []string {
+		res := []string{}
+		for row := range channel.Pull {
+			res = append(res, row[2].(string))
+		}
+		return res
+	}
+	for _, test := range []struct {
+		values   rowType.RowType
+		expected []string
+	}{
+		{rowType.RowType{"ann"}, []string{"urgent", "later", "someday"}},
+		{rowType.RowType{"ann", 1}, []string{"urgent"}},
+		{rowType.RowType{"ann", 1.0}, []string{"urgent"}}, //1.0 == 1
+		{rowType.RowType{"ann", nil}, []string{"someday"}},
+		{rowType.RowType{1}, []string{}}, //the string "1" is not the number 1
+	} {
+		if actual := titles(index.Channel_of(test.values)); !slices.Equal(actual, test.expected) {
+			t.Errorf("%v: expected %v but got %v", test.values, test.expected, actual)
+		}
+	}
+
+	//changing the second column moves the row between the channels of both columns, but not of only the first
+	todo_table.Update_field_where_eq(row_schema, "title", "later", 1, 1)
+	if actual := titles(index.Channel_of(rowType.RowType{"ann", 1})); !slices.Equal(actual, []string{"urgent", "later"}) {
+		t.Errorf("expected urgent and later but got %v", actual)
+	}
+	if actual := titles(index.Channel_of(rowType.RowType{"ann"})); !slices.Equal(actual, []string{"urgent", "later", "someday"}) {
+		t.Errorf("expected every todo of ann but got %v", actual)
+	}
+}
+
+func TestIndexStats(t *testing.T) {
+	row_schema := rowType.RowSchema{
+		{Type: rowType.String, Name: "owner"},
+		{Type: rowType.Int, Name: "priority"},
+	}
+	todo_table := pubsub.New_R_Table(row_schema)
+	todo_table.Indexes = append(todo_table.Indexes, pubsub.NewIndex([]int{0, 1}, &todo_table))
+	index := &todo_table.Indexes[0]
+	todo_table.Add(rowType.RowType{"ann", 1})
+	todo_table.Add(rowType.RowType{"ann", 2})
+	todo_table.Add(rowType.RowType{"bob", 1})
+	todo_table.Add(rowType.RowType{"cid", 1})
+	todo_table.Update_where_eq(row_schema, "owner", "cid", rowType.RowType{"bob", 3})
+	todo_table.Remove_where_eq(row_schema, "owner", "ann")
+
+	stats := index.Stats()
+	expected := pubsub.Index_stats{Rows: 3, Distinct_values: []int{2, 3}}
+	if stats.Rows != expected.Rows || !slices.Equal(stats.Distinct_values, expected.Distinct_values) {
+		t.Fatalf("expected %v but got %v", expected, stats)
+	}
+	if stats.Average_channel_size(1) != 1.5 {
+		t.Fatalf("expected 1.5 rows for each owner but got %v", stats.Average_channel_size(1))
+	}
+}
+
+func TestUniqueIndex(t *testing.T) {
+	row_schema := rowType.RowSchema{
+		{Type: rowType.String, Name: "email"},
+		{Type: rowType.Int, Name: "id"},
+	}
+	people := pubsub.New_R_Table(row_schema)
+	people.Add(rowType.RowType{"ann@mail.com", 1})
+	//an index made after rows were added still has them
+	people.Indexes = append(people.Indexes, pubsub.NewIndex([]int{0}, &people))
+	people.Indexes[0].Unique = true
+	people.Add(rowType.RowType{"bob@mail.com", 2})
+
+	if err := people.Add(rowType.RowType{"ann@mail.com", 3}); err == nil || err.Error() != "duplicate key: (email)=(ann@mail.com) already exists" {
+		t.Fatalf("expected adding a second ann@mail.com to be rejected but got %v", err)
+	}
+	if _, ok := people.Update_where_eq(row_schema, "id", 2, rowType.RowType{"ann@mail.com", 2}).(*pubsub.Unique_violation); !ok {
+		t.Fatal("expected updating bob@mail.com to ann@mail.com to be rejected")
+	}
+	//a row can be updated to the values it already has
+	if err := people.Update_where_eq(row_schema, "id", 2, rowType.RowType{"bob@mail.com", 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []rowType.RowType{}
+	for row := range people.Pull {
+		rows = append(rows, row)
+	}
+	std_message, err := compare.Compare([]rowType.RowType{{"ann@mail.com", 1}, {"bob@mail.com", 20}}, rows, "")
+	if err != nil {
+		t.Log(std_message)
+		t.Fatal(err)
+	}
+}
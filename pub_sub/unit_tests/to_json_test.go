@@ -36,7 +36,7 @@ func TestThat_Table_To_Json_Is_Valid_Json(t *testing.T) {
 	todo_table.Add(rowType.RowType{
 		"take out the trash", false, 1,
 	})
-	mapper := todo_table.Map_on(func(rt rowType.RowType) rowType.RowType {
+	mapper := pubsub.Map_on(&todo_table, func(rt rowType.RowType) rowType.RowType {
 		return rowType.RowType{rt[0].(string) + "!"}
 	})
 
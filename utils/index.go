@@ -1,25 +1,48 @@
 package utils
 
 import (
+	"cmp"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func String_or_num_to_string(value any) string {
 	switch value := value.(type) {
+	case nil:
+		return "null"
 	case string:
 		return value
 	case int:
 		return fmt.Sprintf("%d", value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64) //so a whole float is written like the int with the same value
 	case bool:
 		if value {
 			return "true"
 		}
 		return "false"
+	case time.Time:
+		return value.UTC().Format(time.RFC3339Nano)
+	case json.RawMessage:
+		return string(value)
 	default:
-		panic(fmt.Sprintf("only string and int are supported and not %T", value))
+		panic(fmt.Sprintf("only strings, numbers, bools, timestamps and json are supported and not %T", value))
 	}
 }
+
+// reads an ISO-8601 timestamp (or just a date), like the ones timestamps are written as
+func Parse_timestamp(value string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if timestamp, err := time.Parse(layout, value); err == nil {
+			return timestamp
+		}
+	}
+	panic(fmt.Sprintf("%q is not an ISO-8601 timestamp", value))
+}
+
 func Capitalize(s string) string {
 	return strings.ToUpper(s[:1]) + s[1:]
 }
@@ -35,3 +58,44 @@ func CompareSlices[T comparable](a, b []T) bool {
 	}
 	return true
 }
+
+// orders two cell values of the same type, returns a negative number when a comes first, 0 when they are equal and a positive number when b comes first
+// null (nil) comes before every other value
+func CompareValues(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case int:
+		if b, is_float := b.(float64); is_float {
+			return cmp.Compare(float64(a), b)
+		}
+		return cmp.Compare(a, b.(int))
+	case float64:
+		if b, is_int := b.(int); is_int {
+			return cmp.Compare(a, float64(b))
+		}
+		return cmp.Compare(a, b.(float64))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case bool:
+		if a == b.(bool) {
+			return 0
+		}
+		if !a {
+			return -1
+		}
+		return 1
+	default:
+		panic(fmt.Sprintf("can not compare %T with %T", a, b))
+	}
+}